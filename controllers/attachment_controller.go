@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type AttachmentController struct {
+	AttachmentService *services.AttachmentService
+}
+
+// @Summary		上传附件
+// @Description	上传文件，返回的附件ID可在发送消息时通过 attachment_ids 引用。超过保留时间仍未被消息引用的附件会被自动清理
+// @Accept			multipart/form-data
+// @Produce		json
+// @Param			file		formData	file					true	"文件"
+// @Param			session_id	formData	string					false	"会话ID"
+// @Success		200			{object}	models.Attachment		"成功"
+// @Failure		400			{object}	domainErrors.Problem	"请求错误"
+// @Failure		401			{object}	domainErrors.Problem	"未登录"
+// @Failure		403			{object}	domainErrors.Problem	"无权访问该会话"
+// @Failure		500			{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/attachments [post]
+func (ac *AttachmentController) UploadAttachment(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ac.AttachmentService.MaxUploadRequestSize())
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "attachment_too_large"))
+			return
+		}
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "file_required"))
+		return
+	}
+
+	attachment, err := ac.AttachmentService.Upload(c.Request.Context(), middlewares.CurrentUserIDPtr(c), c.PostForm("session_id"), fileHeader)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// @Summary		获取会话附件列表
+// @Description	获取当前用户在指定会话中上传的附件
+// @Produce		json
+// @Param			session_id	query		string					true	"会话ID"
+// @Success		200			{array}		models.Attachment		"成功"
+// @Failure		400			{object}	domainErrors.Problem	"请求错误"
+// @Failure		401			{object}	domainErrors.Problem	"未登录"
+// @Failure		500			{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/attachments [get]
+func (ac *AttachmentController) ListAttachments(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "session_id_required"))
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	attachments, err := ac.AttachmentService.ListBySession(sessionID, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// @Summary		获取附件信息
+// @Description	获取附件的元数据，包括大小、类型和 SHA-256 校验和
+// @Produce		json
+// @Param			id	path		int						true	"附件ID"
+// @Success		200	{object}	models.Attachment		"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		404	{object}	domainErrors.Problem	"附件未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/attachments/{id} [get]
+func (ac *AttachmentController) GetAttachment(c *gin.Context) {
+	id, ok := attachmentID(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	attachment, err := ac.AttachmentService.Get(id, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// @Summary		下载附件
+// @Description	下载附件内容
+// @Produce		octet-stream
+// @Param			id	path		int						true	"附件ID"
+// @Success		200	{file}		file					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		404	{object}	domainErrors.Problem	"附件未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/attachments/{id}/content [get]
+func (ac *AttachmentController) DownloadAttachment(c *gin.Context) {
+	id, ok := attachmentID(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	attachment, err := ac.AttachmentService.Get(id, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if c.GetHeader("If-None-Match") == `"`+attachment.Checksum+`"` {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	content, err := ac.AttachmentService.Open(c.Request.Context(), attachment)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer content.Close()
+
+	// 图片可以内联显示，其他类型一律作为下载处理，避免浏览器执行上传的 HTML 等内容
+	disposition := "attachment"
+	if attachment.IsImage() {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+attachment.Checksum+`"`)
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, io.LimitReader(content, attachment.Size), nil)
+}
+
+// @Summary		删除附件
+// @Description	删除附件及其内容
+// @Produce		json
+// @Param			id	path		int						true	"附件ID"
+// @Success		200	{object}	string					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		404	{object}	domainErrors.Problem	"附件未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/attachments/{id} [delete]
+func (ac *AttachmentController) DeleteAttachment(c *gin.Context) {
+	id, ok := attachmentID(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	if err := ac.AttachmentService.Delete(c.Request.Context(), id, userID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "attachment deleted successfully"})
+}
+
+func attachmentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "attachment_id_invalid"))
+		return 0, false
+	}
+	return uint(id), true
+}
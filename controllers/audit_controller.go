@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type AuditController struct {
+	AuditService *services.AuditService
+}
+
+// @Summary		查询审计日志
+// @Description	按操作、结果、操作者、操作对象、请求ID、IP 和时间范围查询审计日志，按时间倒序返回，总数通过 X-Total-Count 响应头返回，需要 audit:read 权限。
+// @Description	审计日志记录用户的创建、修改、删除和停用，登录，角色修改，API 密钥的创建和吊销，分享链接的创建以及启动时发现的配置变化，只能追加，不能修改或删除
+// @Produce		json
+// @Param			action		query		string					false	"操作，例如 user.update"
+// @Param			outcome		query		string					false	"结果"	Enums(success, failure)
+// @Param			actor_id	query		int						false	"操作者用户ID"
+// @Param			target_type	query		string					false	"操作对象类型"	Enums(user, api_key, share_link, config)
+// @Param			target_id	query		string					false	"操作对象ID"
+// @Param			request_id	query		string					false	"请求ID"
+// @Param			ip			query		string					false	"客户端 IP"
+// @Param			from		query		string					false	"开始时间（含），RFC 3339 格式"
+// @Param			to			query		string					false	"结束时间（不含），RFC 3339 格式"
+// @Param			limit		query		int						false	"每页数量，默认50，最大200"
+// @Param			offset		query		int						false	"偏移量"
+// @Success		200			{array}		models.AuditLog			"成功"
+// @Failure		400			{object}	domainErrors.Problem	"请求错误"
+// @Failure		401			{object}	domainErrors.Problem	"未登录"
+// @Failure		403			{object}	domainErrors.Problem	"无权访问"
+// @Failure		500			{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/audit-logs [get]
+func (ac *AuditController) ListAuditLogs(c *gin.Context) {
+	var query models.AuditLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	entries, total, err := ac.AuditService.List(c.Request.Context(), query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditLog{}
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, entries)
+}
@@ -1,57 +1,81 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
 	"github.com/thoulee21/go-learn/models"
 	"github.com/thoulee21/go-learn/services"
 	"gorm.io/gorm"
 )
 
 type ChatController struct {
-	DB        *gorm.DB
-	AIService *services.AIService
+	DB                  *gorm.DB
+	AIService           *services.AIService
+	ConversationService *services.ConversationService
+	KnowledgeService    *services.KnowledgeService
+	AttachmentService   *services.AttachmentService
+	ModerationService   *services.ModerationService
+	PIIRedactor         *services.PIIRedactor
 }
 
 //	@Summary		测试AI服务
-//	@Description	测试AI服务是否正常工作
+//	@Description	测试AI服务是否正常工作。每次调用都会请求模型并消耗 token，健康检查请使用 /healthz 和 /readyz
 //	@Produce		json
-//	@Param			msg	query		string	false	"测试消息"
-//	@Success		200	{string}	string	"成功"
-//	@Failure		500	{object}	string	"内部错误"
+//	@Param			msg	query		string					false	"测试消息"
+//	@Success		200	{string}	string					"成功"
+//	@Failure		500	{object}	domainErrors.Problem	"内部错误"
+//	@Failure		502	{object}	domainErrors.Problem	"AI服务错误"
 //	@Router			/test [get]
 func (cc *ChatController) Test(c *gin.Context) {
 	testMessage := c.Query("msg")
 
-	responseText, err := cc.AIService.GenerateResponse(
+	result, err := cc.AIService.GenerateResponse(
 		c.Request.Context(),
 		[]services.ChatMessage{{
 			Role:    "user",
 			Content: testMessage,
 		}})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务错误: " + err.Error()})
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.UpstreamError))
 		return
 	}
 
-	c.JSON(http.StatusOK, responseText)
+	c.JSON(http.StatusOK, result.Content)
 }
 
 //	@Summary		发送聊天消息
-//	@Description	发送消息到AI并获取回复
+//	@Description	发送消息到AI并获取回复。可通过 image_urls 附带图片地址，或以 multipart/form-data 提交并在 images 字段上传图片，attachment_ids 可引用通过 /attachments 上传的文件
 //	@Accept			json
+//	@Accept			mpfd
 //	@Produce		json
-//	@Param			request	body		models.ChatRequest	true	"聊天请求"
-//	@Success		200		{object}	models.ChatResponse	"成功"
-//	@Failure		400		{object}	string				"请求错误"
-//	@Failure		500		{object}	string				"内部错误"
+//	@Param			request			body		models.ChatRequest		true	"聊天请求"
+//	@Param			Cache-Control	header		string					false	"no-cache 跳过回复缓存读取，no-store 完全不使用回复缓存"
+//	@Success		200				{object}	models.ChatResponse		"成功"
+//	@Header			200				{string}	X-Cache					"回复缓存状态：HIT、MISS 或 BYPASS，未启用缓存时不返回"
+//	@Failure		400				{object}	domainErrors.Problem	"请求错误"
+//	@Failure		403				{object}	domainErrors.Problem	"无权访问该会话或 API 密钥没有 chat 权限范围"
+//	@Failure		422				{object}	domainErrors.Problem	"内容被审核策略拦截"
+//	@Failure		500				{object}	domainErrors.Problem	"内部错误"
+//	@Failure		502				{object}	domainErrors.Problem	"AI服务错误"
+//	@Security		BearerAuth
+//	@Security		APIKeyAuth
 //	@Router			/chat [post]
 func (cc *ChatController) Chat(c *gin.Context) {
 	var request models.ChatRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindChatRequest(c, &request, cc.AttachmentService.MaxChatRequestSize()); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
 		return
 	}
 
@@ -60,73 +84,139 @@ func (cc *ChatController) Chat(c *gin.Context) {
 		request.SessionID = uuid.New().String()
 	}
 
+	// 已归属其他用户的会话不能继续发送消息
+	ownerID, err := cc.ConversationService.ResolveSessionOwner(request.SessionID, middlewares.CurrentUserIDPtr(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 审核用户输入，被拦截时不保存消息
+	inputModeration, err := cc.moderateInput(c, &request, ownerID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 校验并保存随消息发送的图片和引用的附件
+	attachments, err := cc.prepareAttachments(c, request, ownerID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
 	// 保存用户消息
 	userMessage := models.ChatMessage{
-		SessionID: request.SessionID,
-		Role:      "user",
-		Content:   request.Message,
+		SessionID:   request.SessionID,
+		UserID:      ownerID,
+		APIKeyID:    middlewares.CurrentAPIKeyID(c),
+		Role:        "user",
+		Content:     request.Message,
+		Attachments: attachments,
 	}
-	if err := cc.DB.Create(&userMessage).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法保存消息"})
+	if err := cc.DB.WithContext(c.Request.Context()).Session(&gorm.Session{FullSaveAssociations: true}).Create(&userMessage).Error; err != nil {
+		_ = c.Error(domainErrors.TranslateDBError(err))
 		return
 	}
+	cc.ModerationService.Record(c.Request.Context(), models.ModerationStageInput, request.SessionID, ownerID, &userMessage.ID, inputModeration)
 
-	// 获取历史消息（最多10条）
-	var chatHistory []models.ChatMessage
-	cc.DB.Where("session_id = ?", request.SessionID).Order("created_at desc").Limit(10).Find(&chatHistory)
+	// 开启脱敏时，发送给服务商的内容中的个人信息会被替换为占位符
+	cc.withPIIRedaction(c)
+	withToolCaller(c)
 
-	// 构造OpenAI消息格式
-	var openAIMessages []services.ChatMessage
-	for i := len(chatHistory) - 1; i >= 0; i-- {
-		openAIMessages = append(openAIMessages, services.ChatMessage{
-			Role:    chatHistory[i].Role,
-			Content: chatHistory[i].Content,
-		})
+	// 获取历史消息并构造OpenAI消息格式
+	openAIMessages := cc.loadHistory(c.Request.Context(), request.SessionID)
+
+	// 需要时从知识库检索相关片段作为上下文
+	citations, openAIMessages, err := cc.withKnowledge(c, request, openAIMessages)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	// 调用AI服务
-	responseText, err := cc.AIService.GenerateResponse(c.Request.Context(), openAIMessages)
+	// 调用AI服务，请求头 Cache-Control: no-cache 跳过缓存读取，no-store 完全不使用缓存
+	result, err := cc.AIService.GenerateResponse(withCacheControl(c), openAIMessages)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务错误: " + err.Error()})
+		if filterErr := cc.contentFilterError(c.Request.Context(), err, userMessage); filterErr != nil {
+			_ = c.Error(filterErr)
+			return
+		}
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.UpstreamError))
+		return
+	}
+	middlewares.AddTokenUsage(c, result.PromptTokens+result.CompletionTokens)
+
+	// 保存工具调用过程
+	if err := cc.saveToolMessages(c.Request.Context(), &userMessage, result.Messages); err != nil {
+		_ = c.Error(domainErrors.TranslateDBError(err))
+		return
+	}
+
+	// 审核AI回复
+	outputModeration := cc.ModerationService.Merge(
+		cc.ModerationService.ProviderResult(result.Content, nil, result.FilterDetections),
+		cc.ModerationService.Check(c.Request.Context(), models.ModerationStageOutput, result.Content),
+	)
+	if outputModeration.Blocked() {
+		cc.ModerationService.Record(c.Request.Context(), models.ModerationStageOutput, request.SessionID, userMessage.UserID, nil, outputModeration)
+		_ = c.Error(services.BlockedError(outputModeration))
 		return
 	}
 
 	// 保存AI回复
 	aiMessage := models.ChatMessage{
 		SessionID: request.SessionID,
+		UserID:    userMessage.UserID,
+		APIKeyID:  userMessage.APIKeyID,
 		Role:      "assistant",
-		Content:   responseText,
+		Content:   outputModeration.Text,
 	}
-	if err := cc.DB.Create(&aiMessage).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法保存AI回复"})
+	if err := cc.DB.WithContext(c.Request.Context()).Create(&aiMessage).Error; err != nil {
+		_ = c.Error(domainErrors.TranslateDBError(err))
 		return
 	}
+	cc.ModerationService.Record(c.Request.Context(), models.ModerationStageOutput, request.SessionID, userMessage.UserID, &aiMessage.ID, outputModeration)
 
 	// 返回响应
+	if result.CacheStatus != "" {
+		c.Header("X-Cache", result.CacheStatus)
+	}
+	if result.CacheStatus == services.CacheHit {
+		c.Header("Age", strconv.Itoa(int(time.Since(result.CachedAt).Seconds())))
+	}
 	c.JSON(http.StatusOK, models.ChatResponse{
 		SessionID: request.SessionID,
-		Message:   responseText,
+		Message:   aiMessage.Content,
+		Citations: citations,
 	})
 }
 
 //	@Summary		获取聊天历史
 //	@Description	获取特定会话的聊天历史
 //	@Produce		json
-//	@Param			session_id	path		string				true	"会话ID"
-//	@Success		200			{array}		models.ChatMessage	"成功"
-//	@Failure		400			{object}	string				"请求错误"
-//	@Failure		500			{object}	string				"内部错误"
+//	@Param			session_id	path		string					true	"会话ID"
+//	@Success		200			{array}		models.ChatMessage		"成功"
+//	@Failure		400			{object}	domainErrors.Problem	"请求错误"
+//	@Failure		404			{object}	domainErrors.Problem	"会话未找到"
+//	@Failure		500			{object}	domainErrors.Problem	"内部错误"
+//	@Security		BearerAuth
 //	@Router			/chat/history/{session_id} [get]
 func (cc *ChatController) GetChatHistory(c *gin.Context) {
 	sessionID := c.Param("session_id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "会话ID不能为空"})
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "session_id_required"))
 		return
 	}
 
 	var messages []models.ChatMessage
-	if err := cc.DB.Where("session_id = ?", sessionID).Order("created_at asc").Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := cc.DB.WithContext(c.Request.Context()).Preload("Attachments").Where("session_id = ?", sessionID).Order("created_at asc").Find(&messages).Error; err != nil {
+		_ = c.Error(domainErrors.TranslateDBError(err))
+		return
+	}
+
+	// 已归属用户的会话仅对所有者可见
+	if !services.CanAccessSession(services.SessionOwner(messages), middlewares.CurrentUserIDPtr(c)) {
+		_ = c.Error(domainErrors.NewAppErrorWithType(domainErrors.NotFound))
 		return
 	}
 
@@ -134,18 +224,23 @@ func (cc *ChatController) GetChatHistory(c *gin.Context) {
 }
 
 //	@Summary		流式发送聊天消息
-//	@Description	流式发送消息到AI并获取实时回复
+//	@Description	流式发送消息到AI并获取实时回复。可通过 image_urls 附带图片地址，或以 multipart/form-data 提交并在 images 字段上传图片，attachment_ids 可引用通过 /attachments 上传的文件。开始输出后发生的错误以 error 事件发送，数据为 problem details
 //	@Accept			json
+//	@Accept			mpfd
 //	@Produce		text/event-stream
-//	@Param			request	body		models.ChatRequest	true	"聊天请求"
-//	@Success		200		{object}	string				"成功"
-//	@Failure		400		{object}	string				"请求错误"
-//	@Failure		500		{object}	string				"内部错误"
+//	@Param			request	body		models.ChatRequest		true	"聊天请求"
+//	@Success		200		{object}	string					"成功"
+//	@Failure		400		{object}	domainErrors.Problem	"请求错误"
+//	@Failure		403		{object}	domainErrors.Problem	"无权访问该会话或 API 密钥没有 chat 权限范围"
+//	@Failure		422		{object}	domainErrors.Problem	"内容被审核策略拦截"
+//	@Failure		500		{object}	domainErrors.Problem	"内部错误"
+//	@Security		BearerAuth
+//	@Security		APIKeyAuth
 //	@Router			/chat/stream [post]
 func (cc *ChatController) StreamChat(c *gin.Context) {
 	var request models.ChatRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindChatRequest(c, &request, cc.AttachmentService.MaxChatRequestSize()); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
 		return
 	}
 
@@ -154,28 +249,54 @@ func (cc *ChatController) StreamChat(c *gin.Context) {
 		request.SessionID = uuid.New().String()
 	}
 
+	// 已归属其他用户的会话不能继续发送消息
+	ownerID, err := cc.ConversationService.ResolveSessionOwner(request.SessionID, middlewares.CurrentUserIDPtr(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 审核用户输入，被拦截时不保存消息
+	inputModeration, err := cc.moderateInput(c, &request, ownerID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 校验并保存随消息发送的图片和引用的附件
+	attachments, err := cc.prepareAttachments(c, request, ownerID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
 	// 保存用户消息
 	userMessage := models.ChatMessage{
-		SessionID: request.SessionID,
-		Role:      "user",
-		Content:   request.Message,
+		SessionID:   request.SessionID,
+		UserID:      ownerID,
+		APIKeyID:    middlewares.CurrentAPIKeyID(c),
+		Role:        "user",
+		Content:     request.Message,
+		Attachments: attachments,
 	}
-	if err := cc.DB.Create(&userMessage).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法保存消息"})
+	if err := cc.DB.WithContext(c.Request.Context()).Session(&gorm.Session{FullSaveAssociations: true}).Create(&userMessage).Error; err != nil {
+		_ = c.Error(domainErrors.TranslateDBError(err))
 		return
 	}
+	cc.ModerationService.Record(c.Request.Context(), models.ModerationStageInput, request.SessionID, ownerID, &userMessage.ID, inputModeration)
 
-	// 获取历史消息（最多10条）
-	var chatHistory []models.ChatMessage
-	cc.DB.Where("session_id = ?", request.SessionID).Order("created_at desc").Limit(10).Find(&chatHistory)
+	// 开启脱敏时，发送给服务商的内容中的个人信息会被替换为占位符
+	cc.withPIIRedaction(c)
+	withToolCaller(c)
 
-	// 构造OpenAI消息格式
-	var openAIMessages []services.ChatMessage
-	for i := len(chatHistory) - 1; i >= 0; i-- {
-		openAIMessages = append(openAIMessages, services.ChatMessage{
-			Role:    chatHistory[i].Role,
-			Content: chatHistory[i].Content,
-		})
+	// 获取历史消息并构造OpenAI消息格式
+	openAIMessages := cc.loadHistory(c.Request.Context(), request.SessionID)
+
+	// 需要时从知识库检索相关片段作为上下文
+	citations, openAIMessages, err := cc.withKnowledge(c, request, openAIMessages)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
 	// 设置SSE响应头
@@ -183,36 +304,270 @@ func (cc *ChatController) StreamChat(c *gin.Context) {
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
 	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+	// 流式回复的时长不受服务器写超时限制，服务关闭时通过请求的 context 中断
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
 
-	// 保存完整响应用于数据库存储
-	fullResponse := ""
+	// 回复经过审核后再发送，命中拦截规则时取消生成
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	moderator := cc.ModerationService.NewStreamModerator(models.ModerationStageOutput)
 
-	// 创建回调函数处理流式响应
-	callback := func(chunk string) {
+	send := func(chunk string) {
+		if chunk == "" {
+			return
+		}
 		// 发送数据块
 		c.Writer.Write([]byte("data: " + chunk + "\n\n"))
 		c.Writer.Flush()
-		fullResponse += chunk
+	}
+
+	// 创建回调函数处理流式响应
+	callback := func(chunk string) {
+		out, blocked := moderator.Write(ctx, chunk)
+		if blocked {
+			cancel()
+			return
+		}
+		send(out)
+	}
+
+	// 调用工具前输出的文本已保存在调用工具的 assistant 消息中，不再计入最终回复
+	onToolRound := func() {
+		out, blocked := moderator.EndRound(ctx)
+		if blocked {
+			cancel()
+			return
+		}
+		send(out)
 	}
 
 	// 调用AI服务的流式响应方法
-	err := cc.AIService.GenerateStreamResponse(c.Request.Context(), openAIMessages, callback)
+	result, err := cc.AIService.GenerateStreamResponse(ctx, openAIMessages, callback, onToolRound)
+	if result != nil {
+		middlewares.AddTokenUsage(c, result.PromptTokens+result.CompletionTokens)
+	}
+	// 请求的 context 被取消（服务关闭或客户端断开）时生成中断，已经生成的部分仍然发送并保存。
+	// 之后的数据库操作不能使用已取消的 context
+	interrupted := err != nil && c.Request.Context().Err() != nil
+	saveCtx := context.WithoutCancel(c.Request.Context())
+	if err == nil || interrupted {
+		if out, blocked := moderator.Flush(saveCtx); !blocked {
+			send(out)
+		}
+	}
+	if moderator.Result().Blocked() {
+		cc.ModerationService.Record(ctx, models.ModerationStageOutput, request.SessionID, userMessage.UserID, nil, moderator.Result())
+		writeStreamError(c, services.BlockedError(moderator.Result()))
+		return
+	}
+	if interrupted {
+		if moderator.Result().Text != "" {
+			aiMessage := models.ChatMessage{
+				SessionID:   request.SessionID,
+				UserID:      userMessage.UserID,
+				APIKeyID:    userMessage.APIKeyID,
+				Role:        "assistant",
+				Content:     moderator.Result().Text,
+				Interrupted: true,
+			}
+			if cc.DB.WithContext(saveCtx).Create(&aiMessage).Error == nil {
+				cc.ModerationService.Record(ctx, models.ModerationStageOutput, request.SessionID, userMessage.UserID, &aiMessage.ID, moderator.Result())
+			}
+		}
+		writeStreamError(c, domainErrors.NewAppError(err, domainErrors.ServiceUnavailable))
+		return
+	}
 	if err != nil {
+		if filterErr := cc.contentFilterError(ctx, err, userMessage); filterErr != nil {
+			err = filterErr
+		} else {
+			err = domainErrors.NewAppError(err, domainErrors.UpstreamError)
+		}
 		// 尝试发送错误消息，但此时可能连接已关闭
-		c.Writer.Write([]byte("data: {\"error\": \"" + err.Error() + "\"}\n\n"))
-		c.Writer.Flush()
+		writeStreamError(c, err)
 		return
 	}
 
+	// 发送引用来源
+	if len(citations) > 0 {
+		payload, _ := json.Marshal(gin.H{"citations": citations})
+		c.Writer.Write([]byte("event: citations\ndata: " + string(payload) + "\n\n"))
+	}
+
 	// 发送结束信号
 	c.Writer.Write([]byte("data: [DONE]\n\n"))
 	c.Writer.Flush()
 
-	// 保存AI回复到数据库
+	// 保存工具调用过程和AI回复到数据库
+	_ = cc.saveToolMessages(saveCtx, &userMessage, result.Messages)
+	outputModeration := cc.ModerationService.Merge(
+		cc.ModerationService.ProviderResult(result.Content, nil, result.FilterDetections),
+		moderator.Result(),
+	)
 	aiMessage := models.ChatMessage{
 		SessionID: request.SessionID,
+		UserID:    userMessage.UserID,
+		APIKeyID:  userMessage.APIKeyID,
 		Role:      "assistant",
-		Content:   fullResponse,
+		Content:   outputModeration.Text,
+	}
+	if cc.DB.WithContext(saveCtx).Create(&aiMessage).Error == nil {
+		cc.ModerationService.Record(ctx, models.ModerationStageOutput, request.SessionID, userMessage.UserID, &aiMessage.ID, outputModeration)
+	}
+}
+
+// loadHistory 获取会话最近的历史消息（最多10条）并转换为AI服务的消息格式
+func (cc *ChatController) loadHistory(ctx context.Context, sessionID string) []services.ChatMessage {
+	var chatHistory []models.ChatMessage
+	cc.DB.WithContext(ctx).Preload("Attachments").Where("session_id = ?", sessionID).Order("created_at desc, id desc").Limit(10).Find(&chatHistory)
+
+	var openAIMessages []services.ChatMessage
+	for i := len(chatHistory) - 1; i >= 0; i-- {
+		// 截断后位于开头的 tool 消息缺少对应的工具调用，不能发送给模型
+		if len(openAIMessages) == 0 && chatHistory[i].Role == "tool" {
+			continue
+		}
+		openAIMessages = append(openAIMessages, services.ChatMessage{
+			Role:       chatHistory[i].Role,
+			Content:    chatHistory[i].Content,
+			ToolCalls:  chatHistory[i].ToolCalls,
+			ToolCallID: chatHistory[i].ToolCallID,
+			Images:     cc.AttachmentService.LoadImages(ctx, chatHistory[i].Attachments),
+		})
+	}
+	return openAIMessages
+}
+
+// bindChatRequest 解析聊天请求，multipart 请求按表单解析，其余按 JSON 解析
+func bindChatRequest(c *gin.Context, request *models.ChatRequest, maxSize int64) error {
+	// 在解析 multipart 之前限制请求体，避免过大的请求被写入临时文件
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	if c.ContentType() == binding.MIMEMultipartPOSTForm {
+		return c.ShouldBindWith(request, binding.FormMultipart)
+	}
+	return c.ShouldBindJSON(request)
+}
+
+// prepareAttachments 处理 multipart 请求中上传的图片、图片地址以及引用的已上传附件
+func (cc *ChatController) prepareAttachments(c *gin.Context, request models.ChatRequest, ownerID *uint) ([]models.Attachment, error) {
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["images"]
+	}
+	if len(files) == 0 && len(request.ImageURLs) == 0 && len(request.AttachmentIDs) == 0 {
+		return nil, nil
+	}
+	return cc.AttachmentService.PrepareAttachments(
+		c.Request.Context(), ownerID, request.SessionID, files, request.ImageURLs, request.AttachmentIDs,
+	)
+}
+
+// withPIIRedaction 为本次请求创建占位符映射，保存的消息仍使用原始内容
+func (cc *ChatController) withPIIRedaction(c *gin.Context) {
+	if cc.PIIRedactor == nil {
+		return
+	}
+	c.Request = c.Request.WithContext(services.WithPIIVault(c.Request.Context(), cc.PIIRedactor.NewVault()))
+}
+
+// withToolCaller 记录发起对话的用户，工具只能查询该用户的数据；匿名请求不提供需要用户身份的工具
+func withToolCaller(c *gin.Context) {
+	if userID, ok := middlewares.CurrentUserID(c); ok {
+		c.Request = c.Request.WithContext(services.WithToolCaller(c.Request.Context(), userID))
+	}
+}
+
+// withCacheControl 根据请求头 Cache-Control 设置本次请求的回复缓存选项
+func withCacheControl(c *gin.Context) context.Context {
+	var control services.CacheControl
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache":
+			control.NoRead = true
+		case "no-store":
+			control.NoStore = true
+		}
+	}
+	return services.WithCacheControl(c.Request.Context(), control)
+}
+
+// withKnowledge 在请求开启知识库时检索相关片段，并把包含片段的系统消息放在历史消息之前
+func (cc *ChatController) withKnowledge(c *gin.Context, request models.ChatRequest, messages []services.ChatMessage) ([]models.Citation, []services.ChatMessage, error) {
+	if !request.UseKnowledgeBase {
+		return nil, messages, nil
+	}
+	if cc.KnowledgeService == nil {
+		return nil, nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "knowledge_not_configured")
+	}
+
+	citations, systemMessage, err := cc.KnowledgeService.Retrieve(c.Request.Context(), request.Message, request.TopK)
+	if err != nil {
+		return nil, nil, err
+	}
+	if systemMessage == nil {
+		return nil, messages, nil
+	}
+	return citations, append([]services.ChatMessage{*systemMessage}, messages...), nil
+}
+
+// moderateInput 审核用户输入，需要脱敏时替换请求中的消息。
+// 被拦截的输入直接记录，其余结果在消息保存后由调用方记录。
+func (cc *ChatController) moderateInput(c *gin.Context, request *models.ChatRequest, ownerID *uint) (*services.ModerationResult, error) {
+	result := cc.ModerationService.Check(c.Request.Context(), models.ModerationStageInput, request.Message)
+	if result.Blocked() {
+		cc.ModerationService.Record(c.Request.Context(), models.ModerationStageInput, request.SessionID, ownerID, nil, result)
+		return nil, services.BlockedError(result)
+	}
+	request.Message = result.Text
+	return result, nil
+}
+
+// contentFilterError 把服务商内容过滤的拒绝转换为 ContentFiltered 错误并记录，其他错误返回 nil。
+// 输入被拒绝时删除刚保存的用户消息，避免后续请求携带同样的内容再次被拒绝。
+func (cc *ChatController) contentFilterError(ctx context.Context, err error, userMessage models.ChatMessage) error {
+	var filterErr *services.ContentFilterError
+	if !errors.As(err, &filterErr) {
+		return nil
+	}
+
+	if filterErr.Stage == models.ModerationStageInput {
+		_ = cc.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Attachment{}).Where("message_id = ?", userMessage.ID).Update("message_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&userMessage).Error
+		})
+	}
+	result := cc.ModerationService.ProviderResult("", filterErr, nil)
+	cc.ModerationService.Record(ctx, filterErr.Stage, userMessage.SessionID, userMessage.UserID, nil, result)
+	return services.BlockedError(result)
+}
+
+// writeStreamError 以 error 事件发送错误，内容与 ErrorHandler 返回的 problem details 一致。
+// 此时响应已经开始，错误只记录在 c.Errors 中用于日志，不再由 ErrorHandler 输出
+func writeStreamError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	payload, _ := json.Marshal(middlewares.NewProblem(c, err))
+	c.Writer.Write([]byte("event: error\ndata: " + string(payload) + "\n\n"))
+	c.Writer.Flush()
+}
+
+// saveToolMessages 保存工具调用过程中产生的 assistant/tool 消息，会话、用户和 API 密钥与用户消息相同
+func (cc *ChatController) saveToolMessages(ctx context.Context, userMessage *models.ChatMessage, messages []services.ChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	records := make([]models.ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		records = append(records, models.ChatMessage{
+			SessionID:  userMessage.SessionID,
+			UserID:     userMessage.UserID,
+			APIKeyID:   userMessage.APIKeyID,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
 	}
-	cc.DB.Create(&aiMessage)
+	return cc.DB.WithContext(ctx).Create(&records).Error
 }
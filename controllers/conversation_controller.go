@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+// maxImportSize 限制导入文件的大小
+const maxImportSize = 32 << 20
+
+type ConversationController struct {
+	ConversationService *services.ConversationService
+}
+
+// @Summary		导出会话
+// @Description	将单个会话导出为 JSON、Markdown 或 OpenAI 微调 JSONL 格式
+// @Produce		json
+// @Produce		text/markdown
+// @Produce		application/jsonl
+// @Param			session_id	path		string					true	"会话ID"
+// @Param			format		query		string					false	"导出格式"	Enums(json, markdown, jsonl)	default(json)
+// @Success		200			{object}	models.Conversation		"成功"
+// @Failure		400			{object}	domainErrors.Problem	"请求错误"
+// @Failure		404			{object}	domainErrors.Problem	"会话未找到"
+// @Failure		500			{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/export/{session_id} [get]
+func (cc *ConversationController) ExportSession(c *gin.Context) {
+	format := c.DefaultQuery("format", services.FormatJSON)
+	if _, _, err := services.FormatContentType(format); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	sessionID := c.Param("session_id")
+	conversation, err := cc.ConversationService.GetConversation(sessionID, middlewares.CurrentUserIDPtr(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	writeExport(c, format, "conversation-"+sessionID, []models.Conversation{*conversation}, true)
+}
+
+// @Summary		导出全部会话
+// @Description	将当前用户的全部会话导出为 JSON、Markdown 或 OpenAI 微调 JSONL 格式
+// @Produce		json
+// @Produce		text/markdown
+// @Produce		application/jsonl
+// @Param			format	query		string					false	"导出格式"	Enums(json, markdown, jsonl)	default(json)
+// @Success		200		{array}		models.Conversation		"成功"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Failure		401		{object}	domainErrors.Problem	"未登录"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/export [get]
+func (cc *ConversationController) ExportAllSessions(c *gin.Context) {
+	format := c.DefaultQuery("format", services.FormatJSON)
+	if _, _, err := services.FormatContentType(format); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	conversations, err := cc.ConversationService.GetUserConversations(userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("conversations-%s", time.Now().Format("20060102-150405"))
+	writeExport(c, format, filename, conversations, false)
+}
+
+// @Summary		导入会话
+// @Description	从 JSON、Markdown、OpenAI 微调 JSONL 或 ChatGPT 导出文件（conversations.json 或 zip 压缩包）导入会话，每个会话都会创建新的会话ID
+// @Accept			multipart/form-data
+// @Accept			json
+// @Produce		json
+// @Param			format	query		string					true	"导入格式"	Enums(json, markdown, jsonl, chatgpt)
+// @Param			file	formData	file					false	"导入文件，也可以直接作为请求体上传"
+// @Success		200		{object}	models.ImportResponse	"成功"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Failure		401		{object}	domainErrors.Problem	"未登录"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/import [post]
+func (cc *ConversationController) ImportSessions(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "format_required"))
+		return
+	}
+
+	data, err := readImportData(c)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	conversations, err := services.ParseConversations(data, format)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	response, err := cc.ConversationService.ImportConversations(userID, conversations)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// readImportData 读取 multipart 中的 file 字段，否则读取整个请求体
+func readImportData(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	if c.ContentType() == "multipart/form-data" {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+	return data, nil
+}
+
+func writeExport(c *gin.Context, format, filename string, conversations []models.Conversation, single bool) {
+	contentType, ext, _ := services.FormatContentType(format)
+
+	var buf bytes.Buffer
+	if err := services.WriteConversations(&buf, format, conversations, single); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.UnknownError))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, ext))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type EmbeddingController struct {
+	AIService *services.AIService
+}
+
+// @Summary		计算文本向量
+// @Description	计算一条或多条文本的向量，输入较多时会分批请求模型，响应格式与 OpenAI embeddings 接口一致
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.EmbeddingRequest		true	"向量请求"
+// @Success		200		{object}	models.EmbeddingResponse	"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Failure		502		{object}	domainErrors.Problem		"AI服务错误"
+// @Security		BearerAuth
+// @Security		APIKeyAuth
+// @Router			/embeddings [post]
+func (ec *EmbeddingController) CreateEmbeddings(c *gin.Context) {
+	var request models.EmbeddingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	if !ec.AIService.EmbeddingsEnabled() {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "embeddings_not_configured"))
+		return
+	}
+	if err := ec.AIService.ValidateEmbeddingInput(request.Input); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	result, err := ec.AIService.Embed(c.Request.Context(), request.Input, request.Dimensions)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.UpstreamError))
+		return
+	}
+	middlewares.AddTokenUsage(c, result.TotalTokens)
+
+	data := make([]models.EmbeddingData, 0, len(result.Vectors))
+	for i, vector := range result.Vectors {
+		data = append(data, models.EmbeddingData{Object: "embedding", Index: i, Embedding: vector})
+	}
+	c.JSON(http.StatusOK, models.EmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  result.Model,
+		Usage: models.EmbeddingUsage{
+			PromptTokens: result.PromptTokens,
+			TotalTokens:  result.TotalTokens,
+		},
+	})
+}
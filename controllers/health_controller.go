@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type HealthController struct {
+	HealthService *services.HealthService
+}
+
+// @Summary		存活检查
+// @Description	进程能够处理请求时返回 200，不检查任何依赖，用于存活探针
+// @Produce		json
+// @Success		200	{object}	models.HealthResponse	"成功"
+// @Router			/healthz [get]
+func (hc *HealthController) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// @Summary		就绪检查
+// @Description	检查数据库连接，开启 HEALTH_LLM_PROBE 时还会检查模型服务商（结果会缓存），返回每个依赖的状态和耗时。数据库不可用时返回 503，仅服务商不可用时返回 200 且状态为 degraded
+// @Produce		json
+// @Success		200	{object}	models.HealthResponse	"就绪"
+// @Failure		503	{object}	models.HealthResponse	"未就绪"
+// @Router			/readyz [get]
+func (hc *HealthController) Readiness(c *gin.Context) {
+	health := hc.HealthService.Readiness(c.Request.Context())
+	status := http.StatusOK
+	if health.Status == models.HealthStatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+}
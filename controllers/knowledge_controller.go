@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+// maxDocumentSize 限制上传文档的大小
+const maxDocumentSize = 20 << 20
+
+type KnowledgeController struct {
+	KnowledgeService *services.KnowledgeService
+}
+
+// @Summary		上传知识库文档
+// @Description	上传文本、Markdown、HTML 或 PDF 文档，文档会被切分并计算向量后加入知识库
+// @Accept			multipart/form-data
+// @Produce		json
+// @Param			file	formData	file						true	"文档文件"
+// @Success		200		{object}	models.KnowledgeDocument	"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		403		{object}	domainErrors.Problem		"没有 kb:manage 权限"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Failure		502		{object}	domainErrors.Problem		"AI服务错误"
+// @Security		BearerAuth
+// @Router			/kb/documents [post]
+func (kc *KnowledgeController) UploadDocument(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "file_required"))
+		return
+	}
+	if fileHeader.Size > maxDocumentSize {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "document_too_large"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	document, err := kc.KnowledgeService.Upload(c.Request.Context(), middlewares.CurrentUserIDPtr(c), fileHeader.Filename, data)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// @Summary		获取知识库文档列表
+// @Description	获取知识库中的全部文档
+// @Produce		json
+// @Success		200	{array}		models.KnowledgeDocument	"成功"
+// @Failure		401	{object}	domainErrors.Problem		"未登录"
+// @Failure		500	{object}	domainErrors.Problem		"内部错误"
+// @Security		BearerAuth
+// @Router			/kb/documents [get]
+func (kc *KnowledgeController) ListDocuments(c *gin.Context) {
+	documents, err := kc.KnowledgeService.List()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, documents)
+}
+
+// @Summary		删除知识库文档
+// @Description	删除文档及其全部片段，删除后不再参与检索
+// @Produce		json
+// @Param			id	path		int						true	"文档ID"
+// @Success		200	{object}	string					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"没有 kb:manage 权限"
+// @Failure		404	{object}	domainErrors.Problem	"文档未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/kb/documents/{id} [delete]
+func (kc *KnowledgeController) DeleteDocument(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "document_id_invalid"))
+		return
+	}
+
+	if err := kc.KnowledgeService.Delete(c.Request.Context(), uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "document deleted successfully"})
+}
+
+// @Summary		检索知识库
+// @Description	返回与查询最相关的文档片段，可用于调试检索效果
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.KnowledgeSearchRequest	true	"检索请求"
+// @Success		200		{array}		models.Citation					"成功"
+// @Failure		400		{object}	domainErrors.Problem			"请求错误"
+// @Failure		401		{object}	domainErrors.Problem			"未登录"
+// @Failure		500		{object}	domainErrors.Problem			"内部错误"
+// @Failure		502		{object}	domainErrors.Problem			"AI服务错误"
+// @Security		BearerAuth
+// @Router			/kb/search [post]
+func (kc *KnowledgeController) Search(c *gin.Context) {
+	var request models.KnowledgeSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	citations, _, err := kc.KnowledgeService.Search(c.Request.Context(), request.Query, request.TopK)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if citations == nil {
+		citations = []models.Citation{}
+	}
+	c.JSON(http.StatusOK, citations)
+}
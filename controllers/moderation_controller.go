@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type ModerationController struct {
+	ModerationService *services.ModerationService
+}
+
+// @Summary		查询审核记录
+// @Description	按会话、用户、阶段、类别、动作和复核状态查询命中审核规则的记录，总数通过 X-Total-Count 响应头返回，需要 moderation:read 权限
+// @Produce		json
+// @Param			session_id	query		string					false	"会话ID"
+// @Param			user_id		query		int						false	"用户ID"
+// @Param			stage		query		string					false	"审核阶段"	Enums(input, output)
+// @Param			category	query		string					false	"类别"
+// @Param			action		query		string					false	"动作"	Enums(flag, redact, block)
+// @Param			reviewed	query		bool					false	"是否已复核"
+// @Param			limit		query		int						false	"每页数量，默认50，最大200"
+// @Param			offset		query		int						false	"偏移量"
+// @Success		200			{array}		models.ModerationFlag	"成功"
+// @Failure		400			{object}	domainErrors.Problem	"请求错误"
+// @Failure		401			{object}	domainErrors.Problem	"未登录"
+// @Failure		403			{object}	domainErrors.Problem	"无权访问"
+// @Failure		500			{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/moderation/flags [get]
+func (mc *ModerationController) ListFlags(c *gin.Context) {
+	var query models.ModerationFlagQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	flags, total, err := mc.ModerationService.ListFlags(query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if flags == nil {
+		flags = []models.ModerationFlag{}
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, flags)
+}
+
+// @Summary		复核审核记录
+// @Description	将审核记录标记为已复核，需要 moderation:review 权限
+// @Produce		json
+// @Param			id	path		int						true	"记录ID"
+// @Success		200	{object}	models.ModerationFlag	"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"记录未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/moderation/flags/{id}/review [post]
+func (mc *ModerationController) ReviewFlag(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "flag_id_invalid"))
+		return
+	}
+	adminID, _ := middlewares.CurrentUserID(c)
+
+	flag, err := mc.ModerationService.ReviewFlag(uint(id), adminID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
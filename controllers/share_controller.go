@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type ShareController struct {
+	ShareService *services.ShareService
+	AuditService *services.AuditService
+}
+
+// @Summary		创建分享链接
+// @Description	为自己的会话创建只读分享链接，分享内容为创建时的会话快照
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.CreateShareLinkRequest	true	"分享请求"
+// @Success		200		{object}	models.ShareLink				"成功"
+// @Failure		400		{object}	domainErrors.Problem			"请求错误"
+// @Failure		401		{object}	domainErrors.Problem			"未登录"
+// @Failure		404		{object}	domainErrors.Problem			"会话未找到"
+// @Failure		500		{object}	domainErrors.Problem			"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/shares [post]
+func (sc *ShareController) CreateShareLink(c *gin.Context) {
+	var request models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	link, err := sc.ShareService.Create(userID, request)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	entry := models.NewAuditLog(models.AuditActionShareCreate, models.AuditTargetShareLink, link.ID)
+	entry.Metadata = models.AuditMetadata{"session_id": link.SessionID, "expires_at": link.ExpiresAt}
+	middlewares.Audit(c, sc.AuditService, entry)
+	c.JSON(http.StatusOK, link)
+}
+
+// @Summary		获取分享链接列表
+// @Description	获取当前用户创建的全部分享链接
+// @Produce		json
+// @Success		200	{array}		models.ShareLink		"成功"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/shares [get]
+func (sc *ShareController) ListShareLinks(c *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(c)
+	links, err := sc.ShareService.List(userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// @Summary		撤销分享链接
+// @Description	撤销分享链接，撤销后公开地址立即失效
+// @Produce		json
+// @Param			id	path		int						true	"分享链接ID"
+// @Success		200	{object}	string					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		404	{object}	domainErrors.Problem	"分享链接未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/chat/shares/{id} [delete]
+func (sc *ShareController) RevokeShareLink(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "share_id_invalid"))
+		return
+	}
+
+	userID, _ := middlewares.CurrentUserID(c)
+	if err := sc.ShareService.Revoke(userID, uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "share link revoked successfully"})
+}
+
+// @Summary		查看分享的会话
+// @Description	公开的只读接口，返回分享创建时的会话快照
+// @Produce		json
+// @Param			token	path		string						true	"分享令牌"
+// @Success		200		{object}	models.SharedConversation	"成功"
+// @Failure		404		{object}	domainErrors.Problem		"分享不存在、已撤销或已过期"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Router			/share/{token} [get]
+func (sc *ShareController) GetSharedConversation(c *gin.Context) {
+	conversation, err := sc.ShareService.GetShared(c.Param("token"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, conversation)
+}
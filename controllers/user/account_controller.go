@@ -0,0 +1,162 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type AccountController struct {
+	AccountService *services.AccountService
+	AuditService   *services.AuditService
+}
+
+// auditUserUpdate 记录通过邮件令牌完成的用户修改，请求本身是匿名的，操作者是令牌所属的用户
+func (ac *AccountController) auditUserUpdate(c *gin.Context, before, after *models.User) {
+	entry := models.NewAuditLog(models.AuditActionUserUpdate, models.AuditTargetUser, after.ID)
+	entry.ActorID = &after.ID
+	entry.Changes = models.UserChanges(before, after)
+	middlewares.Audit(c, ac.AuditService, entry)
+}
+
+// @Summary		发送验证邮件
+// @Description	向当前用户的邮箱重新发送验证邮件，邮件语言由 Accept-Language 决定。同一用户两次发送之间需要间隔 EMAIL_RESEND_INTERVAL_SECONDS 秒，每天最多发送 EMAIL_DAILY_LIMIT 封
+// @Produce		json
+// @Success		202	"已发送"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		409	{object}	domainErrors.Problem	"邮箱已验证"
+// @Failure		429	{object}	domainErrors.Problem	"发送过于频繁"
+// @Failure		502	{object}	domainErrors.Problem	"邮件发送失败"
+// @Security		BearerAuth
+// @Router			/account/verification [post]
+func (ac *AccountController) SendVerification(c *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(c)
+	if err := ac.AccountService.SendVerification(c.Request.Context(), userID, middlewares.Language(c)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// @Summary		验证邮箱
+// @Description	提交验证邮件中的令牌，将邮箱标记为已验证
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.AccountTokenRequest	true	"令牌"
+// @Success		200		{object}	models.UserResponse			"成功"
+// @Failure		400		{object}	domainErrors.Problem		"令牌无效或已过期"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Router			/account/verification/confirm [post]
+func (ac *AccountController) VerifyEmail(c *gin.Context) {
+	var request models.AccountTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	before, user, err := ac.AccountService.VerifyEmail(c.Request.Context(), request.Token)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ac.auditUserUpdate(c, before, user)
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
+}
+
+// @Summary		找回密码
+// @Description	在后台向邮箱发送重置密码邮件。无论邮箱是否已注册、邮件能否发送都返回 202，避免泄露注册信息
+// @Accept			json
+// @Produce		json
+// @Param			request	body	models.ForgotPasswordRequest	true	"邮箱"
+// @Success		202		"已受理"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Router			/account/password/forgot [post]
+func (ac *AccountController) ForgotPassword(c *gin.Context) {
+	var request models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	ac.AccountService.RequestPasswordReset(c.Request.Context(), request.Email, middlewares.Language(c))
+	c.Status(http.StatusAccepted)
+}
+
+// @Summary		重置密码
+// @Description	提交重置密码邮件中的令牌和新密码。令牌只能使用一次，重置前签发的访问令牌全部失效
+// @Accept			json
+// @Produce		json
+// @Param			request	body	models.ResetPasswordRequest	true	"令牌和新密码"
+// @Success		204		"成功"
+// @Failure		400		{object}	domainErrors.Problem	"令牌无效或已过期"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Router			/account/password/reset [post]
+func (ac *AccountController) ResetPassword(c *gin.Context) {
+	var request models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	before, user, err := ac.AccountService.ResetPassword(c.Request.Context(), request.Token, request.NewPassword)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ac.auditUserUpdate(c, before, user)
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary		修改邮箱
+// @Description	校验当前密码后向新邮箱发送确认邮件，确认后才会修改邮箱
+// @Accept			json
+// @Produce		json
+// @Param			request	body	models.ChangeEmailRequest	true	"新邮箱和当前密码"
+// @Success		202		"已发送"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误或密码错误"
+// @Failure		401		{object}	domainErrors.Problem	"未登录"
+// @Failure		409		{object}	domainErrors.Problem	"邮箱已被使用"
+// @Failure		429		{object}	domainErrors.Problem	"发送过于频繁"
+// @Failure		502		{object}	domainErrors.Problem	"邮件发送失败"
+// @Security		BearerAuth
+// @Router			/account/email [post]
+func (ac *AccountController) ChangeEmail(c *gin.Context) {
+	var request models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	userID, _ := middlewares.CurrentUserID(c)
+	err := ac.AccountService.RequestEmailChange(c.Request.Context(), userID, request.NewEmail, request.Password, middlewares.Language(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// @Summary		确认修改邮箱
+// @Description	提交确认邮件中的令牌，将邮箱修改为新邮箱
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.AccountTokenRequest	true	"令牌"
+// @Success		200		{object}	models.UserResponse			"成功"
+// @Failure		400		{object}	domainErrors.Problem		"令牌无效或已过期"
+// @Failure		409		{object}	domainErrors.Problem		"邮箱已被使用"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Router			/account/email/confirm [post]
+func (ac *AccountController) ConfirmEmailChange(c *gin.Context) {
+	var request models.AccountTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	before, user, err := ac.AccountService.ConfirmEmailChange(c.Request.Context(), request.Token)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	ac.auditUserUpdate(c, before, user)
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
+}
@@ -0,0 +1,173 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type APIKeyController struct {
+	APIKeyService *services.APIKeyService
+	AuditService  *services.AuditService
+}
+
+// @Summary		创建 API 密钥
+// @Description	为当前用户创建 API 密钥，供后端服务以该用户的身份调用 scopes 中的接口：chat 对应 /chat 和 /chat/stream，embeddings 对应 /embeddings。完整密钥只在响应中返回一次，请求时放在 X-API-Key 请求头或 Authorization: Bearer 中
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.CreateAPIKeyRequest	true	"密钥名称、权限范围和有效期"
+// @Success		201		{object}	models.CreateAPIKeyResponse	"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		409		{object}	domainErrors.Problem		"有效密钥数量已达上限"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Security		BearerAuth
+// @Router			/api-keys [post]
+func (kc *APIKeyController) CreateAPIKey(c *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(c)
+	kc.create(c, userID)
+}
+
+// @Summary		获取 API 密钥列表
+// @Description	获取当前用户的全部 API 密钥，包括已吊销和已过期的密钥，不包含完整密钥
+// @Produce		json
+// @Success		200	{array}		models.APIKey			"成功"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/api-keys [get]
+func (kc *APIKeyController) ListAPIKeys(c *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(c)
+	keys, err := kc.APIKeyService.List(c.Request.Context(), &userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary		吊销 API 密钥
+// @Description	吊销当前用户的 API 密钥，吊销后立即失效
+// @Produce		json
+// @Param			id	path		int						true	"密钥ID"
+// @Success		200	{object}	models.APIKey			"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		404	{object}	domainErrors.Problem	"密钥不存在"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/api-keys/{id} [delete]
+func (kc *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	userID, _ := middlewares.CurrentUserID(c)
+	kc.revoke(c, &userID)
+}
+
+// @Summary		获取全部 API 密钥
+// @Description	获取所有用户的 API 密钥，可按用户筛选，需要 api_keys:manage 权限
+// @Produce		json
+// @Param			user_id	query		int						false	"用户ID"
+// @Success		200		{array}		models.APIKey			"成功"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Failure		401		{object}	domainErrors.Problem	"未登录"
+// @Failure		403		{object}	domainErrors.Problem	"无权访问"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/api-keys [get]
+func (kc *APIKeyController) AdminListAPIKeys(c *gin.Context) {
+	var userID *uint
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+			return
+		}
+		uid := uint(id)
+		userID = &uid
+	}
+	keys, err := kc.APIKeyService.List(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary		为用户创建 API 密钥
+// @Description	为指定用户创建 API 密钥，需要 api_keys:manage 权限。完整密钥只在响应中返回一次
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int							true	"用户ID"
+// @Param			request	body		models.CreateAPIKeyRequest	true	"密钥名称、权限范围和有效期"
+// @Success		201		{object}	models.CreateAPIKeyResponse	"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		403		{object}	domainErrors.Problem		"无权访问"
+// @Failure		404		{object}	domainErrors.Problem		"用户未找到"
+// @Failure		409		{object}	domainErrors.Problem		"有效密钥数量已达上限"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/{id}/api-keys [post]
+func (kc *APIKeyController) AdminCreateAPIKey(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	kc.create(c, uint(userID))
+}
+
+// @Summary		吊销任意 API 密钥
+// @Description	吊销任意用户的 API 密钥，需要 api_keys:manage 权限
+// @Produce		json
+// @Param			id	path		int						true	"密钥ID"
+// @Success		200	{object}	models.APIKey			"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"密钥不存在"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/api-keys/{id} [delete]
+func (kc *APIKeyController) AdminRevokeAPIKey(c *gin.Context) {
+	kc.revoke(c, nil)
+}
+
+func (kc *APIKeyController) create(c *gin.Context, userID uint) {
+	var request models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	created, err := kc.APIKeyService.Create(c.Request.Context(), userID, request)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	entry := models.NewAuditLog(models.AuditActionAPIKeyCreate, models.AuditTargetAPIKey, created.ID)
+	entry.Metadata = models.AuditMetadata{"user_id": created.UserID, "prefix": created.Prefix, "scopes": created.Scopes}
+	middlewares.Audit(c, kc.AuditService, entry)
+	c.JSON(http.StatusCreated, created)
+}
+
+// revoke 吊销路径参数 id 对应的密钥，userID 不为 nil 时只能吊销该用户的密钥
+func (kc *APIKeyController) revoke(c *gin.Context, userID *uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "api_key_id_invalid"))
+		return
+	}
+	apiKey, err := kc.APIKeyService.Revoke(c.Request.Context(), uint(id), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	entry := models.NewAuditLog(models.AuditActionAPIKeyRevoke, models.AuditTargetAPIKey, apiKey.ID)
+	entry.Metadata = models.AuditMetadata{"user_id": apiKey.UserID, "prefix": apiKey.Prefix}
+	middlewares.Audit(c, kc.AuditService, entry)
+	c.JSON(http.StatusOK, apiKey)
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+const oidcCookiePath = "/auth/oidc"
+
+type OIDCController struct {
+	OIDCService  *services.OIDCService
+	AuditService *services.AuditService
+}
+
+// @Summary		获取身份提供方列表
+// @Description	获取可用于单点登录的 OpenID Connect 身份提供方，由 OIDC_PROVIDERS 配置
+// @Produce		json
+// @Success		200	{array}	models.OIDCProvider	"成功"
+// @Router			/auth/oidc/providers [get]
+func (oc *OIDCController) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, oc.OIDCService.Providers())
+}
+
+// @Summary		开始单点登录
+// @Description	在浏览器中打开，跳转到身份提供方登录。登录过程使用授权码流程和 PKCE，state、nonce 和 PKCE verifier 保存在 10 分钟内有效的签名 cookie 中
+// @Param			provider	path	string	true	"身份提供方名称"
+// @Success		302			"跳转到身份提供方"
+// @Failure		404			{object}	domainErrors.Problem	"身份提供方不存在"
+// @Failure		503			{object}	domainErrors.Problem	"身份提供方不可用"
+// @Router			/auth/oidc/{provider}/login [get]
+func (oc *OIDCController) Login(c *gin.Context) {
+	authURL, cookie, err := oc.OIDCService.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	oc.setLoginCookie(c, cookie, int(services.OIDCLoginTTL/time.Second))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary		完成单点登录
+// @Description	身份提供方登录后的回调地址。校验 ID 令牌后按外部身份查找用户，首次登录时按已验证的邮箱关联已有用户或自动创建用户，然后签发访问令牌。
+// @Description	配置了 OIDC_POST_LOGIN_URL 时跳转到该地址，访问令牌和过期时间放在 URL fragment 的 token 和 expires_at 中，否则直接返回登录结果
+// @Produce		json
+// @Param			provider	path		string					true	"身份提供方名称"
+// @Param			code		query		string					false	"授权码"
+// @Param			state		query		string					true	"登录时生成的 state"
+// @Param			error		query		string					false	"身份提供方返回的错误"
+// @Success		200			{object}	models.LoginResponse	"成功"
+// @Success		302			"跳转到 OIDC_POST_LOGIN_URL"
+// @Failure		401			{object}	domainErrors.Problem	"登录失败或登录会话已过期"
+// @Failure		403			{object}	domainErrors.Problem	"该身份没有关联账户且未开启自动创建"
+// @Failure		404			{object}	domainErrors.Problem	"身份提供方不存在"
+// @Failure		409			{object}	domainErrors.Problem	"邮箱已被未验证的账户使用"
+// @Failure		503			{object}	domainErrors.Problem	"身份提供方不可用"
+// @Router			/auth/oidc/{provider}/callback [get]
+func (oc *OIDCController) Callback(c *gin.Context) {
+	cookie, _ := c.Cookie(services.OIDCLoginCookie)
+	// 登录 cookie 只能使用一次
+	oc.setLoginCookie(c, "", -1)
+
+	provider := c.Param("provider")
+	if reason := c.Query("error"); reason != "" {
+		// error 参数来自浏览器地址，可能被任意构造，响应中只使用固定的信息
+		err := domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_denied")
+		err.Err = fmt.Errorf("%s: %s", err.Err, services.OAuth2AuthorizationError(reason))
+		oc.auditFailure(c, provider, err)
+		_ = c.Error(err)
+		return
+	}
+	login, err := oc.OIDCService.CompleteLogin(c.Request.Context(), provider, c.Query("code"), c.Query("state"), cookie)
+	if err != nil {
+		oc.auditFailure(c, provider, err)
+		_ = c.Error(err)
+		return
+	}
+
+	response := login.Response
+	if login.Created {
+		entry := models.NewAuditLog(models.AuditActionUserCreate, models.AuditTargetUser, response.User.ID)
+		entry.ActorID = &response.User.ID
+		entry.Metadata = models.AuditMetadata{"user_name": response.User.UserName, "provider": provider}
+		middlewares.Audit(c, oc.AuditService, entry)
+	}
+	entry := models.NewAuditLog(models.AuditActionLogin, models.AuditTargetUser, response.User.ID)
+	entry.ActorID = &response.User.ID
+	entry.Metadata = models.AuditMetadata{"method": "oidc", "provider": provider}
+	if login.Linked {
+		entry.Metadata["identity_linked"] = true
+	}
+	middlewares.Audit(c, oc.AuditService, entry)
+
+	if postLoginURL := oc.OIDCService.PostLoginURL(); postLoginURL != "" {
+		fragment := url.Values{
+			"token":      {response.Token},
+			"expires_at": {response.ExpiresAt.Format(time.RFC3339)},
+		}
+		c.Redirect(http.StatusFound, postLoginURL+"#"+fragment.Encode())
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (oc *OIDCController) auditFailure(c *gin.Context, provider string, err error) {
+	middlewares.Audit(c, oc.AuditService, models.AuditLog{
+		Action:     models.AuditActionLogin,
+		Outcome:    models.AuditOutcomeFailure,
+		TargetType: models.AuditTargetUser,
+		Metadata:   models.AuditMetadata{"method": "oidc", "provider": provider, "reason": err.Error()},
+	})
+}
+
+func (oc *OIDCController) setLoginCookie(c *gin.Context, value string, maxAge int) {
+	// 身份提供方跳转回来是跨站的顶级导航，SameSite=Lax 时浏览器仍会携带 cookie
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OIDCLoginCookie, value, maxAge, oidcCookiePath, "", oc.OIDCService.SecureCookie(), true)
+}
@@ -0,0 +1,132 @@
+package user
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type PrivacyController struct {
+	PrivacyService *services.PrivacyService
+	AuditService   *services.AuditService
+}
+
+// @Summary		请求彻底删除用户数据
+// @Description	用户立即被停用并软删除，会话、消息、附件、分享链接、API 密钥、回复缓存等数据由后台任务删除，审核记录、知识库文档和审计日志被匿名化。
+// @Description	完成后删除请求中保留删除回执，记录各类数据的数量，不包含个人信息。删除其他用户的数据需要 users:delete 权限
+// @Produce		json
+// @Param			id	path		int						true	"用户ID"
+// @Success		202	{object}	models.ErasureRequest	"已受理"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		409	{object}	domainErrors.Problem	"已有待处理的删除请求"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/user/{id}/erasure [post]
+func (pc *PrivacyController) RequestErasure(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	requestedBy, _ := middlewares.CurrentUserID(c)
+	request, err := pc.PrivacyService.RequestErasure(c.Request.Context(), uint(userID), requestedBy)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	entry := models.NewAuditLog(models.AuditActionUserErasure, models.AuditTargetUser, request.UserID)
+	entry.Metadata = models.AuditMetadata{"erasure_request_id": request.ID}
+	middlewares.Audit(c, pc.AuditService, entry)
+	c.JSON(http.StatusAccepted, request)
+}
+
+// @Summary		导出用户数据
+// @Description	下载用户的全部个人数据，zip 中的 data.json 包含用户信息、会话、分享链接、API 密钥等，attachments 目录包含上传的附件。导出其他用户的数据需要 users:read 权限
+// @Produce		application/zip
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{file}		file					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/user/{id}/export [get]
+func (pc *PrivacyController) ExportData(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	var buf bytes.Buffer
+	if err := pc.PrivacyService.Export(c.Request.Context(), uint(userID), &buf); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("user-%d-%s.zip", userID, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// @Summary		获取删除请求列表
+// @Description	获取彻底删除用户数据的请求及删除回执，需要 users:delete 权限
+// @Produce		json
+// @Param			status	query		string					false	"状态"	Enums(pending, completed)
+// @Success		200		{array}		models.ErasureRequest	"成功"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Failure		401		{object}	domainErrors.Problem	"未登录"
+// @Failure		403		{object}	domainErrors.Problem	"无权访问"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/erasures [get]
+func (pc *PrivacyController) ListErasures(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != models.ErasureStatusPending && status != models.ErasureStatusCompleted {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "erasure_status_invalid"))
+		return
+	}
+	requests, err := pc.PrivacyService.ListErasures(c.Request.Context(), status)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// @Summary		获取删除请求
+// @Description	获取彻底删除用户数据的请求及删除回执，需要 users:delete 权限
+// @Produce		json
+// @Param			id	path		int						true	"删除请求ID"
+// @Success		200	{object}	models.ErasureRequest	"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"删除请求不存在"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/erasures/{id} [get]
+func (pc *PrivacyController) GetErasure(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "erasure_id_invalid"))
+		return
+	}
+	request, err := pc.PrivacyService.GetErasure(c.Request.Context(), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
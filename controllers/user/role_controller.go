@@ -0,0 +1,72 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+type RoleController struct {
+	RBACService  *services.RBACService
+	AuditService *services.AuditService
+}
+
+// @Summary		获取角色列表
+// @Description	获取全部角色及其权限，需要 roles:manage 权限
+// @Produce		json
+// @Success		200	{array}		models.Role				"成功"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/roles [get]
+func (rc *RoleController) ListRoles(c *gin.Context) {
+	roles, err := rc.RBACService.ListRoles(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// @Summary		设置用户角色
+// @Description	将用户的角色替换为请求中的角色，需要 roles:manage 权限
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int							true	"用户ID"
+// @Param			request	body		models.SetUserRolesRequest	true	"角色"
+// @Success		200		{object}	models.UserResponse			"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误或角色不存在"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		403		{object}	domainErrors.Problem		"无权访问"
+// @Failure		404		{object}	domainErrors.Problem		"用户未找到"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/{id}/roles [put]
+func (rc *RoleController) SetUserRoles(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	var request models.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(domainErrors.NewAppError(err, domainErrors.ValidationError))
+		return
+	}
+	user, previous, err := rc.RBACService.SetUserRoles(c.Request.Context(), uint(userID), request.Roles)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	response := models.NewUserResponse(user)
+	entry := models.NewAuditLog(models.AuditActionUserRoles, models.AuditTargetUser, user.ID)
+	entry.Changes = models.AuditChanges{"roles": {Before: previous, After: response.Roles}}
+	middlewares.Audit(c, rc.AuditService, entry)
+	c.JSON(http.StatusOK, response)
+}
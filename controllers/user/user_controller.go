@@ -2,142 +2,206 @@ package user
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/middlewares"
 	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
 	"gorm.io/gorm"
 )
 
 type UserController struct {
-	DB          *gorm.DB
-	UserService models.IUserService
+	DB             *gorm.DB
+	UserService    models.IUserService
+	AuthService    *services.AuthService
+	AccountService *services.AccountService
+	AuditService   *services.AuditService
 }
 
 // @Summary		创建用户
-// @Description	创建一个新的用户
+// @Description	创建一个新的用户，并向邮箱发送验证邮件，邮件语言由 Accept-Language 决定
 // @Accept			json
 // @Produce		json
-// @Param			user	body		models.User	true	"用户信息"
-// @Success		200		{object}	models.User	"成功"
-// @Failure		400		{object}	string		"请求错误"
-// @Failure		500		{object}	string		"内部错误"
+// @Param			user	body		models.CreateUserRequest	true	"用户信息"
+// @Success		200		{object}	models.UserResponse			"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		409		{object}	domainErrors.Problem		"用户名或邮箱已被使用"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
 // @Router			/user [post]
 func (c *UserController) NewUser(ctx *gin.Context) {
-	var request models.User
-	if err := ctx.BindJSON(&request); err != nil {
+	var request models.CreateUserRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
 		appError := domainErrors.NewAppError(err, domainErrors.ValidationError)
 		_ = ctx.Error(appError)
 		return
 	}
-	userModel, err := c.UserService.Create(&request)
+	userModel, err := c.UserService.Create(request.ToUser(), request.Password)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	userResponse := userModel
-	ctx.JSON(http.StatusOK, userResponse)
+	entry := models.NewAuditLog(models.AuditActionUserCreate, models.AuditTargetUser, userModel.ID)
+	entry.Metadata = models.AuditMetadata{"user_name": userModel.UserName}
+	middlewares.Audit(ctx, c.AuditService, entry)
+	// 验证邮件发送失败不影响注册，用户可以稍后通过 /account/verification 重新发送
+	if err := c.AccountService.SendVerification(ctx.Request.Context(), userModel.ID, middlewares.Language(ctx)); err != nil {
+		slog.WarnContext(ctx.Request.Context(), "verification email not sent",
+			slog.Uint64("user_id", uint64(userModel.ID)), slog.Any("error", err))
+	}
+	ctx.JSON(http.StatusOK, models.NewUserResponse(userModel))
+}
+
+// @Summary		用户登录
+// @Description	使用用户名和密码登录，返回访问令牌
+// @Accept			json
+// @Produce		json
+// @Param			request	body		models.LoginRequest		true	"登录信息"
+// @Success		200		{object}	models.LoginResponse	"成功"
+// @Failure		400		{object}	domainErrors.Problem	"请求错误"
+// @Failure		401		{object}	domainErrors.Problem	"用户名或密码错误"
+// @Failure		500		{object}	domainErrors.Problem	"内部错误"
+// @Router			/user/login [post]
+func (c *UserController) Login(ctx *gin.Context) {
+	var request models.LoginRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		appError := domainErrors.NewAppError(err, domainErrors.ValidationError)
+		_ = ctx.Error(appError)
+		return
+	}
+	response, err := c.AuthService.Login(request.UserName, request.Password)
+	if err != nil {
+		// 登录失败时记录尝试的用户名，便于发现暴力破解
+		middlewares.Audit(ctx, c.AuditService, models.AuditLog{
+			Action:     models.AuditActionLogin,
+			Outcome:    models.AuditOutcomeFailure,
+			TargetType: models.AuditTargetUser,
+			Metadata:   models.AuditMetadata{"method": "password", "user_name": request.UserName, "reason": err.Error()},
+		})
+		_ = ctx.Error(err)
+		return
+	}
+	entry := models.NewAuditLog(models.AuditActionLogin, models.AuditTargetUser, response.User.ID)
+	entry.ActorID = &response.User.ID
+	entry.Metadata = models.AuditMetadata{"method": "password"}
+	middlewares.Audit(ctx, c.AuditService, entry)
+	ctx.JSON(http.StatusOK, response)
 }
 
 // @Summary		获取所有用户
-// @Description	获取所有用户的信息
+// @Description	获取所有用户的信息，需要 users:read 权限
 // @Produce		json
-// @Success		200	{array}		models.User	"成功"
-// @Failure		500	{object}	string		"内部错误"
+// @Success		200	{array}		models.UserResponse		"成功"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
 // @Router			/user [get]
 func (c *UserController) GetAllUsers(ctx *gin.Context) {
 	users, err := c.UserService.GetAll()
 	if err != nil {
-		appError := domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
-		_ = ctx.Error(appError)
+		_ = ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, users)
+	ctx.JSON(http.StatusOK, models.NewUserResponses(*users))
 }
 
 // @Summary		获取用户信息
-// @Description	根据用户ID获取用户信息
+// @Description	根据用户ID获取用户信息，包含用户的角色。获取其他用户需要 users:read 权限
 // @Produce		json
-// @Param			id	path		int			true	"用户ID"
-// @Success		200	{object}	models.User	"成功"
-// @Failure		400	{object}	string		"请求错误"
-// @Failure		404	{object}	string		"用户未找到"
-// @Failure		500	{object}	string		"内部错误"
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{object}	models.UserResponse		"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
 // @Router			/user/{id} [get]
 func (c *UserController) GetUserByID(ctx *gin.Context) {
 	userID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		appError := domainErrors.NewAppError(errors.New("user id is invalid"), domainErrors.ValidationError)
+		appError := domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid")
 		_ = ctx.Error(appError)
 		return
 	}
 	user, err := c.UserService.GetByID(uint(userID))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			appError := domainErrors.NewAppError(errors.New("user not found"), domainErrors.NotFound)
+			appError := domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
 			_ = ctx.Error(appError)
 			return
 		}
-		appError := domainErrors.NewAppError(err, domainErrors.UnknownError)
-		_ = ctx.Error(appError)
+		_ = ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
+	ctx.JSON(http.StatusOK, models.NewUserResponse(user))
 }
 
 // @Summary		更新用户信息
-// @Description	根据用户ID更新用户信息
+// @Description	根据用户ID更新用户信息。更新其他用户需要 users:write 权限
 // @Accept			json
 // @Produce		json
-// @Param			id		path		int			true	"用户ID"
-// @Param			user	body		models.User	true	"用户信息"
-// @Success		200		{object}	models.User	"成功"
-// @Failure		400		{object}	string		"请求错误"
-// @Failure		404		{object}	string		"用户未找到"
-// @Failure		500		{object}	string		"内部错误"
+// @Param			id		path		int							true	"用户ID"
+// @Param			user	body		models.UpdateUserRequest	true	"用户信息"
+// @Success		200		{object}	models.UserResponse			"成功"
+// @Failure		400		{object}	domainErrors.Problem		"请求错误"
+// @Failure		401		{object}	domainErrors.Problem		"未登录"
+// @Failure		403		{object}	domainErrors.Problem		"无权访问"
+// @Failure		404		{object}	domainErrors.Problem		"用户未找到"
+// @Failure		409		{object}	domainErrors.Problem		"用户名或邮箱已被使用"
+// @Failure		500		{object}	domainErrors.Problem		"内部错误"
+// @Security		BearerAuth
 // @Router			/user/{id} [put]
 func (c *UserController) UpdateUser(ctx *gin.Context) {
 	userID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		appError := domainErrors.NewAppError(errors.New("param id is necessary"), domainErrors.ValidationError)
+		appError := domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "id_required")
 		_ = ctx.Error(appError)
 		return
 	}
-	var requestMap models.User
-	err = ctx.BindJSON(&requestMap)
+	var request models.UpdateUserRequest
+	err = ctx.ShouldBindJSON(&request)
 	if err != nil {
 		appError := domainErrors.NewAppError(err, domainErrors.ValidationError)
 		_ = ctx.Error(appError)
 		return
 	}
-	err = updateValidation(requestMap)
+	before, err := c.UserService.GetByID(uint(userID))
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	userUpdated, err := c.UserService.Update(uint(userID), &requestMap)
+	userUpdated, err := c.UserService.Update(uint(userID), request.ToUser())
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, userUpdated)
+	entry := models.NewAuditLog(models.AuditActionUserUpdate, models.AuditTargetUser, userUpdated.ID)
+	entry.Changes = models.UserChanges(before, userUpdated)
+	middlewares.Audit(ctx, c.AuditService, entry)
+	ctx.JSON(http.StatusOK, models.NewUserResponse(userUpdated))
 }
 
 // @Summary		删除用户
-// @Description	根据用户ID删除用户
+// @Description	根据用户ID软删除用户，删除后不能登录，管理员可以恢复。彻底删除用户数据使用 /user/{id}/erasure。删除其他用户需要 users:delete 权限
 // @Produce		json
-// @Param			id	path		int		true	"用户ID"
-// @Success		200	{object}	string	"成功"
-// @Failure		400	{object}	string	"请求错误"
-// @Failure		404	{object}	string	"用户未找到"
-// @Failure		500	{object}	string	"内部错误"
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{object}	string					"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
 // @Router			/user/{id} [delete]
 func (c *UserController) DeleteUser(ctx *gin.Context) {
 	userID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		appError := domainErrors.NewAppError(errors.New("param id is necessary"), domainErrors.ValidationError)
+		appError := domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "id_required")
 		_ = ctx.Error(appError)
 		return
 	}
@@ -146,5 +210,110 @@ func (c *UserController) DeleteUser(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	middlewares.Audit(ctx, c.AuditService, models.NewAuditLog(models.AuditActionUserDelete, models.AuditTargetUser, uint(userID)))
 	ctx.JSON(http.StatusOK, gin.H{"message": "resource deleted successfully"})
 }
+
+// @Summary		获取已删除的用户
+// @Description	获取被软删除、可以恢复的用户，需要 users:read 权限
+// @Produce		json
+// @Success		200	{array}		models.UserResponse		"成功"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/deleted [get]
+func (c *UserController) ListDeletedUsers(ctx *gin.Context) {
+	users, err := c.UserService.GetDeleted()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.NewUserResponses(*users))
+}
+
+// @Summary		恢复用户
+// @Description	恢复被软删除的用户，需要 users:delete 权限。已请求彻底删除的用户不能恢复
+// @Produce		json
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{object}	models.UserResponse		"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"已删除的用户未找到"
+// @Failure		409	{object}	domainErrors.Problem	"用户数据正在被彻底删除"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/{id}/restore [post]
+func (c *UserController) RestoreUser(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		_ = ctx.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	user, err := c.UserService.Restore(uint(userID))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	middlewares.Audit(ctx, c.AuditService, models.NewAuditLog(models.AuditActionUserRestore, models.AuditTargetUser, user.ID))
+	ctx.JSON(http.StatusOK, models.NewUserResponse(user))
+}
+
+// @Summary		停用用户
+// @Description	停用用户，需要 users:write 权限。停用的用户不能登录，已签发的访问令牌和 API 密钥立即失效，数据保留
+// @Produce		json
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{object}	models.UserResponse		"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/{id}/deactivate [post]
+func (c *UserController) DeactivateUser(ctx *gin.Context) {
+	c.setActive(ctx, false)
+}
+
+// @Summary		重新启用用户
+// @Description	重新启用被停用的用户，需要 users:write 权限
+// @Produce		json
+// @Param			id	path		int						true	"用户ID"
+// @Success		200	{object}	models.UserResponse		"成功"
+// @Failure		400	{object}	domainErrors.Problem	"请求错误"
+// @Failure		401	{object}	domainErrors.Problem	"未登录"
+// @Failure		403	{object}	domainErrors.Problem	"无权访问"
+// @Failure		404	{object}	domainErrors.Problem	"用户未找到"
+// @Failure		500	{object}	domainErrors.Problem	"内部错误"
+// @Security		BearerAuth
+// @Router			/admin/users/{id}/reactivate [post]
+func (c *UserController) ReactivateUser(ctx *gin.Context) {
+	c.setActive(ctx, true)
+}
+
+func (c *UserController) setActive(ctx *gin.Context, active bool) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		_ = ctx.Error(domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "user_id_invalid"))
+		return
+	}
+	before, err := c.UserService.GetByID(uint(userID))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	user, err := c.UserService.SetActive(uint(userID), active)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	action := models.AuditActionUserDeactivate
+	if active {
+		action = models.AuditActionUserReactivate
+	}
+	entry := models.NewAuditLog(action, models.AuditTargetUser, user.ID)
+	entry.Changes = models.UserChanges(before, user)
+	middlewares.Audit(ctx, c.AuditService, entry)
+	ctx.JSON(http.StatusOK, models.NewUserResponse(user))
+}
@@ -0,0 +1,273 @@
+package errors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// Field error codes of database constraint violations.
+const (
+	fieldCodeUnique   = "unique"
+	fieldCodeRequired = "required"
+	fieldCodeExists   = "exists"
+)
+
+// MySQL server error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlDuplicateEntry     = 1062
+	mysqlNoDefaultValue     = 1364
+	mysqlColumnCannotBeNull = 1048
+	mysqlRowIsReferenced    = 1451
+	mysqlNoReferencedRow    = 1452
+	mysqlRowIsReferenced2   = 1217
+	mysqlNoReferencedRow2   = 1216
+	mysqlCheckViolated      = 3819
+	mysqlLockWaitTimeout    = 1205
+	mysqlDeadlock           = 1213
+	mysqlTooManyConnections = 1040
+	mysqlServerShutdown     = 1053
+)
+
+// PostgreSQL SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgNotNullViolation     = "23502"
+	pgCheckViolation       = "23514"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgLockNotAvailable     = "55P03"
+	pgTooManyConnections   = "53300"
+	pgAdminShutdown        = "57P01"
+	pgCannotConnectNow     = "57P03"
+	pgConnectionException  = "08"
+)
+
+var (
+	// Duplicate entry 'a@b.c' for key 'users.email'
+	mysqlKeyPattern = regexp.MustCompile(`for key '([^']+)'`)
+	// Column 'email' cannot be null / Field 'email' doesn't have a default value
+	mysqlColumnPattern = regexp.MustCompile(`^(?:Column|Field) '([^']+)'`)
+	// ... CONSTRAINT `fk_x` FOREIGN KEY (`user_id`) REFERENCES ...
+	mysqlForeignKeyPattern = regexp.MustCompile("FOREIGN KEY \\(`([^`]+)`")
+	// Key (email)=(a@b.c) already exists.
+	pgKeyPattern = regexp.MustCompile(`^Key \(([^)]+)\)`)
+	// UNIQUE constraint failed: users.email
+	sqliteColumnPattern = regexp.MustCompile(`constraint failed: ([^\s,]+)`)
+)
+
+// dbError describes a database error in driver-independent terms.
+type dbError struct {
+	errType   string
+	messageID string
+	// field is the column that violated a constraint, if the driver reports it
+	field     string
+	fieldCode string
+}
+
+// TranslateDBError converts an error returned by GORM into an AppError, recognizing
+// constraint violations, deadlocks and connection failures of the MySQL, SQLite and
+// PostgreSQL drivers. The conflicting column, when known, is reported in Fields.
+// nil and AppErrors are returned as is; the original error stays available through errors.Is/As.
+func TranslateDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return err
+	}
+
+	translated := classifyDBError(err)
+	appErr = NewAppErrorWithMessage(translated.errType, translated.messageID, translated.args()...)
+	appErr.Err = fmt.Errorf("%s: %w", appErr.Err, err)
+	if translated.field != "" {
+		appErr.Fields = []FieldError{{Field: translated.field, Code: translated.fieldCode}}
+	}
+	return appErr
+}
+
+// args returns the arguments of the error's message.
+func (e dbError) args() []any {
+	if e.field == "" {
+		return nil
+	}
+	return []any{e.field}
+}
+
+func classifyDBError(err error) dbError {
+	var mysqlErr *mysql.MySQLError
+	var pgErr *pgconn.PgError
+	var sqliteErr sqlite3.Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, sql.ErrNoRows):
+		return dbError{errType: NotFound, messageID: notFoundMessage}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return uniqueViolation("")
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return dbError{errType: Conflict, messageID: "foreign_key_violation"}
+	case errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return dbError{errType: ValidationError, messageID: "check_constraint_violation"}
+	case errors.As(err, &mysqlErr):
+		return classifyMySQLError(mysqlErr)
+	case errors.As(err, &pgErr):
+		return classifyPostgresError(pgErr)
+	case errors.As(err, &sqliteErr):
+		return classifySQLiteError(sqliteErr)
+	case isConnectionError(err):
+		return dbError{errType: ServiceUnavailable, messageID: "database_unavailable"}
+	}
+	return dbError{errType: RepositoryError, messageID: repositoryErrorMessage}
+}
+
+func classifyMySQLError(err *mysql.MySQLError) dbError {
+	switch err.Number {
+	case mysqlDuplicateEntry:
+		return uniqueViolation(mysqlKeyColumn(err.Message))
+	case mysqlColumnCannotBeNull, mysqlNoDefaultValue:
+		return notNullViolation(firstSubmatch(mysqlColumnPattern, err.Message))
+	case mysqlNoReferencedRow, mysqlNoReferencedRow2:
+		return missingReference(firstSubmatch(mysqlForeignKeyPattern, err.Message))
+	case mysqlRowIsReferenced, mysqlRowIsReferenced2:
+		return dbError{errType: Conflict, messageID: "record_still_referenced"}
+	case mysqlCheckViolated:
+		return dbError{errType: ValidationError, messageID: "check_constraint_violation"}
+	case mysqlDeadlock, mysqlLockWaitTimeout:
+		return dbError{errType: ServiceUnavailable, messageID: "database_busy"}
+	case mysqlTooManyConnections, mysqlServerShutdown:
+		return dbError{errType: ServiceUnavailable, messageID: "database_unavailable"}
+	}
+	return dbError{errType: RepositoryError, messageID: repositoryErrorMessage}
+}
+
+func classifyPostgresError(err *pgconn.PgError) dbError {
+	switch err.Code {
+	case pgUniqueViolation:
+		return uniqueViolation(pgKeyColumn(err))
+	case pgNotNullViolation:
+		return notNullViolation(err.ColumnName)
+	case pgForeignKeyViolation:
+		// Deleting or updating a row that is still referenced fails with
+		// "update or delete on table ..."; anything else is a dangling reference.
+		if strings.HasPrefix(err.Message, "update or delete") {
+			return dbError{errType: Conflict, messageID: "record_still_referenced"}
+		}
+		return missingReference(pgKeyColumn(err))
+	case pgCheckViolation:
+		return dbError{errType: ValidationError, messageID: "check_constraint_violation"}
+	case pgDeadlockDetected, pgSerializationFailure, pgLockNotAvailable:
+		return dbError{errType: ServiceUnavailable, messageID: "database_busy"}
+	case pgTooManyConnections, pgAdminShutdown, pgCannotConnectNow:
+		return dbError{errType: ServiceUnavailable, messageID: "database_unavailable"}
+	}
+	if strings.HasPrefix(err.Code, pgConnectionException) {
+		return dbError{errType: ServiceUnavailable, messageID: "database_unavailable"}
+	}
+	return dbError{errType: RepositoryError, messageID: repositoryErrorMessage}
+}
+
+func classifySQLiteError(err sqlite3.Error) dbError {
+	switch err.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return uniqueViolation(sqliteColumn(err.Error()))
+	case sqlite3.ErrConstraintNotNull:
+		return notNullViolation(sqliteColumn(err.Error()))
+	case sqlite3.ErrConstraintForeignKey:
+		// SQLite does not say which side of the reference failed.
+		return dbError{errType: Conflict, messageID: "foreign_key_violation"}
+	case sqlite3.ErrConstraintCheck:
+		return dbError{errType: ValidationError, messageID: "check_constraint_violation"}
+	}
+	switch err.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return dbError{errType: ServiceUnavailable, messageID: "database_busy"}
+	case sqlite3.ErrCantOpen, sqlite3.ErrIoErr:
+		return dbError{errType: ServiceUnavailable, messageID: "database_unavailable"}
+	}
+	return dbError{errType: RepositoryError, messageID: repositoryErrorMessage}
+}
+
+func uniqueViolation(field string) dbError {
+	if field == "" {
+		return dbError{errType: ResourceAlreadyExists, messageID: alreadyExistsErrorMessage}
+	}
+	return dbError{errType: ResourceAlreadyExists, messageID: "field_already_exists", field: field, fieldCode: fieldCodeUnique}
+}
+
+func notNullViolation(field string) dbError {
+	if field == "" {
+		return dbError{errType: ValidationError, messageID: validationErrorMessage}
+	}
+	return dbError{errType: ValidationError, messageID: "field_required", field: field, fieldCode: fieldCodeRequired}
+}
+
+func missingReference(field string) dbError {
+	if field == "" {
+		return dbError{errType: ValidationError, messageID: "foreign_key_violation"}
+	}
+	return dbError{errType: ValidationError, messageID: "related_record_missing", field: field, fieldCode: fieldCodeExists}
+}
+
+// isConnectionError reports whether err means that the database could not be reached.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	var connectErr *pgconn.ConnectError
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr)
+}
+
+// mysqlKeyColumn returns the column of the unique key named in a duplicate entry message.
+// MySQL 8 qualifies the key with its table, and GORM names unique indexes idx_<table>_<column>.
+func mysqlKeyColumn(message string) string {
+	key := firstSubmatch(mysqlKeyPattern, message)
+	table, name, qualified := strings.Cut(key, ".")
+	if !qualified {
+		return key
+	}
+	if column, ok := strings.CutPrefix(name, "idx_"+table+"_"); ok {
+		return column
+	}
+	return name
+}
+
+// pgKeyColumn returns the first column of the key in a PostgreSQL constraint violation.
+func pgKeyColumn(err *pgconn.PgError) string {
+	if err.ColumnName != "" {
+		return err.ColumnName
+	}
+	columns := firstSubmatch(pgKeyPattern, err.Detail)
+	column, _, _ := strings.Cut(columns, ",")
+	return strings.Trim(strings.TrimSpace(column), `"`)
+}
+
+// sqliteColumn returns the first column named in an SQLite constraint failure.
+func sqliteColumn(message string) string {
+	column := firstSubmatch(sqliteColumnPattern, message)
+	if _, name, ok := strings.Cut(column, "."); ok {
+		return name
+	}
+	return column
+}
+
+func firstSubmatch(pattern *regexp.Regexp, s string) string {
+	if m := pattern.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package errors
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// requireTranslation checks the type, message and reported field of a translated database error.
+func requireTranslation(t *testing.T, err error, errType, messageID, field string) {
+	t.Helper()
+	translated := TranslateDBError(err)
+	var appErr *AppError
+	if !errors.As(translated, &appErr) {
+		t.Fatalf("TranslateDBError(%v) = %v, want an AppError", err, translated)
+	}
+	if appErr.Type != errType || appErr.MessageID != messageID {
+		t.Fatalf("got %s/%s, want %s/%s", appErr.Type, appErr.MessageID, errType, messageID)
+	}
+	var got string
+	if len(appErr.Fields) > 0 {
+		got = appErr.Fields[0].Field
+	}
+	if got != field {
+		t.Fatalf("field = %q, want %q", got, field)
+	}
+	if !errors.Is(translated, err) {
+		t.Fatalf("the original error %v is not wrapped", err)
+	}
+}
+
+func TestTranslateDBError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		errType   string
+		messageID string
+		field     string
+	}{
+		{"record not found", gorm.ErrRecordNotFound, NotFound, notFoundMessage, ""},
+		{"gorm duplicated key", gorm.ErrDuplicatedKey, ResourceAlreadyExists, alreadyExistsErrorMessage, ""},
+		{"bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), ServiceUnavailable, "database_unavailable", ""},
+		{"unknown", errors.New("something else"), RepositoryError, repositoryErrorMessage, ""},
+
+		{"mysql 1062 with table-qualified gorm index", &mysql.MySQLError{Number: 1062,
+			Message: "Duplicate entry 'a@b.c' for key 'users.idx_users_email'"},
+			ResourceAlreadyExists, "field_already_exists", "email"},
+		{"mysql 1062 with bare key", &mysql.MySQLError{Number: 1062,
+			Message: "Duplicate entry 'alice' for key 'user_name'"},
+			ResourceAlreadyExists, "field_already_exists", "user_name"},
+		{"mysql 1062 wrapped", fmt.Errorf("create: %w", &mysql.MySQLError{Number: 1062,
+			Message: "Duplicate entry 'k' for key 'api_keys.prefix'"}),
+			ResourceAlreadyExists, "field_already_exists", "prefix"},
+		{"mysql 1048", &mysql.MySQLError{Number: 1048, Message: "Column 'email' cannot be null"},
+			ValidationError, "field_required", "email"},
+		{"mysql 1364", &mysql.MySQLError{Number: 1364, Message: "Field 'user_name' doesn't have a default value"},
+			ValidationError, "field_required", "user_name"},
+		{"mysql 1451", &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: " +
+			"a foreign key constraint fails (`app`.`chat_messages`, CONSTRAINT `fk_chat_messages_user` " +
+			"FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"},
+			Conflict, "record_still_referenced", ""},
+		{"mysql 1452", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: " +
+			"a foreign key constraint fails (`app`.`chat_messages`, CONSTRAINT `fk_chat_messages_user` " +
+			"FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"},
+			ValidationError, "related_record_missing", "user_id"},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"},
+			ServiceUnavailable, "database_busy", ""},
+		{"mysql unknown", &mysql.MySQLError{Number: 1146, Message: "Table 'app.users' doesn't exist"},
+			RepositoryError, repositoryErrorMessage, ""},
+
+		{"postgres 23505", &pgconn.PgError{Code: "23505",
+			Message: `duplicate key value violates unique constraint "idx_users_email"`,
+			Detail:  "Key (email)=(a@b.c) already exists."},
+			ResourceAlreadyExists, "field_already_exists", "email"},
+		{"postgres 23505 composite key", &pgconn.PgError{Code: "23505",
+			Detail: `Key ("issuer", subject)=(https://sso, 42) already exists.`},
+			ResourceAlreadyExists, "field_already_exists", "issuer"},
+		{"postgres 23503 dangling reference", &pgconn.PgError{Code: "23503",
+			Message: `insert or update on table "chat_messages" violates foreign key constraint "fk_chat_messages_user"`,
+			Detail:  `Key (user_id)=(9) is not present in table "users".`},
+			ValidationError, "related_record_missing", "user_id"},
+		{"postgres 23503 still referenced", &pgconn.PgError{Code: "23503",
+			Message: `update or delete on table "users" violates foreign key constraint "fk_chat_messages_user" on table "chat_messages"`,
+			Detail:  `Key (id)=(9) is still referenced from table "chat_messages".`},
+			Conflict, "record_still_referenced", ""},
+		{"postgres 23502", &pgconn.PgError{Code: "23502", ColumnName: "email"},
+			ValidationError, "field_required", "email"},
+		{"postgres deadlock", &pgconn.PgError{Code: "40P01"}, ServiceUnavailable, "database_busy", ""},
+		{"postgres connection failure", &pgconn.PgError{Code: "08006"}, ServiceUnavailable, "database_unavailable", ""},
+
+		{"sqlite busy", sqlite3.Error{Code: sqlite3.ErrBusy}, ServiceUnavailable, "database_busy", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requireTranslation(t, tt.err, tt.errType, tt.messageID, tt.field)
+		})
+	}
+}
+
+func TestTranslateDBErrorKeepsAppErrors(t *testing.T) {
+	appErr := NewAppErrorWithType(NotFound)
+	if got := TranslateDBError(appErr); got != appErr {
+		t.Fatalf("TranslateDBError changed an AppError to %v", got)
+	}
+	if TranslateDBError(nil) != nil {
+		t.Fatal("TranslateDBError(nil) is not nil")
+	}
+}
+
+// TestTranslateSQLiteConstraintErrors uses the messages of a real SQLite database,
+// including the duplicate email on signup that used to surface as a generic database error.
+func TestTranslateSQLiteConstraintErrors(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	if err := db.Create(&models.User{UserName: "alice", Email: "alice@example.com", HashPassword: "x"}).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		create    func() error
+		errType   string
+		messageID string
+		field     string
+	}{
+		{"UNIQUE email", func() error {
+			return db.Create(&models.User{UserName: "alice2", Email: "alice@example.com", HashPassword: "x"}).Error
+		}, ResourceAlreadyExists, "field_already_exists", "email"},
+		{"UNIQUE user name", func() error {
+			return db.Create(&models.User{UserName: "alice", Email: "other@example.com", HashPassword: "x"}).Error
+		}, ResourceAlreadyExists, "field_already_exists", "user_name"},
+		{"NOT NULL", func() error {
+			return db.Exec("INSERT INTO users (user_name, hash_password) VALUES ('bobby', 'x')").Error
+		}, ValidationError, "field_required", "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.create()
+			var sqliteErr sqlite3.Error
+			if !errors.As(err, &sqliteErr) {
+				t.Fatalf("got %v, want an SQLite error", err)
+			}
+			requireTranslation(t, err, tt.errType, tt.messageID, tt.field)
+		})
+	}
+}
@@ -1,45 +1,81 @@
 package errors
 
-import "errors"
+import (
+	"errors"
 
+	"github.com/thoulee21/go-learn/i18n"
+)
+
+// Error types, each with the ID of its default message in the i18n catalog.
 const (
 	// NotFound error indicates a missing / not found record
 	NotFound        = "NotFound"
-	notFoundMessage = "record not found"
+	notFoundMessage = "record_not_found"
 
 	// ValidationError indicates an error in input validation
 	ValidationError        = "ValidationError"
-	validationErrorMessage = "validation error"
+	validationErrorMessage = "validation_error"
 
 	// ResourceAlreadyExists indicates a duplicate / already existing record
 	ResourceAlreadyExists     = "ResourceAlreadyExists"
-	alreadyExistsErrorMessage = "resource already exists"
+	alreadyExistsErrorMessage = "resource_already_exists"
 
 	// RepositoryError indicates a repository (e.g database) error
 	RepositoryError        = "RepositoryError"
-	repositoryErrorMessage = "error in repository operation"
+	repositoryErrorMessage = "repository_operation"
 
 	// NotAuthenticated indicates an authentication error
 	NotAuthenticated             = "NotAuthenticated"
-	notAuthenticatedErrorMessage = "not Authenticated"
+	notAuthenticatedErrorMessage = "not_authenticated_error"
 
 	// TokenGeneratorError indicates an token generation error
 	TokenGeneratorError        = "TokenGeneratorError"
-	tokenGeneratorErrorMessage = "error in token generation"
+	tokenGeneratorErrorMessage = "token_generator_error"
 
 	// NotAuthorized indicates an authorization error
 	NotAuthorized             = "NotAuthorized"
-	notAuthorizedErrorMessage = "not authorized"
+	notAuthorizedErrorMessage = "not_authorized_error"
+
+	// ContentFiltered indicates that a prompt or completion was blocked by content moderation
+	ContentFiltered             = "ContentFiltered"
+	contentFilteredErrorMessage = "content_blocked"
+
+	// MethodNotAllowed indicates that the route does not support the request method
+	MethodNotAllowed             = "MethodNotAllowed"
+	methodNotAllowedErrorMessage = "method_not_allowed_error"
+
+	// ServiceUnavailable indicates that the server cannot finish the request, e.g. while shutting down
+	ServiceUnavailable             = "ServiceUnavailable"
+	serviceUnavailableErrorMessage = "service_unavailable_error"
+
+	// UpstreamError indicates that an upstream service such as the AI provider failed
+	UpstreamError             = "UpstreamError"
+	upstreamErrorErrorMessage = "upstream_service_error"
+
+	// Conflict indicates that the request conflicts with related records, e.g. deleting a referenced record
+	Conflict             = "Conflict"
+	conflictErrorMessage = "conflict_error"
+
+	// RateLimited indicates that the client sent too many requests of a kind, e.g. emails
+	RateLimited             = "RateLimited"
+	rateLimitedErrorMessage = "rate_limited_error"
 
 	// UnknownError indicates an error that the app cannot find the cause for
 	UnknownError        = "UnknownError"
-	unknownErrorMessage = "something went wrong"
+	unknownErrorMessage = "unknown_error"
 )
 
 // AppError defines an application (domain) error
 type AppError struct {
 	Err  error
 	Type string
+	// MessageID and Args identify the message in the i18n catalog that is shown to clients.
+	// Errors without a MessageID show Err's message untranslated.
+	MessageID string
+	Args      []any
+	// Fields lists the request fields that caused the error. Their messages are filled in
+	// when the error is rendered.
+	Fields []FieldError
 }
 
 // NewAppError initializes a new domain error using an error and its type.
@@ -52,34 +88,72 @@ func NewAppError(err error, errType string) *AppError {
 
 // NewAppErrorWithType initializes a new default error for a given type.
 func NewAppErrorWithType(errType string) *AppError {
-	var err error
+	return NewAppErrorWithMessage(errType, defaultMessageID(errType))
+}
+
+// defaultMessageID returns the default i18n message of an error type.
+func defaultMessageID(errType string) string {
+	var messageID string
 
 	switch errType {
 	case NotFound:
-		err = errors.New(notFoundMessage)
+		messageID = notFoundMessage
 	case ValidationError:
-		err = errors.New(validationErrorMessage)
+		messageID = validationErrorMessage
 	case ResourceAlreadyExists:
-		err = errors.New(alreadyExistsErrorMessage)
+		messageID = alreadyExistsErrorMessage
 	case RepositoryError:
-		err = errors.New(repositoryErrorMessage)
+		messageID = repositoryErrorMessage
 	case NotAuthenticated:
-		err = errors.New(notAuthenticatedErrorMessage)
+		messageID = notAuthenticatedErrorMessage
 	case NotAuthorized:
-		err = errors.New(notAuthorizedErrorMessage)
+		messageID = notAuthorizedErrorMessage
 	case TokenGeneratorError:
-		err = errors.New(tokenGeneratorErrorMessage)
+		messageID = tokenGeneratorErrorMessage
+	case ContentFiltered:
+		messageID = contentFilteredErrorMessage
+	case MethodNotAllowed:
+		messageID = methodNotAllowedErrorMessage
+	case ServiceUnavailable:
+		messageID = serviceUnavailableErrorMessage
+	case UpstreamError:
+		messageID = upstreamErrorErrorMessage
+	case Conflict:
+		messageID = conflictErrorMessage
+	case RateLimited:
+		messageID = rateLimitedErrorMessage
 	default:
-		err = errors.New(unknownErrorMessage)
+		messageID = unknownErrorMessage
 	}
+	return messageID
+}
 
+// NewAppErrorWithMessage initializes a new domain error whose message is looked up in the
+// i18n catalog, so that clients see it in their language. Err holds the English message.
+func NewAppErrorWithMessage(errType string, messageID string, args ...any) *AppError {
 	return &AppError{
-		Err:  err,
-		Type: errType,
+		Err:       errors.New(i18n.T(i18n.English, messageID, args...)),
+		Type:      errType,
+		MessageID: messageID,
+		Args:      args,
 	}
 }
 
+// Reason returns a stable identifier that is more specific than the error type,
+// namely the i18n message ID. It is empty for errors that use the default message of their type.
+func (appErr *AppError) Reason() string {
+	if appErr.MessageID == defaultMessageID(appErr.Type) {
+		return ""
+	}
+	return appErr.MessageID
+}
+
 // String converts the app error to a human-readable string.
 func (appErr *AppError) Error() string {
 	return appErr.Err.Error()
 }
+
+// Unwrap returns the underlying error.
+func (appErr *AppError) Unwrap() error {
+	return appErr.Err
+}
@@ -0,0 +1,37 @@
+package errors
+
+// ProblemTypeBaseURI prefixes the code of a problem to form its type URI.
+const ProblemTypeBaseURI = "https://github.com/thoulee21/go-learn/blob/main/docs/problems.md#"
+
+// Problem is an RFC 7807 problem details response body.
+type Problem struct {
+	// Type is a URI that identifies the problem type and links to its documentation
+	Type string `json:"type" example:"https://github.com/thoulee21/go-learn/blob/main/docs/problems.md#not_found"`
+	// Title is a short summary of the problem type that does not change between occurrences
+	Title string `json:"title" example:"Not Found"`
+	// Status is the HTTP status code
+	Status int `json:"status" example:"404"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty" example:"conversation not found"`
+	// Instance is the request path
+	Instance string `json:"instance,omitempty" example:"/conversations/abc"`
+	// Code is a stable machine-readable identifier; clients should match on it instead of on Detail
+	Code string `json:"code" example:"not_found" enums:"validation_failed,not_authenticated,not_authorized,not_found,method_not_allowed,already_exists,conflict,content_filtered,rate_limited,repository_error,token_generation_failed,upstream_error,service_unavailable,internal_error"`
+	// Reason is a stable identifier that is more specific than Code, e.g. erasure_pending or api_key_limit.
+	// It is omitted when there is no more specific reason. See docs/problems.md for the possible values
+	Reason string `json:"reason,omitempty" example:"erasure_pending"`
+	// RequestID matches the X-Request-ID response header and the server logs
+	RequestID string `json:"request_id,omitempty" example:"5f0c6a8e-3b7d-4a1e-9c2f-1d2e3f4a5b6c"`
+	// Errors lists the invalid or conflicting fields, e.g. of a validation_failed problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field is invalid.
+type FieldError struct {
+	// Field is the JSON name of the field
+	Field string `json:"field" example:"message"`
+	// Code is the failed rule, e.g. required, max, email, unique
+	Code string `json:"code" example:"required"`
+	// Message is a human-readable explanation
+	Message string `json:"message" example:"is required"`
+}
@@ -0,0 +1,92 @@
+// Package i18n holds the message catalogs and negotiates the response language.
+package i18n
+
+import (
+	"fmt"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+// Supported languages, as BCP 47 tags.
+const (
+	English           = "en"
+	SimplifiedChinese = "zh-CN"
+
+	// DefaultLanguage is used when the request has no Accept-Language header.
+	DefaultLanguage = SimplifiedChinese
+	// FallbackLanguage is used when none of the requested languages is supported,
+	// and for messages missing from a catalog.
+	FallbackLanguage = English
+)
+
+var (
+	languages = []string{SimplifiedChinese, English}
+	matcher   = language.NewMatcher([]language.Tag{language.MustParse(SimplifiedChinese), language.English})
+
+	catalogs = map[string]map[string]string{
+		English:           messagesEn,
+		SimplifiedChinese: messagesZhCN,
+	}
+
+	// universal-translator locale names of the supported languages.
+	translatorLocales = map[string]string{
+		English:           "en",
+		SimplifiedChinese: "zh",
+	}
+	universal = ut.New(en.New(), en.New(), zh.New())
+)
+
+// Negotiate picks the supported language that best matches an Accept-Language header.
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return FallbackLanguage
+	}
+	return languages[index]
+}
+
+// T returns the message with the given ID in lang, formatting args into it.
+// Unknown IDs are returned as is.
+func T(lang, id string, args ...any) string {
+	format, ok := catalogs[lang][id]
+	if !ok {
+		if format, ok = catalogs[FallbackLanguage][id]; !ok {
+			return id
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Has reports whether the fallback catalog defines id.
+func Has(id string) bool {
+	_, ok := catalogs[FallbackLanguage][id]
+	return ok
+}
+
+// Translator returns the validator translator for lang.
+func Translator(lang string) ut.Translator {
+	trans, _ := universal.GetTranslator(translatorLocales[lang])
+	return trans
+}
+
+// RegisterValidatorTranslations registers the built-in validation messages of every
+// supported language with v, so that validator.FieldError.Translate can be used.
+func RegisterValidatorTranslations(v *validator.Validate) error {
+	if err := enTranslations.RegisterDefaultTranslations(v, Translator(English)); err != nil {
+		return err
+	}
+	return zhTranslations.RegisterDefaultTranslations(v, Translator(SimplifiedChinese))
+}
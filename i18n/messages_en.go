@@ -0,0 +1,121 @@
+package i18n
+
+var messagesEn = map[string]string{
+	// Problem titles, keyed by error code
+	"validation_failed":       "Invalid request",
+	"not_authenticated":       "Authentication required",
+	"not_authorized":          "Permission denied",
+	"not_found":               "Not found",
+	"method_not_allowed":      "Method not allowed",
+	"already_exists":          "Already exists",
+	"conflict":                "Conflict",
+	"content_filtered":        "Content blocked",
+	"rate_limited":            "Too many requests",
+	"repository_error":        "Database error",
+	"token_generation_failed": "Token generation failed",
+	"upstream_error":          "AI service error",
+	"service_unavailable":     "Service unavailable",
+	"internal_error":          "Internal server error",
+
+	// Default messages of the error types
+	"record_not_found":          "record not found",
+	"validation_error":          "validation error",
+	"resource_already_exists":   "resource already exists",
+	"repository_operation":      "error in repository operation",
+	"not_authenticated_error":   "not authenticated",
+	"token_generator_error":     "error in token generation",
+	"not_authorized_error":      "not authorized",
+	"content_blocked":           "content blocked by moderation policy",
+	"method_not_allowed_error":  "method not allowed",
+	"service_unavailable_error": "service unavailable",
+	"upstream_service_error":    "upstream service error",
+	"conflict_error":            "request conflicts with related records",
+	"rate_limited_error":        "too many requests, please try again later",
+	"unknown_error":             "something went wrong",
+
+	// Request validation
+	"request_validation_failed": "request validation failed",
+	"malformed_json":            "malformed JSON request body",
+	"field_type":                "%s must be of type %s",
+	"username_charset":          "%s may only contain letters, digits, '_', '-' and '.', and must start with a letter or digit",
+	"password_strength":         "%s must contain at least one letter and one digit",
+	"route_not_found":           "route not found",
+	"id_required":               "param id is necessary",
+	"session_id_required":       "session id is required",
+	"format_required":           "format is required",
+	"file_required":             "file is required",
+	"attachment_too_large":      "file is too large",
+	"user_id_invalid":           "user id is invalid",
+	"share_id_invalid":          "share link id is invalid",
+	"flag_id_invalid":           "flag id is invalid",
+	"document_id_invalid":       "document id is invalid",
+	"attachment_id_invalid":     "attachment id is invalid",
+	"api_key_id_invalid":        "API key id is invalid",
+
+	// Users and authentication
+	"user_not_found":            "user not found",
+	"deleted_user_not_found":    "deleted user not found",
+	"account_deactivated":       "this account has been deactivated",
+	"invalid_credentials":       "invalid user name or password",
+	"invalid_token":             "invalid or expired token",
+	"role_unknown":              "unknown roles: %s",
+	"api_key_invalid":           "API key is invalid, revoked or expired",
+	"api_key_not_found":         "API key not found",
+	"api_key_limit":             "at most %d active API keys are allowed per user",
+	"api_key_scope_missing":     "API key lacks the %s scope",
+	"api_key_not_allowed":       "API keys cannot be used for this endpoint",
+	"oidc_provider_unknown":     "unknown identity provider %s",
+	"oidc_provider_unavailable": "identity provider %s is unavailable",
+	"oidc_state_invalid":        "login session is invalid or has expired, please start again",
+	"oidc_login_failed":         "login with the identity provider failed: %s",
+	"oidc_login_denied":         "the identity provider did not complete the login",
+	"oidc_email_missing":        "identity provider did not return an email address",
+	"oidc_email_in_use":         "an account with email %s already exists; sign in with its password and verify the email before using single sign-on",
+	"oidc_account_not_found":    "no account is linked to this identity",
+	"erasure_pending":           "an erasure request for this user is already pending",
+	"erasure_not_found":         "erasure request not found",
+	"erasure_id_invalid":        "erasure request id is invalid",
+	"erasure_status_invalid":    "status must be pending or completed",
+
+	// Database
+	"field_already_exists":       "%s already exists",
+	"field_required":             "%s is required",
+	"related_record_missing":     "%s refers to a record that does not exist",
+	"record_still_referenced":    "record is still referenced by other records",
+	"foreign_key_violation":      "request refers to missing records or to records that are still in use",
+	"check_constraint_violation": "request violates a data constraint",
+	"database_busy":              "database is busy, please retry",
+	"database_unavailable":       "database is unavailable",
+
+	// Account emails
+	"invalid_account_token":  "link is invalid or has expired",
+	"email_already_verified": "email is already verified",
+	"email_resend_too_soon":  "please wait %d seconds before requesting another email",
+	"email_daily_limit":      "too many emails requested today, please try again tomorrow",
+	"email_unchanged":        "new email is the same as the current one",
+	"password_incorrect":     "password is incorrect",
+	"mail_delivery_failed":   "failed to send email",
+	"duration_hours":         "%d hours",
+	"duration_minutes":       "%d minutes",
+	"mail_verify_subject":    "Verify your email address",
+	"mail_verify_body":       "Hello %s,\n\nPlease confirm your email address by opening the link below within %s:\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+	"mail_reset_subject":     "Reset your password",
+	"mail_reset_body":        "Hello %s,\n\nWe received a request to reset your password. Open the link below within %s to choose a new one:\n\n%s\n\nIf you did not request a password reset, you can ignore this email and your password stays unchanged.\n",
+	"mail_change_subject":    "Confirm your new email address",
+	"mail_change_body":       "Hello %s,\n\nPlease confirm %s as the new email address of your account by opening the link below within %s:\n\n%s\n\nIf you did not request this change, you can ignore this email.\n",
+
+	// Chat, knowledge base and attachments
+	"content_blocked_categories": "content blocked by moderation policy: %s",
+	"knowledge_not_configured":   "knowledge base is not configured",
+	"embeddings_not_configured":  "embeddings are not configured",
+	"document_too_large":         "document is too large",
+	"document_empty":             "document contains no text",
+	"too_many_attachments":       "at most %d attachments can be referenced per message",
+	"attachment_requires_auth":   "referencing uploaded attachments requires authentication",
+	"attachment_in_use":          "attachment %d is already used in another message",
+	"too_many_images":            "at most %d images are allowed per message",
+	"unsupported_image_type":     "%s: unsupported image type %s",
+	"image_url_too_long":         "image url exceeds %d characters",
+	"image_url_invalid":          "image url %q must be an absolute http(s) url",
+	"unsupported_image_url":      "image url %q is not a supported image type",
+}
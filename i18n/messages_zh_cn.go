@@ -0,0 +1,121 @@
+package i18n
+
+var messagesZhCN = map[string]string{
+	// Problem titles, keyed by error code
+	"validation_failed":       "请求参数不合法",
+	"not_authenticated":       "未登录",
+	"not_authorized":          "无权访问",
+	"not_found":               "资源不存在",
+	"method_not_allowed":      "不支持的请求方法",
+	"already_exists":          "资源已存在",
+	"conflict":                "资源冲突",
+	"content_filtered":        "内容被拦截",
+	"rate_limited":            "请求过于频繁",
+	"repository_error":        "数据库错误",
+	"token_generation_failed": "令牌生成失败",
+	"upstream_error":          "AI 服务错误",
+	"service_unavailable":     "服务暂时不可用",
+	"internal_error":          "服务器内部错误",
+
+	// Default messages of the error types
+	"record_not_found":          "记录不存在",
+	"validation_error":          "参数校验失败",
+	"resource_already_exists":   "资源已存在",
+	"repository_operation":      "数据库操作失败",
+	"not_authenticated_error":   "未登录或登录已失效",
+	"token_generator_error":     "无法生成访问令牌",
+	"not_authorized_error":      "无权执行该操作",
+	"content_blocked":           "内容被审核策略拦截",
+	"method_not_allowed_error":  "路由不支持该请求方法",
+	"service_unavailable_error": "服务暂时不可用",
+	"upstream_service_error":    "AI 服务调用失败",
+	"conflict_error":            "请求与关联数据冲突",
+	"rate_limited_error":        "请求过于频繁，请稍后再试",
+	"unknown_error":             "发生未知错误",
+
+	// Request validation
+	"request_validation_failed": "请求参数校验失败",
+	"malformed_json":            "请求体不是合法的 JSON",
+	"field_type":                "%s 的类型应为 %s",
+	"username_charset":          "%s只能包含字母、数字、下划线、连字符和点，且必须以字母或数字开头",
+	"password_strength":         "%s必须同时包含字母和数字",
+	"route_not_found":           "路由不存在",
+	"id_required":               "缺少ID参数",
+	"session_id_required":       "会话ID不能为空",
+	"format_required":           "导出格式不能为空",
+	"file_required":             "请上传文件",
+	"attachment_too_large":      "文件过大",
+	"user_id_invalid":           "用户ID不合法",
+	"share_id_invalid":          "分享链接ID不合法",
+	"flag_id_invalid":           "审核记录ID不合法",
+	"document_id_invalid":       "文档ID不合法",
+	"attachment_id_invalid":     "附件ID不合法",
+	"api_key_id_invalid":        "API 密钥ID不合法",
+
+	// Users and authentication
+	"user_not_found":            "用户不存在",
+	"deleted_user_not_found":    "已删除的用户不存在",
+	"account_deactivated":       "该账户已被停用",
+	"invalid_credentials":       "用户名或密码错误",
+	"invalid_token":             "访问令牌无效或已过期",
+	"role_unknown":              "角色不存在：%s",
+	"api_key_invalid":           "API 密钥无效、已吊销或已过期",
+	"api_key_not_found":         "API 密钥不存在",
+	"api_key_limit":             "每个用户最多只能有 %d 个有效的 API 密钥",
+	"api_key_scope_missing":     "API 密钥没有 %s 权限范围",
+	"api_key_not_allowed":       "该接口不能使用 API 密钥访问",
+	"oidc_provider_unknown":     "身份提供方 %s 不存在",
+	"oidc_provider_unavailable": "身份提供方 %s 暂时不可用",
+	"oidc_state_invalid":        "登录会话无效或已过期，请重新登录",
+	"oidc_login_failed":         "通过身份提供方登录失败：%s",
+	"oidc_login_denied":         "身份提供方没有完成登录",
+	"oidc_email_missing":        "身份提供方没有返回邮箱地址",
+	"oidc_email_in_use":         "邮箱 %s 已被其他账户使用，请先使用密码登录并验证邮箱，之后再使用单点登录",
+	"oidc_account_not_found":    "该身份没有关联任何账户",
+	"erasure_pending":           "该用户已有待处理的删除请求",
+	"erasure_not_found":         "删除请求不存在",
+	"erasure_id_invalid":        "删除请求ID不合法",
+	"erasure_status_invalid":    "状态必须是 pending 或 completed",
+
+	// Database
+	"field_already_exists":       "%s 已存在",
+	"field_required":             "%s 不能为空",
+	"related_record_missing":     "%s 引用的记录不存在",
+	"record_still_referenced":    "记录仍被其他数据引用",
+	"foreign_key_violation":      "请求引用了不存在的记录或仍在使用的记录",
+	"check_constraint_violation": "请求违反了数据约束",
+	"database_busy":              "数据库繁忙，请重试",
+	"database_unavailable":       "数据库不可用",
+
+	// Account emails
+	"invalid_account_token":  "链接无效或已过期",
+	"email_already_verified": "邮箱已验证",
+	"email_resend_too_soon":  "请在 %d 秒后再请求发送邮件",
+	"email_daily_limit":      "今天请求发送的邮件过多，请明天再试",
+	"email_unchanged":        "新邮箱与当前邮箱相同",
+	"password_incorrect":     "密码错误",
+	"mail_delivery_failed":   "邮件发送失败",
+	"duration_hours":         "%d 小时",
+	"duration_minutes":       "%d 分钟",
+	"mail_verify_subject":    "验证邮箱地址",
+	"mail_verify_body":       "%s，您好：\n\n请在 %s内打开以下链接验证您的邮箱地址：\n\n%s\n\n如果您没有注册账户，请忽略本邮件。\n",
+	"mail_reset_subject":     "重置密码",
+	"mail_reset_body":        "%s，您好：\n\n我们收到了重置您账户密码的请求。请在 %s内打开以下链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略本邮件，您的密码不会改变。\n",
+	"mail_change_subject":    "确认新邮箱地址",
+	"mail_change_body":       "%s，您好：\n\n请在 %[3]s内打开以下链接，确认将 %[2]s 设为您账户的新邮箱：\n\n%[4]s\n\n如果这不是您本人的操作，请忽略本邮件。\n",
+
+	// Chat, knowledge base and attachments
+	"content_blocked_categories": "内容被审核策略拦截：%s",
+	"knowledge_not_configured":   "未配置知识库",
+	"embeddings_not_configured":  "未配置向量模型",
+	"document_too_large":         "文档过大",
+	"document_empty":             "文档中没有文本内容",
+	"too_many_attachments":       "每条消息最多引用 %d 个附件",
+	"attachment_requires_auth":   "引用已上传的附件需要登录",
+	"attachment_in_use":          "附件 %d 已被其他消息使用",
+	"too_many_images":            "每条消息最多附带 %d 张图片",
+	"unsupported_image_type":     "%s：不支持的图片类型 %s",
+	"image_url_too_long":         "图片地址不能超过 %d 个字符",
+	"image_url_invalid":          "图片地址 %q 必须是完整的 http(s) 地址",
+	"unsupported_image_url":      "图片地址 %q 不是支持的图片类型",
+}
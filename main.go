@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -13,10 +21,14 @@ import (
 	"github.com/thoulee21/go-learn/controllers"
 	"github.com/thoulee21/go-learn/controllers/user"
 	_ "github.com/thoulee21/go-learn/docs"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/metrics"
 	"github.com/thoulee21/go-learn/middlewares"
 	"github.com/thoulee21/go-learn/models"
 	"github.com/thoulee21/go-learn/routes"
 	"github.com/thoulee21/go-learn/services"
+	"github.com/thoulee21/go-learn/tracing"
+	"github.com/thoulee21/go-learn/validation"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -26,7 +38,16 @@ var db *gorm.DB
 
 func init() {
 	// 加载.env文件
-	if err := godotenv.Load(); err != nil {
+	envErr := godotenv.Load()
+
+	// 配置结构化日志，标准库 log 的输出也会经由 slog 输出
+	logger, err := middlewares.NewLogger(os.Stdout)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
+	}
+	slog.SetDefault(logger)
+
+	if envErr != nil {
 		log.Println("No .env file found, loading from environment")
 	} else {
 		log.Println("Loading .env file")
@@ -44,7 +65,6 @@ func init() {
 		user, password, host, port, database,
 	)
 
-	var err error
 	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	if err != nil {
 		// 如果连接MySQL失败，则使用SQLite数据库
@@ -56,16 +76,63 @@ func init() {
 		log.Println("Using SQLite database")
 	}
 
-	dbErr := db.AutoMigrate(&models.ChatMessage{}, models.User{})
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		panic(fmt.Sprintf("Failed to register database metrics: %v", err))
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		panic(fmt.Sprintf("Failed to register database tracing: %v", err))
+	}
+
+	dbErr := db.AutoMigrate(&models.ChatMessage{}, models.User{}, &models.ShareLink{}, &models.Attachment{},
+		&models.KnowledgeDocument{}, &models.KnowledgeChunk{}, &models.ModerationFlag{},
+		&models.ResponseCacheEntry{}, &models.EmailDelivery{}, &models.Permission{}, &models.Role{},
+		&models.APIKey{}, &models.UserIdentity{}, &models.ErasureRequest{}, &models.AuditLog{})
 	if dbErr != nil {
 		panic("failed to migrate database")
 	}
 }
 
+// @securityDefinitions.apikey	BearerAuth
+// @in							header
+// @name						Authorization
+// @description				Bearer 访问令牌，通过 /user/login 获取。调用 /chat、/chat/stream 和 /embeddings 时也可以使用 API 密钥
+//
+// @securityDefinitions.apikey	APIKeyAuth
+// @in							header
+// @name						X-API-Key
+// @description				API 密钥，通过 /api-keys 创建，只能访问密钥权限范围内的接口
 func main() {
-	r := gin.Default()
+	// 收到 SIGINT 或 SIGTERM 时开始关闭服务
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// workers 跟踪使用数据库的后台任务，关闭数据库前等待它们退出
+	var workers sync.WaitGroup
+
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize tracing: %v", err))
+	}
+
+	// 请求日志由 RequestLogger 记录，不使用 gin 默认的文本日志
+	r := gin.New()
+	r.Use(gin.CustomRecovery(middlewares.RecoverWithProblem))
 
-	aiService, err := services.NewAIService()
+	// 校验错误使用 JSON 字段名，并按 Accept-Language 翻译；注册自定义校验规则
+	if err := validation.Setup(); err != nil {
+		panic(fmt.Sprintf("Failed to initialize validator: %v", err))
+	}
+
+	toolRegistry := services.NewToolRegistry(0)
+	if err := services.RegisterBuiltinTools(toolRegistry); err != nil {
+		panic(fmt.Sprintf("Failed to register tools: %v", err))
+	}
+
+	responseCache, err := services.NewResponseCache(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize response cache: %v", err))
+	}
+
+	aiService, err := services.NewAIService(toolRegistry, responseCache)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize AI service: %v", err))
 	}
@@ -75,22 +142,198 @@ func main() {
 		panic(fmt.Sprintf("Failed to initialize User service: %v", err))
 	}
 
+	authService, err := services.NewAuthService(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Auth service: %v", err))
+	}
+
+	rbacService, err := services.NewRBACService(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize RBAC service: %v", err))
+	}
+
+	auditService, err := services.NewAuditService(db, authService)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Audit service: %v", err))
+	}
+	// 与上次启动时的配置比较，记录配置的变化
+	if err := auditService.RecordConfiguration(ctx); err != nil {
+		panic(fmt.Sprintf("Failed to record configuration: %v", err))
+	}
+
+	oidcService, err := services.NewOIDCService(db, authService)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize OIDC service: %v", err))
+	}
+
+	apiKeyService, err := services.NewAPIKeyService(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize API key service: %v", err))
+	}
+
+	mailer, err := services.NewMailer()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize mailer: %v", err))
+	}
+
+	accountService, err := services.NewAccountService(db, authService, mailer)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Account service: %v", err))
+	}
+
+	conversationService, err := services.NewConversationService(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Conversation service: %v", err))
+	}
+
+	shareService, err := services.NewShareService(db, conversationService)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Share service: %v", err))
+	}
+
+	storage, err := services.NewStorage()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize storage: %v", err))
+	}
+
+	attachmentService, err := services.NewAttachmentService(db, storage, conversationService)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Attachment service: %v", err))
+	}
+	attachmentService.StartOrphanCleanup(ctx, time.Hour, &workers)
+
+	privacyService, err := services.NewPrivacyService(db, attachmentService, conversationService, responseCache)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Privacy service: %v", err))
+	}
+	privacyService.StartErasureWorker(ctx, 10*time.Minute, &workers)
+
+	vectorStore, err := services.NewVectorStore(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize vector store: %v", err))
+	}
+
+	knowledgeService, err := services.NewKnowledgeService(db, aiService, vectorStore)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Knowledge service: %v", err))
+	}
+
+	moderationService, err := services.NewModerationService(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Moderation service: %v", err))
+	}
+
+	piiRedactor, err := services.NewPIIRedactor()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize PII redaction: %v", err))
+	}
+
+	healthService, err := services.NewHealthService(db, aiService)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Health service: %v", err))
+	}
+
+	logConfig, err := middlewares.RequestLoggerConfigFromEnv(piiRedactor)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize request logging: %v", err))
+	}
+
+	r.Use(middlewares.Tracing)
+	r.Use(middlewares.RequestLogger(slog.Default(), logConfig))
+	r.Use(middlewares.Metrics)
 	r.Use(cors.Default())
 	r.Use(middlewares.ErrorHandler())
-	r.Use(middlewares.GinBodyLogMiddleware)
 	r.Use(middlewares.CommonHeaders)
 
-	chatController := &controllers.ChatController{DB: db, AIService: aiService}
-	userController := &user.UserController{DB: db, UserService: userService}
+	// Prometheus 指标。设置 METRICS_ADDR 时只在该地址上提供，设置 METRICS_TOKEN 时抓取需要携带 Bearer 令牌。
+	// 路由在 Authenticate 之前注册，抓取使用的令牌不会被当作用户令牌校验
+	metricsHandler := metrics.Handler(os.Getenv("METRICS_TOKEN"))
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		if err := startMetricsServer(ctx, addr, metricsHandler, &workers); err != nil {
+			panic(fmt.Sprintf("Failed to initialize metrics server: %v", err))
+		}
+	} else {
+		r.GET("/metrics", gin.WrapH(metricsHandler))
+	}
+
+	r.Use(middlewares.Authenticate(authService, apiKeyService))
+
+	chatController := &controllers.ChatController{
+		DB:                  db,
+		AIService:           aiService,
+		ConversationService: conversationService,
+		KnowledgeService:    knowledgeService,
+		AttachmentService:   attachmentService,
+		ModerationService:   moderationService,
+		PIIRedactor:         piiRedactor,
+	}
+	userController := &user.UserController{DB: db, UserService: userService, AuthService: authService, AccountService: accountService, AuditService: auditService}
+	accountController := &user.AccountController{AccountService: accountService, AuditService: auditService}
+	roleController := &user.RoleController{RBACService: rbacService, AuditService: auditService}
+	apiKeyController := &user.APIKeyController{APIKeyService: apiKeyService, AuditService: auditService}
+	oidcController := &user.OIDCController{OIDCService: oidcService, AuditService: auditService}
+	privacyController := &user.PrivacyController{PrivacyService: privacyService, AuditService: auditService}
+	conversationController := &controllers.ConversationController{ConversationService: conversationService}
+	shareController := &controllers.ShareController{ShareService: shareService, AuditService: auditService}
+	knowledgeController := &controllers.KnowledgeController{KnowledgeService: knowledgeService}
+	embeddingController := &controllers.EmbeddingController{AIService: aiService}
+	attachmentController := &controllers.AttachmentController{AttachmentService: attachmentService}
+	moderationController := &controllers.ModerationController{ModerationService: moderationService}
+	healthController := &controllers.HealthController{HealthService: healthService}
+	auditController := &controllers.AuditController{AuditService: auditService}
 
 	routes.SetupChatRoutes(r, chatController)
-	routes.SetupUserRoutes(r, userController)
+	routes.SetupUserRoutes(r, userController, rbacService)
+	routes.SetupRoleRoutes(r, roleController, rbacService)
+	routes.SetupAccountRoutes(r, accountController)
+	routes.SetupAPIKeyRoutes(r, apiKeyController, rbacService)
+	routes.SetupOIDCRoutes(r, oidcController)
+	routes.SetupPrivacyRoutes(r, privacyController, rbacService)
+	routes.SetupConversationRoutes(r, conversationController)
+	routes.SetupShareRoutes(r, shareController)
+	routes.SetupKnowledgeRoutes(r, knowledgeController, rbacService)
+	routes.SetupEmbeddingRoutes(r, embeddingController)
+	routes.SetupAttachmentRoutes(r, attachmentController)
+	routes.SetupModerationRoutes(r, moderationController, rbacService)
+	routes.SetupAuditRoutes(r, auditController, rbacService)
+	routes.SetupHealthRoutes(r, healthController)
+
+	// 未匹配的路由同样返回 problem details 格式的错误
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(func(c *gin.Context) {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "route_not_found"))
+	})
+	r.NoMethod(func(c *gin.Context) {
+		_ = c.Error(domainErrors.NewAppErrorWithType(domainErrors.MethodNotAllowed))
+	})
 
 	// Swagger 文档
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// 默认在8080端口启动服务
-	if err := r.Run(); err != nil {
+	srv, err := newServer(r)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize server: %v", err))
+	}
+	if err := srv.run(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Could not start server: %s\n", err)
 	}
+	// 再次收到信号时直接退出
+	stop()
+	// 后台任务随 ctx 取消退出，等待它们和后台发送的邮件完成当前的数据库操作
+	workers.Wait()
+	accountService.Wait()
+
+	// 所有请求结束后关闭数据库连接池并导出剩余的追踪数据
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	log.Println("Server stopped")
 }
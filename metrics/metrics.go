@@ -0,0 +1,105 @@
+// Package metrics defines the Prometheus metrics exported on /metrics, or on METRICS_ADDR when it is set.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// llmBuckets covers fast cache-like responses up to long multi-tool generations.
+var llmBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120}
+
+var (
+	// HTTPRequests counts finished HTTP requests by route and status code.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency, including the full duration of streamed responses.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// InflightStreams is the number of streaming chat responses currently being generated.
+	InflightStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "chat_inflight_streams",
+		Help: "Number of streaming chat responses in progress.",
+	})
+
+	// LLMRequestDuration observes the latency of each call to the model provider.
+	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llm_request_duration_seconds",
+		Help:    "Latency of model provider calls by operation and deployment.",
+		Buckets: llmBuckets,
+	}, []string{"operation", "deployment"})
+
+	// LLMTimeToFirstToken observes how long a streaming call waits for its first content token.
+	LLMTimeToFirstToken = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llm_time_to_first_token_seconds",
+		Help:    "Time from sending a streaming request to receiving the first content token.",
+		Buckets: llmBuckets,
+	}, []string{"deployment"})
+
+	// LLMTokens observes prompt and completion tokens used per chat request.
+	LLMTokens = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llm_tokens_per_request",
+		Help:    "Tokens used per chat request by type (prompt, completion).",
+		Buckets: prometheus.ExponentialBuckets(16, 2, 12),
+	}, []string{"deployment", "type"})
+
+	// LLMErrors counts failed model provider calls by error class.
+	LLMErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_errors_total",
+		Help: "Failed model provider calls by operation and error class.",
+	}, []string{"operation", "class"})
+
+	// DBQueryDuration observes the latency of database queries issued through GORM.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency by operation and table.",
+		Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"operation", "table"})
+)
+
+// ErrorClass groups provider errors into a small, fixed set of label values.
+func ErrorClass(err error) string {
+	var respErr *azcore.ResponseError
+	var filterErr *azopenai.ContentFilterResponseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &filterErr):
+		return "content_filter"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &respErr):
+		switch {
+		case respErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case respErr.StatusCode == http.StatusUnauthorized || respErr.StatusCode == http.StatusForbidden:
+			return "auth"
+		case respErr.StatusCode >= 500:
+			return "server"
+		default:
+			return "client"
+		}
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	default:
+		return "other"
+	}
+}
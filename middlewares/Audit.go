@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+// Audit appends entry to the audit log, filling in the acting user and API key, the
+// client IP and the request ID from the current request. An ActorID already set on
+// entry is kept, e.g. for logins where the request itself is anonymous.
+//
+// Failing to write the entry is logged but does not fail the request, since the
+// audited change has already been made.
+func Audit(c *gin.Context, auditService *services.AuditService, entry models.AuditLog) {
+	if entry.ActorID == nil {
+		entry.ActorID = CurrentUserIDPtr(c)
+	}
+	entry.ActorAPIKeyID = CurrentAPIKeyID(c)
+	entry.IP = c.ClientIP()
+	entry.RequestID = RequestID(c)
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := auditService.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "failed to record audit log",
+			slog.String("action", entry.Action), slog.String("request_id", entry.RequestID), slog.Any("error", err))
+	}
+}
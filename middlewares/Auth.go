@@ -0,0 +1,226 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+const (
+	userIDKey          = "userID"
+	permissionsKey     = "permissions"
+	presentedAPIKeyKey = "presentedAPIKey"
+	apiKeyKey          = "apiKey"
+	apiKeyTokensKey    = "apiKeyTokens"
+)
+
+// Authenticate parses an optional bearer token and stores the user ID in the context.
+// Requests without a token pass through anonymously; invalid tokens are rejected.
+//
+// API keys are accepted as bearer tokens or in the X-API-Key header. A valid key does not
+// authenticate the request by itself: only routes that use AllowAPIKey act on behalf of
+// the key's owner, and usage of the key is recorded once the request completes.
+func Authenticate(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		key := c.GetHeader("X-API-Key")
+		if header == "" && key == "" {
+			c.Next()
+			return
+		}
+
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if header != "" && !found {
+			abortUnauthenticated(c)
+			return
+		}
+		token = strings.TrimSpace(token)
+		if key == "" && services.IsAPIKey(token) {
+			key = token
+		}
+		if key != "" {
+			authenticateAPIKey(c, apiKeyService, strings.TrimSpace(key))
+			return
+		}
+
+		userID, err := authService.ParseToken(token)
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Set(userIDKey, userID)
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeyService *services.APIKeyService, key string) {
+	apiKey, err := apiKeyService.Authenticate(c.Request.Context(), key)
+	if err != nil {
+		_ = c.Error(err)
+		c.Abort()
+		return
+	}
+	c.Set(presentedAPIKeyKey, apiKey)
+	c.Next()
+
+	if _, accepted := CurrentAPIKey(c); !accepted {
+		return
+	}
+	// Streaming responses may outlive the request context, so usage is recorded without it.
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := apiKeyService.RecordUsage(ctx, apiKey.ID, c.GetInt(apiKeyTokensKey)); err != nil {
+		slog.WarnContext(ctx, "failed to record API key usage", slog.Uint64("api_key_id", uint64(apiKey.ID)), slog.Any("error", err))
+	}
+}
+
+// AllowAPIKey lets requests authenticated with an API key that has scope act on behalf of
+// the key's owner. Routes without it treat API key requests as anonymous.
+func AllowAPIKey(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(presentedAPIKeyKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		apiKey := v.(*models.APIKey)
+		if !apiKey.HasScope(scope) {
+			_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthorized, "api_key_scope_missing", scope))
+			c.Abort()
+			return
+		}
+		c.Set(apiKeyKey, apiKey)
+		c.Set(userIDKey, apiKey.UserID)
+		c.Next()
+	}
+}
+
+// CurrentAPIKey returns the API key the request is authenticated with, if any.
+func CurrentAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	v, ok := c.Get(apiKeyKey)
+	if !ok {
+		return nil, false
+	}
+	apiKey, ok := v.(*models.APIKey)
+	return apiKey, ok
+}
+
+// CurrentAPIKeyID returns the ID of the API key the request is authenticated with, or nil.
+func CurrentAPIKeyID(c *gin.Context) *uint {
+	if apiKey, ok := CurrentAPIKey(c); ok {
+		return &apiKey.ID
+	}
+	return nil
+}
+
+// AddTokenUsage attributes tokens consumed by the request to its API key, if any.
+func AddTokenUsage(c *gin.Context, tokens int) {
+	if _, ok := CurrentAPIKey(c); ok {
+		c.Set(apiKeyTokensKey, c.GetInt(apiKeyTokensKey)+tokens)
+	}
+}
+
+// abortUnauthenticated rejects a request that requires a signed-in user. Requests that
+// carry an API key are told the key is not accepted here rather than to sign in.
+func abortUnauthenticated(c *gin.Context) {
+	if _, ok := c.Get(presentedAPIKeyKey); ok {
+		_ = c.Error(domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthorized, "api_key_not_allowed"))
+	} else {
+		_ = c.Error(domainErrors.NewAppErrorWithType(domainErrors.NotAuthenticated))
+	}
+	c.Abort()
+}
+
+// RequireAuth rejects anonymous requests.
+func RequireAuth(c *gin.Context) {
+	if _, ok := CurrentUserID(c); !ok {
+		abortUnauthenticated(c)
+		return
+	}
+	c.Next()
+}
+
+// CurrentUserID returns the authenticated user ID, if any.
+func CurrentUserID(c *gin.Context) (uint, bool) {
+	v, ok := c.Get(userIDKey)
+	if !ok {
+		return 0, false
+	}
+	userID, ok := v.(uint)
+	return userID, ok
+}
+
+// CurrentUserIDPtr returns the authenticated user ID as a pointer, or nil for anonymous requests.
+func CurrentUserIDPtr(c *gin.Context) *uint {
+	if userID, ok := CurrentUserID(c); ok {
+		return &userID
+	}
+	return nil
+}
+
+// RequirePermission rejects requests from users that lack any of the given permissions.
+func RequirePermission(rbacService *services.RBACService, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentUserID(c); !ok {
+			abortUnauthenticated(c)
+			return
+		}
+		for _, permission := range permissions {
+			allowed, err := HasPermission(c, rbacService, permission)
+			if err != nil {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+			if !allowed {
+				_ = c.Error(domainErrors.NewAppErrorWithType(domainErrors.NotAuthorized))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets users access the user named by the route parameter param
+// when it is themselves, and requires permission for anyone else.
+func RequireSelfOrPermission(rbacService *services.RBACService, param, permission string) gin.HandlerFunc {
+	requirePermission := RequirePermission(rbacService, permission)
+	return func(c *gin.Context) {
+		userID, ok := CurrentUserID(c)
+		if !ok {
+			abortUnauthenticated(c)
+			return
+		}
+		if id, err := strconv.ParseUint(c.Param(param), 10, 64); err == nil && uint(id) == userID {
+			c.Next()
+			return
+		}
+		requirePermission(c)
+	}
+}
+
+// HasPermission reports whether the authenticated user has permission. The user's
+// permissions are loaded once per request.
+func HasPermission(c *gin.Context, rbacService *services.RBACService, permission string) (bool, error) {
+	userID, ok := CurrentUserID(c)
+	if !ok {
+		return false, nil
+	}
+	if cached, ok := c.Get(permissionsKey); ok {
+		return cached.(map[string]bool)[permission], nil
+	}
+	permissions, err := rbacService.Permissions(c.Request.Context(), userID)
+	if err != nil {
+		return false, err
+	}
+	c.Set(permissionsKey, permissions)
+	return permissions[permission], nil
+}
@@ -7,7 +7,8 @@ func CommonHeaders(c *gin.Context) {
 	c.Header("Access-Control-Allow-Credentials", "true")
 	c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, DELETE, GET, PUT")
 	c.Header("Access-Control-Allow-Headers",
-		"Content-Type, Depth, User-Agent, X-File-Size, X-Requested-With, If-Modified-Since, X-File-CompanyName, Cache-Control")
+		"Content-Type, Depth, User-Agent, X-File-Size, X-Requested-With, If-Modified-Since, X-File-CompanyName, Cache-Control, X-Request-ID")
+	c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Cache, X-Total-Count")
 	c.Header("X-Frame-Options", "SAMEORIGIN")
 	c.Header("Cache-Control", "no-cache, no-store")
 	c.Header("Pragma", "no-cache")
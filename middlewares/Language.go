@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/i18n"
+)
+
+const languageKey = "language"
+
+// Language returns the response language negotiated from the request's Accept-Language header.
+func Language(c *gin.Context) string {
+	if lang := c.GetString(languageKey); lang != "" {
+		return lang
+	}
+	lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Set(languageKey, lang)
+	return lang
+}
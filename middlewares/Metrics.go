@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/metrics"
+)
+
+// Metrics records request counts and latency by route and status code.
+// Like RequestLogger, it must be registered before ErrorHandler to see the final status.
+func Metrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	// Unmatched routes share one label so arbitrary paths cannot create unbounded label values
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
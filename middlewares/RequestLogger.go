@@ -0,0 +1,283 @@
+package middlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/thoulee21/go-learn/services"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+
+	defaultLogBodyMaxBytes = 4096
+)
+
+// Body capture modes for LOG_BODY.
+const (
+	LogBodyOff    = "off"
+	LogBodyErrors = "errors"
+	LogBodyAll    = "all"
+)
+
+// defaultRedactedFields are always masked in logged JSON and form bodies.
+var defaultRedactedFields = []string{
+	"password", "hash_password", "new_password", "old_password",
+	"token", "access_token", "refresh_token", "id_token", "secret", "client_secret", "api_key",
+}
+
+// NewLogger builds the application logger from LOG_LEVEL (debug, info, warn, error)
+// and LOG_FORMAT (json, text).
+func NewLogger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %s", v)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch format := os.Getenv("LOG_FORMAT"); format {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unsupported LOG_FORMAT: %s", format)
+	}
+}
+
+// RequestLoggerConfig controls what the request logger records.
+type RequestLoggerConfig struct {
+	// Body is one of LogBodyOff, LogBodyErrors (only for 4xx/5xx responses) or LogBodyAll.
+	Body string
+	// BodyMaxBytes limits how much of each body is captured.
+	BodyMaxBytes int
+	// RedactFields are JSON/form field names whose values are masked in logged bodies.
+	RedactFields []string
+	// PIIRedactor, when set, replaces personal information in the logged URI and bodies.
+	PIIRedactor *services.PIIRedactor
+}
+
+// RequestLoggerConfigFromEnv reads LOG_BODY, LOG_BODY_MAX_BYTES and LOG_REDACT_FIELDS.
+// LOG_REDACT_FIELDS is a comma-separated list added to the default sensitive fields.
+func RequestLoggerConfigFromEnv(redactor *services.PIIRedactor) (RequestLoggerConfig, error) {
+	config := RequestLoggerConfig{
+		Body:         LogBodyErrors,
+		BodyMaxBytes: defaultLogBodyMaxBytes,
+		RedactFields: defaultRedactedFields,
+		PIIRedactor:  redactor,
+	}
+	if v := os.Getenv("LOG_BODY"); v != "" {
+		if v != LogBodyOff && v != LogBodyErrors && v != LogBodyAll {
+			return config, fmt.Errorf("LOG_BODY must be one of off, errors, all")
+		}
+		config.Body = v
+	}
+	if v := os.Getenv("LOG_BODY_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return config, errors.New("LOG_BODY_MAX_BYTES must be a positive integer")
+		}
+		config.BodyMaxBytes = n
+	}
+	for _, field := range strings.Split(os.Getenv("LOG_REDACT_FIELDS"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			config.RedactFields = append(config.RedactFields, field)
+		}
+	}
+	return config, nil
+}
+
+// captureWriter keeps the first max bytes of the response for logging.
+// Event streams are passed through without being captured.
+type captureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+	max  int
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.body != nil && !isEventStream(w.Header().Get("Content-Type")) {
+		if remaining := w.max - w.body.Len(); remaining > 0 {
+			w.body.Write(b[:min(len(b), remaining)])
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection.
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func isEventStream(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/event-stream"
+}
+
+// RequestLogger assigns each request an ID, echoed in the X-Request-ID response header,
+// and writes one structured log record per request once the response is complete.
+// It must be registered before ErrorHandler so that it sees the final status code.
+func RequestLogger(logger *slog.Logger, config RequestLoggerConfig) gin.HandlerFunc {
+	redactFields := fieldRedactor(config.RedactFields)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set(requestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+
+		// Only the first BodyMaxBytes are captured for the log; the rest is still passed on to the handlers.
+		var requestBody []byte
+		requestTruncated := false
+		writer := &captureWriter{ResponseWriter: c.Writer, max: config.BodyMaxBytes}
+		if config.Body != LogBodyOff {
+			writer.body = &bytes.Buffer{}
+			if c.Request.Body != nil {
+				buf := make([]byte, config.BodyMaxBytes+1)
+				n, err := io.ReadFull(c.Request.Body, buf)
+				if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+					logger.Warn("failed to read request body", "request_id", requestID, "error", err)
+				}
+				c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf[:n]), c.Request.Body))
+				requestBody = buf[:min(n, config.BodyMaxBytes)]
+				requestTruncated = n > config.BodyMaxBytes
+			}
+		}
+		c.Writer = writer
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("uri", config.PIIRedactor.Redact(c.Request.RequestURI)),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes_out", max(c.Writer.Size(), 0)),
+		}
+		if userID, ok := CurrentUserID(c); ok {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(userID)))
+		}
+		if apiKey, ok := CurrentAPIKey(c); ok {
+			attrs = append(attrs, slog.Uint64("api_key_id", uint64(apiKey.ID)))
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+		streaming := isEventStream(c.Writer.Header().Get("Content-Type"))
+		if streaming {
+			attrs = append(attrs, slog.Bool("stream", true))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		if config.Body == LogBodyAll || (config.Body == LogBodyErrors && status >= 400) {
+			redact := func(s string) string { return config.PIIRedactor.Redact(redactFields(s)) }
+			if body := loggableBody(c.ContentType(), requestBody, requestTruncated); body != "" {
+				attrs = append(attrs, slog.String("request_body", redact(body)))
+			}
+			if !streaming {
+				responseBody := loggableBody(c.Writer.Header().Get("Content-Type"), writer.body.Bytes(), c.Writer.Size() > config.BodyMaxBytes)
+				if responseBody != "" {
+					attrs = append(attrs, slog.String("response_body", redact(responseBody)))
+				}
+			}
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RequestID returns the ID assigned to the current request.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID accepts client-supplied IDs that are short and printable.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldRedactor masks the values of the given fields in JSON and form-encoded bodies.
+// It works on truncated bodies, which a JSON parser would reject.
+func fieldRedactor(fields []string) func(string) string {
+	if len(fields) == 0 {
+		return func(s string) string { return s }
+	}
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	names := strings.Join(quoted, "|")
+	jsonField := regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^,}\s]+)`)
+	formField := regexp.MustCompile(`(?i)(^|&)((?:` + names + `)=)[^&]*`)
+
+	return func(s string) string {
+		s = jsonField.ReplaceAllString(s, `${1}"[REDACTED]"`)
+		return formField.ReplaceAllString(s, `${1}${2}[REDACTED]`)
+	}
+}
+
+// loggableBody returns a body suitable for logging. Binary and multipart content is omitted.
+func loggableBody(contentType string, body []byte, truncated bool) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case len(body) == 0:
+		return ""
+	case mediaType == "multipart/form-data":
+		return "[multipart body omitted]"
+	case mediaType != "" && !strings.HasPrefix(mediaType, "text/") && !strings.HasSuffix(mediaType, "json") &&
+		mediaType != "application/x-www-form-urlencoded":
+		return fmt.Sprintf("[%s body omitted]", mediaType)
+	case !utf8.Valid(body) && !truncated:
+		return "[binary body omitted]"
+	}
+	if truncated {
+		return string(body) + "...(truncated)"
+	}
+	return string(body)
+}
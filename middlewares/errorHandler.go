@@ -1,38 +1,158 @@
 package middlewares
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/i18n"
 )
 
+// problemContentType is the media type of RFC 7807 responses.
+const problemContentType = "application/problem+json"
+
+type problemType struct {
+	status int
+	code   string
+	// public reports whether the error message may be shown to clients as the detail.
+	// Server-side errors use a generic detail so that internals do not leak.
+	public bool
+}
+
+var problemTypes = map[string]problemType{
+	domainErrors.ValidationError:       {http.StatusBadRequest, "validation_failed", true},
+	domainErrors.NotAuthenticated:      {http.StatusUnauthorized, "not_authenticated", true},
+	domainErrors.NotAuthorized:         {http.StatusForbidden, "not_authorized", true},
+	domainErrors.NotFound:              {http.StatusNotFound, "not_found", true},
+	domainErrors.MethodNotAllowed:      {http.StatusMethodNotAllowed, "method_not_allowed", true},
+	domainErrors.ResourceAlreadyExists: {http.StatusConflict, "already_exists", true},
+	domainErrors.Conflict:              {http.StatusConflict, "conflict", true},
+	domainErrors.ContentFiltered:       {http.StatusUnprocessableEntity, "content_filtered", true},
+	domainErrors.RateLimited:           {http.StatusTooManyRequests, "rate_limited", true},
+	domainErrors.RepositoryError:       {http.StatusInternalServerError, "repository_error", false},
+	domainErrors.TokenGeneratorError:   {http.StatusInternalServerError, "token_generation_failed", false},
+	domainErrors.UpstreamError:         {http.StatusBadGateway, "upstream_error", false},
+	domainErrors.ServiceUnavailable:    {http.StatusServiceUnavailable, "service_unavailable", false},
+}
+
+var internalProblem = problemType{http.StatusInternalServerError, "internal_error", false}
+
+// ErrorHandler renders the last error added to the context as a problem details response.
 func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
-		if len(c.Errors) > 0 {
-			err := c.Errors.Last().Err
-			var appErr *domainErrors.AppError
-			if errors.As(err, &appErr) {
-				switch appErr.Type {
-				case domainErrors.NotFound:
-					c.JSON(http.StatusNotFound, gin.H{"error": appErr.Error()})
-				case domainErrors.ValidationError:
-					c.JSON(http.StatusBadRequest, gin.H{"error": appErr.Error()})
-				case domainErrors.RepositoryError:
-					c.JSON(http.StatusInternalServerError, gin.H{"error": appErr.Error()})
-				case domainErrors.NotAuthenticated:
-					c.JSON(http.StatusUnauthorized, gin.H{"error": appErr.Error()})
-				case domainErrors.NotAuthorized:
-					c.JSON(http.StatusForbidden, gin.H{"error": appErr.Error()})
-				default:
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-				}
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			WriteProblem(c, NewProblem(c, c.Errors.Last().Err))
+		}
+	}
+}
+
+// NewProblem converts err into problem details for the current request, in the language
+// negotiated from Accept-Language. Errors that are not AppErrors are reported as internal
+// errors without details.
+func NewProblem(c *gin.Context, err error) domainErrors.Problem {
+	lang := Language(c)
+	pt := internalProblem
+	detail := ""
+	reason := ""
+	var fields []domainErrors.FieldError
+
+	var appErr *domainErrors.AppError
+	if errors.As(err, &appErr) {
+		if t, ok := problemTypes[appErr.Type]; ok {
+			pt = t
+		}
+		reason = appErr.Reason()
+		if pt.public {
+			detail = appErr.Error()
+			if appErr.MessageID != "" {
+				detail = i18n.T(lang, appErr.MessageID, appErr.Args...)
+			}
+		}
+		if appErr.Type == domainErrors.ValidationError {
+			fields, detail = bindingFieldErrors(appErr.Err, detail, lang)
+		}
+		if fields == nil {
+			for _, field := range appErr.Fields {
+				field.Message = detail
+				fields = append(fields, field)
 			}
 		}
 	}
+	title := i18n.T(lang, pt.code)
+	if detail == "" {
+		detail = title
+	}
+
+	return domainErrors.Problem{
+		Type:      domainErrors.ProblemTypeBaseURI + pt.code,
+		Title:     title,
+		Status:    pt.status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      pt.code,
+		Reason:    reason,
+		RequestID: RequestID(c),
+		Errors:    fields,
+	}
+}
+
+// WriteProblem writes problem as the response and aborts the request.
+func WriteProblem(c *gin.Context, problem domainErrors.Problem) {
+	c.Header("Content-Type", problemContentType)
+	c.Header("Content-Language", Language(c))
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// RecoverWithProblem is a gin.RecoveryFunc that reports panics as internal errors.
+func RecoverWithProblem(c *gin.Context, recovered any) {
+	WriteProblem(c, NewProblem(c, fmt.Errorf("panic: %v", recovered)))
+}
+
+// bindingFieldErrors extracts per-field errors from request binding failures, translated into lang.
+// The returned detail replaces validator's verbose message.
+func bindingFieldErrors(err error, detail, lang string) ([]domainErrors.FieldError, string) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		trans := i18n.Translator(lang)
+		fields := make([]domainErrors.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, domainErrors.FieldError{
+				Field:   fieldPath(fe),
+				Code:    fe.Tag(),
+				Message: fe.Translate(trans),
+			})
+		}
+		return fields, i18n.T(lang, "request_validation_failed")
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []domainErrors.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: i18n.T(lang, "field_type", typeErr.Field, typeErr.Type.String()),
+		}}, i18n.T(lang, "request_validation_failed")
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return nil, i18n.T(lang, "malformed_json")
+	}
+	return nil, detail
+}
+
+// fieldPath returns the dotted JSON path of a field without the top-level struct name.
+func fieldPath(fe validator.FieldError) string {
+	if _, path, found := strings.Cut(fe.Namespace(), "."); found {
+		return path
+	}
+	return fe.Field()
 }
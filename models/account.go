@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 账户邮件的用途，同时用于区分令牌和统计发送次数
+const (
+	EmailPurposeVerify        = "verify_email"
+	EmailPurposeResetPassword = "reset_password"
+	EmailPurposeChangeEmail   = "change_email"
+)
+
+// EmailDelivery 记录每次发送的账户邮件，用于限制发送频率
+type EmailDelivery struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"index:idx_email_deliveries_user_purpose;not null"`
+	Purpose   string    `json:"purpose" gorm:"index:idx_email_deliveries_user_purpose;size:32;not null"`
+	Email     string    `json:"email" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_email_deliveries_user_purpose"`
+}
+
+// AccountTokenRequest 提交邮件中的令牌，用于验证邮箱和确认修改邮箱
+type AccountTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"alice@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=72,password" example:"secret456"`
+}
+
+// ChangeEmailRequest 修改邮箱前需要再次输入当前密码，确认邮件发送到新邮箱
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email,max=254" example:"alice@example.org"`
+	Password string `json:"password" binding:"required"`
+}
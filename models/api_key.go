@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// API 密钥的权限范围
+const (
+	// APIKeyScopeChat 允许调用 /chat 和 /chat/stream
+	APIKeyScopeChat = "chat"
+	// APIKeyScopeEmbeddings 允许调用 /embeddings
+	APIKeyScopeEmbeddings = "embeddings"
+)
+
+// APIKey 供后端服务调用聊天和向量接口，以所属用户的身份访问。
+// 数据库只保存密钥的 SHA-256 摘要，完整密钥只在创建时返回一次
+type APIKey struct {
+	ID     uint   `json:"id" gorm:"primarykey"`
+	UserID uint   `json:"user_id" gorm:"index;not null"`
+	Name   string `json:"name" gorm:"size:64;not null"`
+	// Prefix 是密钥的开头部分，用于在列表和日志中识别密钥
+	Prefix       string       `json:"prefix" gorm:"uniqueIndex;size:32;not null" example:"glk_3f9a2c7d41be"`
+	KeyHash      string       `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	Scopes       APIKeyScopes `json:"scopes" gorm:"not null"`
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt   *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt    *time.Time   `json:"revoked_at,omitempty"`
+	RequestCount int64        `json:"request_count"`
+	TokensUsed   int64        `json:"tokens_used"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// Active 判断密钥是否未被吊销且未过期
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope 判断密钥是否包含权限范围 scope
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// APIKeyScopes 以 JSON 文本形式存储在数据库中
+type APIKeyScopes []string
+
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	b, err := json.Marshal([]string(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *APIKeyScopes) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for APIKeyScopes: %T", value)
+	}
+	if len(data) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(data, s)
+}
+
+func (APIKeyScopes) GormDataType() string {
+	return "text"
+}
+
+// CreateAPIKeyRequest 创建 API 密钥
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=64" example:"billing-service"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=chat embeddings" example:"chat,embeddings"`
+	// ExpiresInDays 是有效天数，不填时使用 API_KEY_DEFAULT_TTL_DAYS
+	ExpiresInDays *int `json:"expires_in_days,omitempty" binding:"omitempty,min=1,max=3650" example:"90"`
+}
+
+// CreateAPIKeyResponse 包含完整密钥，只在创建时返回一次
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key" example:"glk_3f9a2c7d41be_9b2e6f0c4d8a1e7f3b5c9d2a6e0f4b8c1d7e3a9f5b2c6d0e4f8a1b7c3d9e5f2a6b0c"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 附件来源
+const (
+	AttachmentSourceUpload = "upload"
+	AttachmentSourceURL    = "url"
+)
+
+// Attachment 是消息附带的文件或图片地址，文件内容保存在存储后端中
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	MessageID   *uint     `json:"message_id,omitempty" gorm:"index"` // 上传后长期未关联消息的附件会被清理
+	SessionID   string    `json:"session_id,omitempty" gorm:"index"`
+	UserID      *uint     `json:"user_id,omitempty" gorm:"index"`
+	Source      string    `json:"source" gorm:"size:16"` // upload, url
+	URL         string    `json:"url,omitempty" gorm:"size:2048"`
+	FileName    string    `json:"file_name,omitempty"`
+	ContentType string    `json:"content_type,omitempty" gorm:"size:128"`
+	Size        int64     `json:"size,omitempty"`
+	Checksum    string    `json:"checksum,omitempty" gorm:"size:64"` // SHA-256，十六进制
+	StorageKey  string    `json:"-" gorm:"size:255"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IsImage 判断附件是否为图片
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
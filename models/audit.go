@@ -0,0 +1,215 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计日志记录的操作
+const (
+	AuditActionUserCreate     = "user.create"
+	AuditActionUserUpdate     = "user.update"
+	AuditActionUserDelete     = "user.delete"
+	AuditActionUserRestore    = "user.restore"
+	AuditActionUserDeactivate = "user.deactivate"
+	AuditActionUserReactivate = "user.reactivate"
+	AuditActionUserErasure    = "user.erasure"
+	AuditActionUserRoles      = "user.roles_update"
+	AuditActionLogin          = "auth.login"
+	AuditActionAPIKeyCreate   = "api_key.create"
+	AuditActionAPIKeyRevoke   = "api_key.revoke"
+	AuditActionShareCreate    = "share_link.create"
+	AuditActionConfigChange   = "config.change"
+)
+
+// 审计日志的操作结果
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// 审计日志的操作对象类型
+const (
+	AuditTargetUser      = "user"
+	AuditTargetAPIKey    = "api_key"
+	AuditTargetShareLink = "share_link"
+	AuditTargetConfig    = "config"
+)
+
+// AuditRedacted 代替审计日志中不能记录的值，例如密码
+const AuditRedacted = "[redacted]"
+
+// ErrAuditLogImmutable 在修改或删除审计日志时返回
+var ErrAuditLogImmutable = errors.New("audit log entries are append-only")
+
+// AuditLog 是一条管理操作或安全相关操作的审计记录，只能追加，不能修改或删除
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Action    string    `json:"action" gorm:"size:64;index;not null" example:"user.update"`
+	Outcome   string    `json:"outcome" gorm:"size:16;not null" example:"success"`
+	// ActorID 是执行操作的用户，匿名请求（例如登录失败）和系统操作为空
+	ActorID *uint `json:"actor_id,omitempty" gorm:"index"`
+	// ActorAPIKeyID 是请求使用的 API 密钥
+	ActorAPIKeyID *uint  `json:"actor_api_key_id,omitempty"`
+	TargetType    string `json:"target_type,omitempty" gorm:"size:32;index:idx_audit_logs_target" example:"user"`
+	TargetID      string `json:"target_id,omitempty" gorm:"size:64;index:idx_audit_logs_target" example:"42"`
+	IP            string `json:"ip,omitempty" gorm:"size:64"`
+	RequestID     string `json:"request_id,omitempty" gorm:"size:64;index"`
+	// Changes 记录被修改字段修改前后的值
+	Changes AuditChanges `json:"changes,omitempty"`
+	// Metadata 记录操作的其他信息，例如登录方式和失败原因
+	Metadata AuditMetadata `json:"metadata,omitempty"`
+}
+
+// NewAuditLog 创建操作对象为 targetType 类型、ID 为 targetID 的审计日志
+func NewAuditLog(action, targetType string, targetID uint) AuditLog {
+	return AuditLog{Action: action, TargetType: targetType, TargetID: strconv.FormatUint(uint64(targetID), 10)}
+}
+
+// auditPseudonymizeKey 标记删除用户数据时对审计日志的匿名化，这是审计日志不能修改的唯一例外
+const auditPseudonymizeKey = "audit:pseudonymize"
+
+// PseudonymizeAuditLogs 返回可以修改审计日志的 db，只用于彻底删除用户数据时去掉日志中可以识别该用户的信息
+func PseudonymizeAuditLogs(db *gorm.DB) *gorm.DB {
+	return db.Set(auditPseudonymizeKey, true)
+}
+
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	if pseudonymize, ok := tx.Get(auditPseudonymizeKey); ok && pseudonymize == true {
+		return nil
+	}
+	return ErrAuditLogImmutable
+}
+
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// AuditChange 是一个字段修改前后的值
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditChanges 以 JSON 文本形式存储在数据库中，键为字段名
+type AuditChanges map[string]AuditChange
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]AuditChange(c))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c *AuditChanges) Scan(value any) error {
+	data, err := auditJSON(value, "AuditChanges")
+	if err != nil || data == nil {
+		*c = nil
+		return err
+	}
+	return json.Unmarshal(data, c)
+}
+
+func (AuditChanges) GormDataType() string {
+	return "text"
+}
+
+// AuditMetadata 以 JSON 文本形式存储在数据库中
+type AuditMetadata map[string]any
+
+func (m AuditMetadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]any(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *AuditMetadata) Scan(value any) error {
+	data, err := auditJSON(value, "AuditMetadata")
+	if err != nil || data == nil {
+		*m = nil
+		return err
+	}
+	return json.Unmarshal(data, m)
+}
+
+func (AuditMetadata) GormDataType() string {
+	return "text"
+}
+
+func auditJSON(value any, typeName string) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return v, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported type for %s: %T", typeName, value)
+	}
+}
+
+// UserChanges 比较用户修改前后的字段，密码只记录是否被修改
+func UserChanges(before, after *User) AuditChanges {
+	changes := AuditChanges{}
+	if before.UserName != after.UserName {
+		changes["user_name"] = AuditChange{Before: before.UserName, After: after.UserName}
+	}
+	if before.Email != after.Email {
+		changes["email"] = AuditChange{Before: before.Email, After: after.Email}
+	}
+	if !equalTime(before.EmailVerifiedAt, after.EmailVerifiedAt) {
+		changes["email_verified_at"] = AuditChange{Before: before.EmailVerifiedAt, After: after.EmailVerifiedAt}
+	}
+	if !equalTime(before.DeactivatedAt, after.DeactivatedAt) {
+		changes["deactivated_at"] = AuditChange{Before: before.DeactivatedAt, After: after.DeactivatedAt}
+	}
+	if before.HashPassword != after.HashPassword {
+		changes["password"] = AuditChange{Before: AuditRedacted, After: AuditRedacted}
+	}
+	return changes
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// AuditLogQuery 是审计日志的查询条件
+type AuditLogQuery struct {
+	Action     string    `form:"action"`
+	Outcome    string    `form:"outcome" binding:"omitempty,oneof=success failure"`
+	ActorID    uint      `form:"actor_id"`
+	TargetType string    `form:"target_type"`
+	TargetID   string    `form:"target_id"`
+	RequestID  string    `form:"request_id"`
+	IP         string    `form:"ip"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit      int       `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset     int       `form:"offset" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ResponseCacheEntry 是 SQL 回复缓存中的一条记录，CacheKey 为请求内容的 SHA-256
+type ResponseCacheEntry struct {
+	CacheKey string `json:"cache_key" gorm:"primarykey;size:64"`
+	Content  string `json:"content"`
+	Size     int    `json:"size"`
+	// UserID 是写入缓存的请求所属的用户，删除用户数据时一并删除，匿名请求为空
+	UserID    *uint     `json:"user_id,omitempty" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
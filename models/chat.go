@@ -1,23 +1,97 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 type ChatMessage struct {
-	ID        uint      `json:"id" gorm:"primarykey"`
-	CreatedAt time.Time `json:"created_at"`
-	SessionID string    `json:"session_id" gorm:"index"`
-	Role      string    `json:"role" binding:"required,oneof=user assistant system"` // user, assistant, system
-	Content   string    `json:"content" binding:"required"`
+	ID          uint         `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time    `json:"created_at"`
+	SessionID   string       `json:"session_id" gorm:"index"`
+	UserID      *uint        `json:"user_id,omitempty" gorm:"index"`
+	APIKeyID    *uint        `json:"api_key_id,omitempty" gorm:"index"`                        // 通过 API 密钥发送时为该密钥
+	Role        string       `json:"role" binding:"required,oneof=user assistant system tool"` // user, assistant, system, tool
+	Content     string       `json:"content" binding:"required"`
+	ToolCalls   ToolCalls    `json:"tool_calls,omitempty"`                              // assistant 请求的工具调用
+	ToolCallID  string       `json:"tool_call_id,omitempty" gorm:"size:64"`             // tool 消息对应的调用ID
+	Attachments []Attachment `json:"attachments,omitempty" gorm:"foreignKey:MessageID"` // 用户消息附带的图片
+	Interrupted bool         `json:"interrupted,omitempty"`                             // 生成被中断，内容不完整
 }
 
+// ToolCall 是模型请求执行的一次函数调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCalls 以 JSON 文本形式存储在数据库中
+type ToolCalls []ToolCall
+
+func (tc ToolCalls) Value() (driver.Value, error) {
+	if len(tc) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(tc)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (tc *ToolCalls) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for ToolCalls: %T", value)
+	}
+	if len(data) == 0 {
+		*tc = nil
+		return nil
+	}
+	return json.Unmarshal(data, tc)
+}
+
+func (ToolCalls) GormDataType() string {
+	return "text"
+}
+
+// ChatRequest 可以以 JSON 或 multipart/form-data 提交，后者可通过 images 字段上传图片
 type ChatRequest struct {
-	SessionID string `json:"session_id,omitempty"`
-	Message   string `json:"message" binding:"required"`
+	SessionID string `json:"session_id,omitempty" form:"session_id"`
+	Message   string `json:"message" form:"message" binding:"required"`
+	// UseKnowledgeBase 为 true 时先从知识库检索相关片段作为上下文
+	UseKnowledgeBase bool `json:"use_knowledge_base,omitempty" form:"use_knowledge_base"`
+	TopK             int  `json:"top_k,omitempty" form:"top_k" binding:"omitempty,min=1,max=20"`
+	// ImageURLs 是随消息发送的图片地址，仅支持 http(s)
+	ImageURLs []string `json:"image_urls,omitempty" form:"image_urls" binding:"omitempty,dive,url"`
+	// AttachmentIDs 引用通过 /attachments 上传的附件
+	AttachmentIDs []uint `json:"attachment_ids,omitempty" form:"attachment_ids"`
 }
 
 type ChatResponse struct {
-	SessionID string `json:"session_id"`
-	Message   string `json:"message"`
+	SessionID string     `json:"session_id"`
+	Message   string     `json:"message"`
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// Conversation 是导出/导入时使用的会话结构
+type Conversation struct {
+	SessionID string        `json:"session_id,omitempty"`
+	Title     string        `json:"title,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Messages  []ChatMessage `json:"messages"`
+}
+
+type ImportResponse struct {
+	SessionIDs []string `json:"session_ids"`
+	Messages   int      `json:"messages"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// EmbeddingInput 接受单个字符串或字符串数组
+type EmbeddingInput []string
+
+func (in *EmbeddingInput) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = EmbeddingInput{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("input must be a string or an array of strings")
+	}
+	*in = many
+	return nil
+}
+
+type EmbeddingRequest struct {
+	Input      EmbeddingInput `json:"input" binding:"required" swaggertype:"array,string"`
+	Dimensions int32          `json:"dimensions,omitempty" binding:"omitempty,min=1"`
+}
+
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingResponse 与 OpenAI embeddings 接口的响应格式保持一致
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// 健康检查状态
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusDegraded    = "degraded"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// 依赖检查失败时返回的错误描述，具体错误只记录在日志中
+const (
+	HealthErrorCheckFailed = "check failed"
+	HealthErrorTimeout     = "timed out"
+)
+
+// DependencyStatus 是一个依赖的检查结果
+type DependencyStatus struct {
+	Status    string    `json:"status" enums:"ok,unavailable"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty" enums:"check failed,timed out"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Cached 表示结果来自缓存，而不是本次请求实时检查
+	Cached bool `json:"cached,omitempty"`
+	// Critical 表示该依赖不可用时服务不能接收请求
+	Critical bool `json:"critical"`
+}
+
+// HealthResponse 是 /healthz 和 /readyz 的响应
+type HealthResponse struct {
+	Status string                      `json:"status" enums:"ok,degraded,unavailable"`
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
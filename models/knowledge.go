@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// KnowledgeDocument 是上传到知识库的文档
+type KnowledgeDocument struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Name        string    `json:"name" gorm:"not null"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	ChunkCount  int       `json:"chunk_count"`
+	UserID      *uint     `json:"user_id,omitempty" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// KnowledgeChunk 是文档切分后的片段及其向量
+type KnowledgeChunk struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	DocumentID uint      `json:"document_id" gorm:"index;not null"`
+	Position   int       `json:"position"`
+	Content    string    `json:"content"`
+	Embedding  Vector    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Vector 以小端 float32 二进制形式存储在数据库中
+type Vector []float32
+
+func (v Vector) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b, nil
+}
+
+func (v *Vector) Scan(value any) error {
+	var b []byte
+	switch data := value.(type) {
+	case nil:
+	case []byte:
+		b = data
+	case string:
+		b = []byte(data)
+	default:
+		return fmt.Errorf("unsupported type for Vector: %T", value)
+	}
+	if len(b)%4 != 0 {
+		return fmt.Errorf("invalid vector length: %d", len(b))
+	}
+	vector := make(Vector, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	*v = vector
+	return nil
+}
+
+func (Vector) GormDataType() string {
+	return "bytes"
+}
+
+// Citation 是回答引用的知识库片段
+type Citation struct {
+	Index        int     `json:"index"`
+	DocumentID   uint    `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	ChunkID      uint    `json:"chunk_id"`
+	Score        float32 `json:"score"`
+	Excerpt      string  `json:"excerpt"`
+}
+
+type KnowledgeSearchRequest struct {
+	Query string `json:"query" binding:"required"`
+	TopK  int    `json:"top_k" binding:"omitempty,min=1,max=20"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 审核阶段
+const (
+	ModerationStageInput  = "input"
+	ModerationStageOutput = "output"
+)
+
+// 审核动作，按严重程度从低到高排列
+const (
+	ModerationActionAllow  = "allow"
+	ModerationActionFlag   = "flag"
+	ModerationActionRedact = "redact"
+	ModerationActionBlock  = "block"
+)
+
+// ModerationFlag 记录一次命中审核规则的情况，供管理员复核
+type ModerationFlag struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	SessionID  string     `json:"session_id" gorm:"index"`
+	UserID     *uint      `json:"user_id,omitempty" gorm:"index"`
+	MessageID  *uint      `json:"message_id,omitempty"` // 被拦截的内容不会保存为消息
+	Stage      string     `json:"stage" gorm:"size:16"` // input, output
+	Checker    string     `json:"checker" gorm:"size:32"`
+	Category   string     `json:"category" gorm:"size:64;index"`
+	Action     string     `json:"action" gorm:"size:16"`
+	Excerpt    string     `json:"excerpt"` // 命中的内容，仅保留首尾字符
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy *uint      `json:"reviewed_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"index"`
+}
+
+type ModerationFlagQuery struct {
+	SessionID string `form:"session_id"`
+	UserID    uint   `form:"user_id"`
+	Stage     string `form:"stage" binding:"omitempty,oneof=input output"`
+	Category  string `form:"category"`
+	Action    string `form:"action" binding:"omitempty,oneof=flag redact block"`
+	// Reviewed 为 true 只返回已复核的记录，为 false 只返回未复核的记录
+	Reviewed *bool `form:"reviewed"`
+	Limit    int   `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset   int   `form:"offset" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserIdentity 将 OpenID Connect 身份提供方的账户关联到用户，同一身份（issuer 和 subject）只能关联一个用户
+type UserIdentity struct {
+	ID     uint `json:"id" gorm:"primarykey"`
+	UserID uint `json:"user_id" gorm:"index;not null"`
+	// Provider 是 OIDC_PROVIDERS 中配置的身份提供方名称
+	Provider    string    `json:"provider" gorm:"size:64;not null"`
+	Issuer      string    `json:"issuer" gorm:"uniqueIndex:idx_user_identities_subject;size:255;not null"`
+	Subject     string    `json:"subject" gorm:"uniqueIndex:idx_user_identities_subject;size:255;not null"`
+	Email       string    `json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OIDCProvider 是可用于登录的身份提供方
+type OIDCProvider struct {
+	Name        string `json:"name" example:"corp"`
+	DisplayName string `json:"display_name" example:"Corporate SSO"`
+	// LoginURL 是开始登录的地址，浏览器打开后会跳转到身份提供方
+	LoginURL string `json:"login_url" example:"/auth/oidc/corp/login"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 数据删除请求的状态
+const (
+	ErasureStatusPending   = "pending"
+	ErasureStatusCompleted = "completed"
+)
+
+// ErasureRequest 是一次彻底删除用户数据的请求，完成后作为删除回执保留。
+// 回执不包含用户的个人信息，SubjectHash 用于在收到询问时核对被删除的账户
+type ErasureRequest struct {
+	ID     uint `json:"id" gorm:"primarykey"`
+	UserID uint `json:"user_id" gorm:"index;not null"`
+	// SubjectHash 是用户邮箱（小写）的 SHA-256，十六进制
+	SubjectHash string `json:"subject_hash" gorm:"size:64;not null"`
+	// RequestedBy 是发起请求的用户，用户本人请求时与 UserID 相同
+	RequestedBy uint   `json:"requested_by"`
+	Status      string `json:"status" gorm:"size:16;index;not null" example:"completed"`
+	// Attempts 和 LastError 记录执行失败的情况，失败后会在下一轮重试
+	Attempts    int            `json:"attempts"`
+	LastError   string         `json:"last_error,omitempty"`
+	Receipt     ErasureReceipt `json:"receipt"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// ErasureReceipt 记录删除或匿名化的数据数量
+type ErasureReceipt struct {
+	Sessions        int   `json:"sessions"`
+	Messages        int64 `json:"messages"`
+	Attachments     int64 `json:"attachments"`
+	ShareLinks      int64 `json:"share_links"`
+	APIKeys         int64 `json:"api_keys"`
+	Identities      int64 `json:"identities"`
+	EmailDeliveries int64 `json:"email_deliveries"`
+	// ResponseCacheEntries 是删除的该用户的请求写入的回复缓存
+	ResponseCacheEntries int64 `json:"response_cache_entries"`
+	// ModerationFlags 和 KnowledgeDocuments 被匿名化而不是删除：审核统计和共享知识库仍然需要这些记录
+	ModerationFlags    int64 `json:"moderation_flags"`
+	KnowledgeDocuments int64 `json:"knowledge_documents"`
+	// AuditLogs 是被匿名化的审计日志。审计日志用于追查安全事件，不删除，只去掉用户名、邮箱和 IP，
+	// 保留的用户 ID 在用户被删除后不再对应任何人
+	AuditLogs   int64 `json:"audit_logs"`
+	UserDeleted bool  `json:"user_deleted"`
+}
+
+func (r ErasureReceipt) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *ErasureReceipt) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for ErasureReceipt: %T", value)
+	}
+	if len(data) == 0 {
+		*r = ErasureReceipt{}
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
+
+func (ErasureReceipt) GormDataType() string {
+	return "text"
+}
+
+// UserExport 是用户可以下载的全部个人数据，与附件文件一起打包为 zip 中的 data.json
+type UserExport struct {
+	ExportedAt         time.Time           `json:"exported_at"`
+	User               UserResponse        `json:"user"`
+	Identities         []UserIdentity      `json:"identities"`
+	APIKeys            []APIKey            `json:"api_keys"`
+	Conversations      []Conversation      `json:"conversations"`
+	Attachments        []Attachment        `json:"attachments"`
+	ShareLinks         []ShareLink         `json:"share_links"`
+	KnowledgeDocuments []KnowledgeDocument `json:"knowledge_documents"`
+	ModerationFlags    []ModerationFlag    `json:"moderation_flags"`
+	EmailDeliveries    []EmailDelivery     `json:"email_deliveries"`
+}
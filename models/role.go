@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// 内置权限，名称格式为 资源:操作
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersDelete      = "users:delete"
+	PermissionRolesManage      = "roles:manage"
+	PermissionModerationRead   = "moderation:read"
+	PermissionModerationReview = "moderation:review"
+	PermissionAPIKeysManage    = "api_keys:manage"
+	PermissionAuditRead        = "audit:read"
+	PermissionKnowledgeManage  = "kb:manage"
+)
+
+// 内置角色
+const (
+	// RoleUser 是新注册用户的默认角色，只能访问和修改自己的数据
+	RoleUser = "user"
+	// RoleAdmin 拥有全部权限
+	RoleAdmin = "admin"
+	// RoleAuditor 可以只读访问用户、审核记录和审计日志
+	RoleAuditor = "auditor"
+)
+
+type Permission struct {
+	ID          uint   `json:"-" gorm:"primarykey"`
+	Name        string `json:"name" gorm:"uniqueIndex;size:64;not null"`
+	Description string `json:"description"`
+}
+
+// Role 是一组权限，用户通过 user_roles 关联角色
+type Role struct {
+	ID          uint         `json:"-" gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;size:64;not null"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"-"`
+	UpdatedAt   time.Time    `json:"-"`
+}
+
+// SetUserRolesRequest 替换用户的全部角色
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required,dive,required" example:"user,auditor"`
+}
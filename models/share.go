@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ShareLink 保存会话在创建分享时的只读快照
+type ShareLink struct {
+	ID           uint       `json:"id" gorm:"primarykey"`
+	Token        string     `json:"token" gorm:"uniqueIndex;size:64;not null"`
+	SessionID    string     `json:"session_id" gorm:"index;not null"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	Title        string     `json:"title"`
+	Snapshot     string     `json:"-"`
+	MessageCount int        `json:"message_count"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type CreateShareLinkRequest struct {
+	SessionID      string `json:"session_id" binding:"required"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=8760"`
+}
+
+// SharedMessage 是公开分享中展示的消息，不包含会话ID和用户信息
+type SharedMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SharedConversation struct {
+	Title     string          `json:"title"`
+	SharedAt  time.Time       `json:"shared_at"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+	Messages  []SharedMessage `json:"messages"`
+}
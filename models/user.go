@@ -1,19 +1,119 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// User 是数据库模型，不直接作为请求或响应使用，接口使用下方的 DTO
 type User struct {
-	ID           uint      `json:"id" gorm:"primarykey"`
-	UserName     string    `json:"user_name" gorm:"unique;not null"`
-	HashPassword string    `json:"hash_password" gorm:"not null"`
-	Email        string    `json:"email" gorm:"unique;not null"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint   `json:"id" gorm:"primarykey"`
+	UserName     string `json:"user_name" gorm:"unique;not null"`
+	HashPassword string `json:"-" gorm:"not null"`
+	Email        string `json:"email" gorm:"unique;not null"`
+	// EmailVerifiedAt 为空表示用户尚未证明拥有该邮箱
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	Roles           []Role         `json:"-" gorm:"many2many:user_roles"`
+	APIKeys         []APIKey       `json:"-"`
+	Identities      []UserIdentity `json:"-"`
+	// PasswordChangedAt 是最近一次重置密码的时间，此前签发的访问令牌全部失效
+	PasswordChangedAt *time.Time `json:"-"`
+	// DeactivatedAt 不为空时用户不能登录，已签发的访问令牌和 API 密钥也会失效
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	// DeletedAt 不为空表示用户已被软删除，管理员可以恢复
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CreateUserRequest 是注册用户的请求，ID、时间戳等字段由服务端生成
+type CreateUserRequest struct {
+	UserName string `json:"user_name" binding:"required,min=4,max=32,username" example:"alice"`
+	Email    string `json:"email" binding:"required,email,max=254" example:"alice@example.com"`
+	// Password 至少 8 位且同时包含字母和数字；bcrypt 只使用前 72 字节
+	Password string `json:"password" binding:"required,min=8,max=72,password" example:"secret123"`
+}
+
+// UpdateUserRequest 是更新用户的请求，只能修改用户名和邮箱。修改邮箱后需要重新验证，
+// 需要保持验证状态时应使用 /account/email 修改邮箱
+type UpdateUserRequest struct {
+	UserName string `json:"user_name" binding:"required,min=4,max=32,username" example:"alice"`
+	Email    string `json:"email" binding:"required,email,max=254" example:"alice@example.com"`
+}
+
+// UserResponse 是接口返回的用户信息，不包含密码哈希
+type UserResponse struct {
+	ID       uint   `json:"id"`
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+	// EmailVerifiedAt 为空表示邮箱尚未验证
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// Roles 是用户的角色名称，仅在查询单个用户时返回
+	Roles         []string   `json:"roles,omitempty" example:"user"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	// DeletedAt 只在管理员查看已删除的用户时返回
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type LoginRequest struct {
+	UserName string `json:"user_name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LoginResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      UserResponse `json:"user"`
+}
+
+// ToUser 将注册请求转换为用户模型，密码由 IUserService.Create 单独处理
+func (r CreateUserRequest) ToUser() *User {
+	return &User{UserName: r.UserName, Email: r.Email}
+}
+
+// ToUser 将更新请求转换为用户模型，只包含可修改的字段
+func (r UpdateUserRequest) ToUser() *User {
+	return &User{UserName: r.UserName, Email: r.Email}
+}
+
+// NewUserResponse 将用户模型转换为响应，已加载 Roles 时包含角色名称
+func NewUserResponse(user *User) UserResponse {
+	response := UserResponse{
+		ID:              user.ID,
+		UserName:        user.UserName,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		DeactivatedAt:   user.DeactivatedAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+	if user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	for _, role := range user.Roles {
+		response.Roles = append(response.Roles, role.Name)
+	}
+	return response
+}
+
+// NewUserResponses 将用户列表转换为响应
+func NewUserResponses(users []User) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, NewUserResponse(&users[i]))
+	}
+	return responses
 }
 
 type IUserService interface {
-	Create(newUser *User) (*User, error)
+	Create(newUser *User, password string) (*User, error)
 	Delete(id uint) error
+	GetDeleted() (*[]User, error)
+	Restore(id uint) (*User, error)
+	SetActive(id uint, active bool) (*User, error)
 	Update(id uint, updatedUser *User) (*User, error)
 	GetAll() (*[]User, error)
 	GetByID(id uint) (*User, error)
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers/user"
+	"github.com/thoulee21/go-learn/middlewares"
+)
+
+func SetupAccountRoutes(r *gin.Engine, ac *user.AccountController) {
+	a := r.Group("/account")
+	{
+		a.POST("/verification", middlewares.RequireAuth, ac.SendVerification)
+		a.POST("/verification/confirm", ac.VerifyEmail)
+		a.POST("/password/forgot", ac.ForgotPassword)
+		a.POST("/password/reset", ac.ResetPassword)
+		a.POST("/email", middlewares.RequireAuth, ac.ChangeEmail)
+		a.POST("/email/confirm", ac.ConfirmEmailChange)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers/user"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+func SetupAPIKeyRoutes(r *gin.Engine, kc *user.APIKeyController, rbacService *services.RBACService) {
+	k := r.Group("/api-keys", middlewares.RequireAuth)
+	{
+		k.POST("", kc.CreateAPIKey)
+		k.GET("", kc.ListAPIKeys)
+		k.DELETE("/:id", kc.RevokeAPIKey)
+	}
+
+	a := r.Group("/admin", middlewares.RequirePermission(rbacService, models.PermissionAPIKeysManage))
+	{
+		a.GET("/api-keys", kc.AdminListAPIKeys)
+		a.POST("/users/:id/api-keys", kc.AdminCreateAPIKey)
+		a.DELETE("/api-keys/:id", kc.AdminRevokeAPIKey)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+)
+
+func SetupAttachmentRoutes(r *gin.Engine, ac *controllers.AttachmentController) {
+	attachmentGroup := r.Group("/attachments", middlewares.RequireAuth)
+	{
+		attachmentGroup.POST("", ac.UploadAttachment)
+		attachmentGroup.GET("", ac.ListAttachments)
+		attachmentGroup.GET("/:id", ac.GetAttachment)
+		attachmentGroup.GET("/:id/content", ac.DownloadAttachment)
+		attachmentGroup.DELETE("/:id", ac.DeleteAttachment)
+	}
+}
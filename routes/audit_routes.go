@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+func SetupAuditRoutes(r *gin.Engine, ac *controllers.AuditController, rbacService *services.RBACService) {
+	r.GET("/admin/audit-logs", middlewares.RequirePermission(rbacService, models.PermissionAuditRead), ac.ListAuditLogs)
+}
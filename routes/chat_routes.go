@@ -3,13 +3,15 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
 )
 
 func SetupChatRoutes(r *gin.Engine, cc *controllers.ChatController) {
 	chatGroup := r.Group("/chat")
 	{
-		chatGroup.POST("", cc.Chat)
-		chatGroup.POST("/stream", cc.StreamChat)
+		chatGroup.POST("", middlewares.AllowAPIKey(models.APIKeyScopeChat), cc.Chat)
+		chatGroup.POST("/stream", middlewares.AllowAPIKey(models.APIKeyScopeChat), cc.StreamChat)
 		chatGroup.GET("/history/:session_id", cc.GetChatHistory)
 	}
 
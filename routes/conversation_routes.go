@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+)
+
+func SetupConversationRoutes(r *gin.Engine, cc *controllers.ConversationController) {
+	chatGroup := r.Group("/chat")
+	{
+		chatGroup.GET("/export", middlewares.RequireAuth, cc.ExportAllSessions)
+		chatGroup.GET("/export/:session_id", cc.ExportSession)
+		chatGroup.POST("/import", middlewares.RequireAuth, cc.ImportSessions)
+	}
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+)
+
+func SetupEmbeddingRoutes(r *gin.Engine, ec *controllers.EmbeddingController) {
+	r.POST("/embeddings", middlewares.AllowAPIKey(models.APIKeyScopeEmbeddings), middlewares.RequireAuth, ec.CreateEmbeddings)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+)
+
+func SetupHealthRoutes(r *gin.Engine, hc *controllers.HealthController) {
+	r.GET("/healthz", hc.Liveness)
+	r.GET("/readyz", hc.Readiness)
+}
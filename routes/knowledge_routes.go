@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+func SetupKnowledgeRoutes(r *gin.Engine, kc *controllers.KnowledgeController, rbacService *services.RBACService) {
+	kbGroup := r.Group("/kb", middlewares.RequireAuth)
+	{
+		// 知识库对所有用户的回答生效，只有管理员可以修改
+		kbGroup.POST("/documents", middlewares.RequirePermission(rbacService, models.PermissionKnowledgeManage), kc.UploadDocument)
+		kbGroup.GET("/documents", kc.ListDocuments)
+		kbGroup.DELETE("/documents/:id", middlewares.RequirePermission(rbacService, models.PermissionKnowledgeManage), kc.DeleteDocument)
+		kbGroup.POST("/search", kc.Search)
+	}
+}
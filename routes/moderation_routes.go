@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+func SetupModerationRoutes(r *gin.Engine, mc *controllers.ModerationController, rbacService *services.RBACService) {
+	moderationGroup := r.Group("/admin/moderation")
+	{
+		moderationGroup.GET("/flags", middlewares.RequirePermission(rbacService, models.PermissionModerationRead), mc.ListFlags)
+		moderationGroup.POST("/flags/:id/review", middlewares.RequirePermission(rbacService, models.PermissionModerationReview), mc.ReviewFlag)
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers/user"
+)
+
+func SetupOIDCRoutes(r *gin.Engine, oc *user.OIDCController) {
+	o := r.Group("/auth/oidc")
+	{
+		o.GET("/providers", oc.ListProviders)
+		o.GET("/:provider/login", oc.Login)
+		o.GET("/:provider/callback", oc.Callback)
+	}
+}
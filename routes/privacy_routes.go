@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers/user"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
+)
+
+func SetupPrivacyRoutes(r *gin.Engine, pc *user.PrivacyController, rbacService *services.RBACService) {
+	u := r.Group("/user")
+	{
+		u.POST("/:id/erasure", middlewares.RequireSelfOrPermission(rbacService, "id", models.PermissionUsersDelete), pc.RequestErasure)
+		u.GET("/:id/export", middlewares.RequireSelfOrPermission(rbacService, "id", models.PermissionUsersRead), pc.ExportData)
+	}
+
+	a := r.Group("/admin/erasures", middlewares.RequirePermission(rbacService, models.PermissionUsersDelete))
+	{
+		a.GET("", pc.ListErasures)
+		a.GET("/:id", pc.GetErasure)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoulee21/go-learn/controllers"
+	"github.com/thoulee21/go-learn/middlewares"
+)
+
+func SetupShareRoutes(r *gin.Engine, sc *controllers.ShareController) {
+	shareGroup := r.Group("/chat/shares", middlewares.RequireAuth)
+	{
+		shareGroup.POST("", sc.CreateShareLink)
+		shareGroup.GET("", sc.ListShareLinks)
+		shareGroup.DELETE("/:id", sc.RevokeShareLink)
+	}
+
+	r.GET("/share/:token", sc.GetSharedConversation)
+}
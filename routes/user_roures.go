@@ -3,15 +3,36 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/thoulee21/go-learn/controllers/user"
+	"github.com/thoulee21/go-learn/middlewares"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/services"
 )
 
-func SetupUserRoutes(r *gin.Engine, uc *user.UserController) {
+func SetupUserRoutes(r *gin.Engine, uc *user.UserController, rbacService *services.RBACService) {
 	u := r.Group("/user")
 	{
-		u.GET("/", uc.GetAllUsers)
+		u.GET("/", middlewares.RequirePermission(rbacService, models.PermissionUsersRead), uc.GetAllUsers)
 		u.POST("/", uc.NewUser)
-		u.GET("/:id", uc.GetUserByID)
-		u.PUT("/:id", uc.UpdateUser)
-		u.DELETE("/:id", uc.DeleteUser)
+		u.POST("/login", uc.Login)
+		// 用户可以访问自己，访问其他用户需要相应权限
+		u.GET("/:id", middlewares.RequireSelfOrPermission(rbacService, "id", models.PermissionUsersRead), uc.GetUserByID)
+		u.PUT("/:id", middlewares.RequireSelfOrPermission(rbacService, "id", models.PermissionUsersWrite), uc.UpdateUser)
+		u.DELETE("/:id", middlewares.RequireSelfOrPermission(rbacService, "id", models.PermissionUsersDelete), uc.DeleteUser)
+	}
+
+	a := r.Group("/admin/users")
+	{
+		a.GET("/deleted", middlewares.RequirePermission(rbacService, models.PermissionUsersRead), uc.ListDeletedUsers)
+		a.POST("/:id/restore", middlewares.RequirePermission(rbacService, models.PermissionUsersDelete), uc.RestoreUser)
+		a.POST("/:id/deactivate", middlewares.RequirePermission(rbacService, models.PermissionUsersWrite), uc.DeactivateUser)
+		a.POST("/:id/reactivate", middlewares.RequirePermission(rbacService, models.PermissionUsersWrite), uc.ReactivateUser)
+	}
+}
+
+func SetupRoleRoutes(r *gin.Engine, rc *user.RoleController, rbacService *services.RBACService) {
+	a := r.Group("/admin", middlewares.RequirePermission(rbacService, models.PermissionRolesManage))
+	{
+		a.GET("/roles", rc.ListRoles)
+		a.PUT("/users/:id/roles", rc.SetUserRoles)
 	}
 }
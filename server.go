@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReadTimeout     = 60 * time.Second
+	defaultWriteTimeout    = 120 * time.Second
+	defaultIdleTimeout     = 120 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+	readHeaderTimeout      = 10 * time.Second
+	// interruptGrace 是关闭超时、中断剩余请求后等待它们保存部分回复的时间
+	interruptGrace = 5 * time.Second
+)
+
+// server 包装 http.Server，关闭时先等待进行中的请求完成，超时后再中断它们
+type server struct {
+	*http.Server
+	shutdownTimeout time.Duration
+	// cancelRequests 取消所有请求的 context
+	cancelRequests context.CancelFunc
+}
+
+// newServer 根据环境变量创建 HTTP 服务器。
+// PORT 为监听端口，默认 8080；HTTP_READ_TIMEOUT_SECONDS、HTTP_WRITE_TIMEOUT_SECONDS、HTTP_IDLE_TIMEOUT_SECONDS
+// 分别设置读、写和空闲连接超时，流式接口不受写超时限制；SHUTDOWN_TIMEOUT_SECONDS 为关闭时等待请求完成的时间。
+func newServer(handler http.Handler) (*server, error) {
+	timeouts := map[string]time.Duration{
+		"HTTP_READ_TIMEOUT_SECONDS":  defaultReadTimeout,
+		"HTTP_WRITE_TIMEOUT_SECONDS": defaultWriteTimeout,
+		"HTTP_IDLE_TIMEOUT_SECONDS":  defaultIdleTimeout,
+		"SHUTDOWN_TIMEOUT_SECONDS":   defaultShutdownTimeout,
+	}
+	for name := range timeouts {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", name)
+			}
+			timeouts[name] = time.Duration(n) * time.Second
+		}
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// 请求的 context 都派生自 baseCtx，关闭超时后取消它以中断仍在生成的回复
+	baseCtx, cancel := context.WithCancel(context.Background())
+	return &server{
+		Server: &http.Server{
+			Addr:              ":" + port,
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       timeouts["HTTP_READ_TIMEOUT_SECONDS"],
+			WriteTimeout:      timeouts["HTTP_WRITE_TIMEOUT_SECONDS"],
+			IdleTimeout:       timeouts["HTTP_IDLE_TIMEOUT_SECONDS"],
+			BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		},
+		shutdownTimeout: timeouts["SHUTDOWN_TIMEOUT_SECONDS"],
+		cancelRequests:  cancel,
+	}, nil
+}
+
+// run 启动服务，直到 ctx 被取消后优雅关闭
+func (s *server) run(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", s.Addr)
+		serveErr <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		s.cancelRequests()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", s.shutdownTimeout)
+	return s.shutdown()
+}
+
+// shutdown 停止接收新请求并等待进行中的请求完成。超时后取消剩余请求的 context，
+// 流式接口会保存已经生成的部分回复，再等待 interruptGrace 后强制关闭连接。
+func (s *server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := s.Shutdown(ctx)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		s.cancelRequests()
+		return err
+	}
+
+	log.Println("Shutdown timed out, interrupting remaining requests")
+	s.cancelRequests()
+	graceCtx, graceCancel := context.WithTimeout(context.Background(), interruptGrace)
+	defer graceCancel()
+	if err := s.Shutdown(graceCtx); err != nil {
+		return s.Close()
+	}
+	return nil
+}
+
+// startMetricsServer 在 addr 上单独提供 Prometheus 指标，使指标不经过对外开放的端口。
+// 监听失败时返回错误；ctx 被取消后关闭服务，退出时调用 wg.Done
+func startMetricsServer(ctx context.Context, addr string, handler http.Handler, wg *sync.WaitGroup) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	metricsServer := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		log.Printf("Serving metrics on %s", listener.Addr())
+		if err := metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), interruptGrace)
+		defer cancel()
+		_ = metricsServer.Shutdown(shutdownCtx)
+	}()
+	return nil
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/i18n"
+	"github.com/thoulee21/go-learn/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultEmailVerificationTTL = 24 * time.Hour
+	defaultPasswordResetTTL     = time.Hour
+	defaultEmailChangeTTL       = 24 * time.Hour
+	defaultEmailResendInterval  = time.Minute
+	defaultEmailDailyLimit      = 5
+	defaultAppBaseURL           = "http://localhost:8080"
+)
+
+// accountEmail 描述一种账户邮件：令牌有效期、邮件内容和前端处理链接的路径
+type accountEmail struct {
+	ttl       time.Duration
+	subjectID string
+	bodyID    string
+	path      string
+}
+
+// AccountService 处理邮箱验证、找回密码和修改邮箱。邮件中的令牌是签名的 JWT，不保存在数据库中：
+// 令牌包含签发时用户邮箱和密码哈希的摘要，邮箱或密码改变后之前签发的令牌全部失效
+type AccountService struct {
+	DB             *gorm.DB
+	auth           *AuthService
+	mailer         Mailer
+	baseURL        string
+	emails         map[string]accountEmail
+	resendInterval time.Duration
+	dailyLimit     int
+	// pending 跟踪后台发送的重置密码邮件
+	pending sync.WaitGroup
+}
+
+// accountClaims 是账户邮件令牌的内容
+type accountClaims struct {
+	jwt.RegisteredClaims
+	// State 是签发时用户状态的摘要，见 userState
+	State string `json:"state"`
+	// NewEmail 是修改邮箱时待确认的新邮箱
+	NewEmail string `json:"new_email,omitempty"`
+}
+
+func NewAccountService(db *gorm.DB, authService *AuthService, mailer Mailer) (*AccountService, error) {
+	verifyTTL, err := durationSecondsFromEnv("EMAIL_VERIFICATION_TTL_SECONDS", defaultEmailVerificationTTL)
+	if err != nil {
+		return nil, err
+	}
+	resetTTL, err := durationSecondsFromEnv("PASSWORD_RESET_TTL_SECONDS", defaultPasswordResetTTL)
+	if err != nil {
+		return nil, err
+	}
+	changeTTL, err := durationSecondsFromEnv("EMAIL_CHANGE_TTL_SECONDS", defaultEmailChangeTTL)
+	if err != nil {
+		return nil, err
+	}
+	resendInterval, err := durationSecondsFromEnv("EMAIL_RESEND_INTERVAL_SECONDS", defaultEmailResendInterval)
+	if err != nil {
+		return nil, err
+	}
+	dailyLimit := defaultEmailDailyLimit
+	if v := os.Getenv("EMAIL_DAILY_LIMIT"); v != "" {
+		dailyLimit, err = strconv.Atoi(v)
+		if err != nil || dailyLimit <= 0 {
+			return nil, errors.New("EMAIL_DAILY_LIMIT must be a positive integer")
+		}
+	}
+	// APP_BASE_URL 是前端地址，邮件中的链接指向前端页面，由前端携带令牌调用 /account 接口
+	baseURL := strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = defaultAppBaseURL
+	}
+
+	return &AccountService{
+		DB:      db,
+		auth:    authService,
+		mailer:  mailer,
+		baseURL: baseURL,
+		emails: map[string]accountEmail{
+			models.EmailPurposeVerify:        {verifyTTL, "mail_verify_subject", "mail_verify_body", "/verify-email"},
+			models.EmailPurposeResetPassword: {resetTTL, "mail_reset_subject", "mail_reset_body", "/reset-password"},
+			models.EmailPurposeChangeEmail:   {changeTTL, "mail_change_subject", "mail_change_body", "/confirm-email"},
+		},
+		resendInterval: resendInterval,
+		dailyLimit:     dailyLimit,
+	}, nil
+}
+
+// SendVerification 向用户当前邮箱发送验证邮件，lang 为邮件语言
+func (s *AccountService) SendVerification(ctx context.Context, userID uint, lang string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "email_already_verified")
+	}
+	if err := s.checkLimit(ctx, user.ID, models.EmailPurposeVerify); err != nil {
+		return err
+	}
+	return s.send(ctx, user, models.EmailPurposeVerify, user.Email, "", lang)
+}
+
+// VerifyEmail 校验验证邮件中的令牌并将邮箱标记为已验证，返回修改前后的用户
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (before, after *models.User, err error) {
+	user, _, err := s.parseToken(ctx, models.EmailPurposeVerify, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	unchanged := *user
+	before = &unchanged
+	if user.EmailVerifiedAt == nil {
+		if err := s.DB.WithContext(ctx).Model(user).Update("email_verified_at", time.Now()).Error; err != nil {
+			return nil, nil, domainErrors.TranslateDBError(err)
+		}
+	}
+	return before, user, nil
+}
+
+// RequestPasswordReset 在后台向邮箱对应的用户发送重置密码邮件。为避免泄露邮箱是否已注册，
+// 查询用户和发送邮件都不影响响应：邮箱不存在、超过发送频率或发送失败时只记录日志
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email, lang string) {
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.sendPasswordReset(ctx, email, lang); err != nil {
+			slog.WarnContext(ctx, "password reset email not sent", slog.Any("error", err))
+		}
+	}()
+}
+
+func (s *AccountService) sendPasswordReset(ctx context.Context, email, lang string) error {
+	var user models.User
+	err := s.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+
+	if err := s.checkLimit(ctx, user.ID, models.EmailPurposeResetPassword); err != nil {
+		return fmt.Errorf("user %d: %w", user.ID, err)
+	}
+	if err := s.send(ctx, &user, models.EmailPurposeResetPassword, user.Email, "", lang); err != nil {
+		return fmt.Errorf("user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+// Wait 等待后台发送的邮件完成，关闭数据库前调用
+func (s *AccountService) Wait() {
+	s.pending.Wait()
+}
+
+// ResetPassword 校验重置密码令牌并设置新密码，之前签发的访问令牌随之失效。
+// 能收到邮件也证明了用户拥有该邮箱，因此同时将邮箱标记为已验证。返回修改前后的用户
+func (s *AccountService) ResetPassword(ctx context.Context, token, newPassword string) (before, after *models.User, err error) {
+	user, _, err := s.parseToken(ctx, models.EmailPurposeResetPassword, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return nil, nil, domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	unchanged := *user
+	before = &unchanged
+
+	updates := map[string]any{"hash_password": hash, "password_changed_at": time.Now()}
+	if user.EmailVerifiedAt == nil {
+		updates["email_verified_at"] = time.Now()
+	}
+	if err := s.DB.WithContext(ctx).Model(user).Updates(updates).Error; err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	return before, user, nil
+}
+
+// RequestEmailChange 校验当前密码后向新邮箱发送确认邮件，确认前邮箱保持不变
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID uint, newEmail, password, lang string) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.HashPassword), []byte(password)) != nil {
+		return domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "password_incorrect")
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "email_unchanged")
+	}
+	if err := s.checkEmailAvailable(ctx, newEmail); err != nil {
+		return err
+	}
+	if err := s.checkLimit(ctx, user.ID, models.EmailPurposeChangeEmail); err != nil {
+		return err
+	}
+	return s.send(ctx, user, models.EmailPurposeChangeEmail, newEmail, newEmail, lang)
+}
+
+// ConfirmEmailChange 校验确认令牌并把邮箱修改为令牌中的新邮箱，新邮箱视为已验证，返回修改前后的用户
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, token string) (before, after *models.User, err error) {
+	user, claims, err := s.parseToken(ctx, models.EmailPurposeChangeEmail, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.NewEmail == "" {
+		return nil, nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "invalid_account_token")
+	}
+	unchanged := *user
+	before = &unchanged
+
+	// 邮箱可能在发送确认邮件后被其他用户使用，此时由唯一约束返回 409
+	err = s.DB.WithContext(ctx).Model(user).Updates(map[string]any{
+		"email":             claims.NewEmail,
+		"email_verified_at": time.Now(),
+	}).Error
+	if err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	if err := s.DB.WithContext(ctx).First(user, user.ID).Error; err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	return before, user, nil
+}
+
+func (s *AccountService) user(ctx context.Context, userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &user, nil
+}
+
+func (s *AccountService) checkEmailAvailable(ctx context.Context, email string) error {
+	var count int64
+	// 被软删除的用户仍然占用邮箱，恢复后需要使用
+	if err := s.DB.WithContext(ctx).Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	if count > 0 {
+		appErr := domainErrors.NewAppErrorWithMessage(domainErrors.ResourceAlreadyExists, "field_already_exists", "new_email")
+		appErr.Fields = []domainErrors.FieldError{{Field: "new_email", Code: "unique"}}
+		return appErr
+	}
+	return nil
+}
+
+// checkLimit 限制同一用户同一用途的邮件发送间隔和每天的发送次数
+func (s *AccountService) checkLimit(ctx context.Context, userID uint, purpose string) error {
+	var deliveries []models.EmailDelivery
+	err := s.DB.WithContext(ctx).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, time.Now().Add(-24*time.Hour)).
+		Order("created_at DESC").
+		Find(&deliveries).Error
+	if err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	if len(deliveries) >= s.dailyLimit {
+		return domainErrors.NewAppErrorWithMessage(domainErrors.RateLimited, "email_daily_limit")
+	}
+	if len(deliveries) > 0 {
+		if wait := s.resendInterval - time.Since(deliveries[0].CreatedAt); wait > 0 {
+			seconds := int((wait + time.Second - 1) / time.Second)
+			return domainErrors.NewAppErrorWithMessage(domainErrors.RateLimited, "email_resend_too_soon", seconds)
+		}
+	}
+	return nil
+}
+
+// send 签发令牌并发送 purpose 对应的邮件，发送成功后记录发送历史
+func (s *AccountService) send(ctx context.Context, user *models.User, purpose, to, newEmail, lang string) error {
+	email := s.emails[purpose]
+	token, err := s.signToken(user, purpose, newEmail, email.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + email.path + "?token=" + token
+	validity := formatValidity(lang, email.ttl)
+	args := []any{user.UserName, validity, link}
+	if purpose == models.EmailPurposeChangeEmail {
+		args = []any{user.UserName, newEmail, validity, link}
+	}
+	mail := Mail{
+		To:      to,
+		Subject: i18n.T(lang, email.subjectID),
+		Body:    i18n.T(lang, email.bodyID, args...),
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		appErr := domainErrors.NewAppErrorWithMessage(domainErrors.UpstreamError, "mail_delivery_failed")
+		appErr.Err = fmt.Errorf("%s: %w", appErr.Err, err)
+		return appErr
+	}
+
+	delivery := models.EmailDelivery{UserID: user.ID, Purpose: purpose, Email: to}
+	if err := s.DB.WithContext(ctx).Create(&delivery).Error; err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	return nil
+}
+
+func (s *AccountService) signToken(user *models.User, purpose, newEmail string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := accountClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		State:    userState(user),
+		NewEmail: newEmail,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.auth.signingKey(purpose))
+	if err != nil {
+		return "", domainErrors.NewAppError(err, domainErrors.TokenGeneratorError)
+	}
+	return token, nil
+}
+
+// parseToken 校验令牌的签名、有效期和用户状态，返回令牌对应的用户
+func (s *AccountService) parseToken(ctx context.Context, purpose, token string) (*models.User, *accountClaims, error) {
+	invalid := domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "invalid_account_token")
+
+	var claims accountClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return s.auth.signingKey(purpose), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, nil, invalid
+	}
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, nil, invalid
+	}
+
+	var user models.User
+	if err := s.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, invalid
+		}
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	if !hmac.Equal([]byte(claims.State), []byte(userState(&user))) {
+		return nil, nil, invalid
+	}
+	return &user, &claims, nil
+}
+
+// userState 是用户邮箱和密码哈希的摘要，二者任一改变后之前签发的账户令牌失效，
+// 因此重置密码和修改邮箱的令牌只能使用一次
+func userState(user *models.User) string {
+	sum := sha256.Sum256([]byte(user.Email + "\x00" + user.HashPassword))
+	return hex.EncodeToString(sum[:16])
+}
+
+// formatValidity 将令牌有效期格式化为 lang 语言的小时数或分钟数
+func formatValidity(lang string, ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		return i18n.T(lang, "duration_hours", int(ttl/time.Hour))
+	}
+	return i18n.T(lang, "duration_minutes", int((ttl+time.Minute-1)/time.Minute))
+}
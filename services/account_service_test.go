@@ -0,0 +1,270 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/i18n"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建迁移好全部表并初始化内置角色的内存数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_foreign_keys=1", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	err = db.AutoMigrate(&models.ChatMessage{}, models.User{}, &models.ShareLink{}, &models.Attachment{},
+		&models.KnowledgeDocument{}, &models.KnowledgeChunk{}, &models.ModerationFlag{},
+		&models.ResponseCacheEntry{}, &models.EmailDelivery{}, &models.Permission{}, &models.Role{},
+		&models.APIKey{}, &models.UserIdentity{}, &models.ErasureRequest{}, &models.AuditLog{})
+	if err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	if _, err := NewRBACService(db); err != nil {
+		t.Fatalf("seeding roles: %v", err)
+	}
+	return db
+}
+
+// createTestUser 创建密码为 secret123 的用户
+func createTestUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+	user, err := (&UserService{DB: db}).Create(&models.User{UserName: name, Email: name + "@example.com"}, "secret123")
+	if err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user
+}
+
+// requireMessage 断言 err 是消息 ID 为 messageID 的 AppError
+func requireMessage(t *testing.T, err error, messageID string) {
+	t.Helper()
+	var appErr *domainErrors.AppError
+	if !errors.As(err, &appErr) || appErr.MessageID != messageID {
+		t.Fatalf("got error %v, want %s", err, messageID)
+	}
+}
+
+type accountTest struct {
+	db       *gorm.DB
+	auth     *AuthService
+	accounts *AccountService
+	mailLog  string
+}
+
+// newAccountTest 使用 LogMailer 创建账户服务，邮件通过 MAIL_LOG_FILE 写入临时文件
+func newAccountTest(t *testing.T) *accountTest {
+	t.Helper()
+	mailLog := filepath.Join(t.TempDir(), "mail.jsonl")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("MAIL_BACKEND", "log")
+	t.Setenv("MAIL_LOG_FILE", mailLog)
+
+	db := newTestDB(t)
+	auth, err := NewAuthService(db)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	mailer, err := NewMailer()
+	if err != nil {
+		t.Fatalf("NewMailer: %v", err)
+	}
+	accounts, err := NewAccountService(db, auth, mailer)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	return &accountTest{db: db, auth: auth, accounts: accounts, mailLog: mailLog}
+}
+
+// mails 返回目前为止写入日志文件的邮件
+func (a *accountTest) mails(t *testing.T) []Mail {
+	t.Helper()
+	f, err := os.Open(a.mailLog)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("opening mail log: %v", err)
+	}
+	defer f.Close()
+
+	var mails []Mail
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var mail Mail
+		if err := json.Unmarshal(scanner.Bytes(), &mail); err != nil {
+			t.Fatalf("decoding mail: %v", err)
+		}
+		mails = append(mails, mail)
+	}
+	return mails
+}
+
+var mailTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// lastToken 返回发给 to 的最后一封邮件中链接的令牌
+func (a *accountTest) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	mails := a.mails(t)
+	for i := len(mails) - 1; i >= 0; i-- {
+		if mails[i].To != to {
+			continue
+		}
+		match := mailTokenPattern.FindStringSubmatch(mails[i].Body)
+		if match == nil {
+			t.Fatalf("mail to %s has no link: %q", to, mails[i].Body)
+		}
+		return match[1]
+	}
+	t.Fatalf("no mail was sent to %s", to)
+	return ""
+}
+
+// accessTokenIssuedAt 签发 iat 为指定时间的访问令牌，模拟之前登录时得到的令牌
+func (a *accountTest) accessTokenIssuedAt(t *testing.T, userID uint, issuedAt time.Time) string {
+	t.Helper()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		ExpiresAt: jwt.NewNumericDate(issuedAt.Add(a.auth.tokenTTL)),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.auth.secret)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	a := newAccountTest(t)
+	ctx := context.Background()
+	user := createTestUser(t, a.db, "alice")
+
+	oldToken := a.accessTokenIssuedAt(t, user.ID, time.Now().Add(-time.Minute))
+	if _, err := a.auth.ParseToken(oldToken); err != nil {
+		t.Fatalf("ParseToken before reset: %v", err)
+	}
+
+	// 未注册的邮箱同样被受理，但不发送邮件
+	a.accounts.RequestPasswordReset(ctx, "nobody@example.com", i18n.English)
+	a.accounts.RequestPasswordReset(ctx, user.Email, i18n.English)
+	a.accounts.Wait()
+	if mails := a.mails(t); len(mails) != 1 || mails[0].To != user.Email {
+		t.Fatalf("sent %+v, want one mail to %s", mails, user.Email)
+	}
+
+	token := a.lastToken(t, user.Email)
+	before, after, err := a.accounts.ResetPassword(ctx, token, "newpass123")
+	if err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	changes := models.UserChanges(before, after)
+	if _, ok := changes["password"]; !ok || len(changes) != 2 {
+		t.Fatalf("changes = %v, want password and email_verified_at", changes)
+	}
+	_, _, err = a.accounts.ResetPassword(ctx, token, "another123")
+	requireMessage(t, err, "invalid_account_token")
+
+	_, err = a.auth.ParseToken(oldToken)
+	requireMessage(t, err, "invalid_token")
+
+	if _, err := a.auth.Login(user.UserName, "secret123"); err == nil {
+		t.Fatal("old password still works after reset")
+	}
+	login, err := a.auth.Login(user.UserName, "newpass123")
+	if err != nil {
+		t.Fatalf("Login with new password: %v", err)
+	}
+	if _, err := a.auth.ParseToken(login.Token); err != nil {
+		t.Fatalf("ParseToken after reset: %v", err)
+	}
+	if login.User.EmailVerifiedAt == nil {
+		t.Fatal("reset link did not mark the email as verified")
+	}
+}
+
+func TestPasswordResetRateLimitIsSilent(t *testing.T) {
+	a := newAccountTest(t)
+	ctx := context.Background()
+	user := createTestUser(t, a.db, "alice")
+
+	a.accounts.RequestPasswordReset(ctx, user.Email, i18n.English)
+	a.accounts.Wait()
+	a.accounts.RequestPasswordReset(ctx, user.Email, i18n.English)
+	a.accounts.Wait()
+	if mails := a.mails(t); len(mails) != 1 {
+		t.Fatalf("sent %d mails within the resend interval, want 1", len(mails))
+	}
+}
+
+func TestVerifyAndChangeEmailFlow(t *testing.T) {
+	a := newAccountTest(t)
+	ctx := context.Background()
+	user := createTestUser(t, a.db, "alice")
+
+	if err := a.accounts.SendVerification(ctx, user.ID, i18n.English); err != nil {
+		t.Fatalf("SendVerification: %v", err)
+	}
+	unverified, verified, err := a.accounts.VerifyEmail(ctx, a.lastToken(t, user.Email))
+	if err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if verified.EmailVerifiedAt == nil {
+		t.Fatal("email is not verified")
+	}
+	if changes := models.UserChanges(unverified, verified); len(changes) != 1 || unverified.EmailVerifiedAt != nil {
+		t.Fatalf("changes = %v, want only email_verified_at", changes)
+	}
+	requireMessage(t, a.accounts.SendVerification(ctx, user.ID, i18n.English), "email_already_verified")
+
+	const newEmail = "alice.new@example.com"
+	requireMessage(t, a.accounts.RequestEmailChange(ctx, user.ID, newEmail, "wrong-password", i18n.English), "password_incorrect")
+	if err := a.accounts.RequestEmailChange(ctx, user.ID, newEmail, "secret123", i18n.English); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	// 确认前邮箱保持不变
+	current, err := a.accounts.user(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("loading user: %v", err)
+	}
+	if current.Email != user.Email {
+		t.Fatalf("email changed to %s before confirmation", current.Email)
+	}
+
+	token := a.lastToken(t, newEmail)
+	before, changed, err := a.accounts.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		t.Fatalf("ConfirmEmailChange: %v", err)
+	}
+	if changed.Email != newEmail || changed.EmailVerifiedAt == nil {
+		t.Fatalf("got email %s verified at %v, want verified %s", changed.Email, changed.EmailVerifiedAt, newEmail)
+	}
+	if change := models.UserChanges(before, changed)["email"]; change.Before != user.Email || change.After != newEmail {
+		t.Fatalf("email change = %+v, want %s -> %s", change, user.Email, newEmail)
+	}
+	_, _, err = a.accounts.ConfirmEmailChange(ctx, token)
+	requireMessage(t, err, "invalid_account_token")
+}
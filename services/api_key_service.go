@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// APIKeyPrefix 是全部 API 密钥的开头，用于区分 API 密钥和登录令牌
+	APIKeyPrefix = "glk_"
+
+	defaultAPIKeyTTLDays    = 365
+	defaultAPIKeyMaxPerUser = 20
+)
+
+// APIKeyService 管理 API 密钥。密钥格式为 glk_<标识>_<随机串>，数据库只保存 SHA-256 摘要和
+// glk_<标识> 部分，随机串有 256 位熵，因此不需要加盐或使用慢哈希
+type APIKeyService struct {
+	DB *gorm.DB
+	// defaultTTL 为 0 时，未指定有效期的密钥永不过期
+	defaultTTL time.Duration
+	maxPerUser int
+}
+
+func NewAPIKeyService(db *gorm.DB) (*APIKeyService, error) {
+	ttlDays, err := intFromEnv("API_KEY_DEFAULT_TTL_DAYS", defaultAPIKeyTTLDays, 0)
+	if err != nil {
+		return nil, err
+	}
+	maxPerUser, err := intFromEnv("API_KEY_MAX_PER_USER", defaultAPIKeyMaxPerUser, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &APIKeyService{
+		DB:         db,
+		defaultTTL: time.Duration(ttlDays) * 24 * time.Hour,
+		maxPerUser: maxPerUser,
+	}, nil
+}
+
+// intFromEnv 读取不小于 minimum 的整数环境变量，未设置时返回 fallback
+func intFromEnv(name string, fallback, minimum int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minimum {
+		return 0, fmt.Errorf("%s must be an integer of at least %d", name, minimum)
+	}
+	return n, nil
+}
+
+// Create 为用户创建 API 密钥。返回值中的完整密钥不会被保存，之后无法再次获取
+func (s *APIKeyService) Create(ctx context.Context, userID uint, request models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	prefix, key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	apiKey := models.APIKey{
+		UserID:  userID,
+		Name:    request.Name,
+		Prefix:  prefix,
+		KeyHash: hashAPIKey(key),
+		Scopes:  uniqueScopes(request.Scopes),
+	}
+	if request.ExpiresInDays != nil {
+		expiresAt := now.AddDate(0, 0, *request.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	} else if s.defaultTTL > 0 {
+		expiresAt := now.Add(s.defaultTTL)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.User{}, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+			}
+			return err
+		}
+		var active int64
+		err := tx.Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+			Count(&active).Error
+		if err != nil {
+			return err
+		}
+		if active >= int64(s.maxPerUser) {
+			return domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "api_key_limit", s.maxPerUser)
+		}
+		return tx.Create(&apiKey).Error
+	})
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &models.CreateAPIKeyResponse{APIKey: apiKey, Key: key}, nil
+}
+
+// List 返回用户的全部密钥（包括已吊销和已过期的），userID 为 nil 时返回所有用户的密钥
+func (s *APIKeyService) List(ctx context.Context, userID *uint) ([]models.APIKey, error) {
+	query := s.DB.WithContext(ctx).Order("id")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	keys := []models.APIKey{}
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return keys, nil
+}
+
+// Revoke 吊销密钥，重复吊销不会修改吊销时间。userID 不为 nil 时只能吊销该用户的密钥
+func (s *APIKeyService) Revoke(ctx context.Context, id uint, userID *uint) (*models.APIKey, error) {
+	query := s.DB.WithContext(ctx)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	var apiKey models.APIKey
+	if err := query.First(&apiKey, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "api_key_not_found")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if apiKey.RevokedAt != nil {
+		return &apiKey, nil
+	}
+
+	now := time.Now()
+	if err := s.DB.WithContext(ctx).Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	apiKey.RevokedAt = &now
+	return &apiKey, nil
+}
+
+// Authenticate 校验密钥，返回未吊销且未过期的密钥
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.DB.WithContext(ctx).Where("key_hash = ?", hashAPIKey(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "api_key_invalid")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if !apiKey.Active(time.Now()) {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "api_key_invalid")
+	}
+	if err := checkUserActive(s.DB.WithContext(ctx), apiKey.UserID); err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// RecordUsage 记录一次使用密钥的请求及其消耗的 token 数
+func (s *APIKeyService) RecordUsage(ctx context.Context, id uint, tokens int) error {
+	err := s.DB.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"last_used_at":  time.Now(),
+		"request_count": gorm.Expr("request_count + 1"),
+		"tokens_used":   gorm.Expr("tokens_used + ?", tokens),
+	}).Error
+	return domainErrors.TranslateDBError(err)
+}
+
+// IsAPIKey 判断凭据是否为 API 密钥而不是登录令牌
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// generateAPIKey 生成密钥，返回用于识别的前缀和完整密钥
+func generateAPIKey() (prefix, key string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	return prefix, prefix + "_" + hex.EncodeToString(secret), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func uniqueScopes(scopes []string) models.APIKeyScopes {
+	unique := models.APIKeyScopes{}
+	for _, scope := range scopes {
+		if !slices.Contains(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxAttachmentSize = 20 << 20
+	defaultMaxImageSize      = 5 << 20
+	defaultMaxImages         = 4
+	defaultOrphanTTL         = 24 * time.Hour
+	maxImageURLLength        = 2048
+	// maxAttachmentsPerMessage 限制一条消息引用的已上传附件数量
+	maxAttachmentsPerMessage = 10
+	// multipartOverhead 是 multipart 请求中文件以外的表单字段和分隔符的余量
+	multipartOverhead = 1 << 20
+)
+
+// 模型支持的图片类型
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ImageInput 是发送给模型的一张图片，URL 可以是 http(s) 地址或 data URL
+type ImageInput struct {
+	URL string `json:"url"`
+}
+
+type AttachmentService struct {
+	DB                  *gorm.DB
+	Storage             Storage
+	ConversationService *ConversationService
+	maxAttachmentSize   int64
+	maxImageSize        int64
+	maxImages           int
+	orphanTTL           time.Duration
+}
+
+func NewAttachmentService(db *gorm.DB, storage Storage, conversationService *ConversationService) (*AttachmentService, error) {
+	s := &AttachmentService{
+		DB:                  db,
+		Storage:             storage,
+		ConversationService: conversationService,
+		maxAttachmentSize:   defaultMaxAttachmentSize,
+		maxImageSize:        defaultMaxImageSize,
+		maxImages:           defaultMaxImages,
+		orphanTTL:           defaultOrphanTTL,
+	}
+
+	for name, apply := range map[string]func(n int){
+		"ATTACHMENT_MAX_SIZE_MB":      func(n int) { s.maxAttachmentSize = int64(n) << 20 },
+		"IMAGE_MAX_SIZE_MB":           func(n int) { s.maxImageSize = int64(n) << 20 },
+		"IMAGE_MAX_COUNT":             func(n int) { s.maxImages = n },
+		"ATTACHMENT_ORPHAN_TTL_HOURS": func(n int) { s.orphanTTL = time.Duration(n) * time.Hour },
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", name)
+			}
+			apply(n)
+		}
+	}
+	return s, nil
+}
+
+// MaxUploadRequestSize 返回上传附件请求体的最大字节数，解析 multipart 之前用于限制请求体
+func (s *AttachmentService) MaxUploadRequestSize() int64 {
+	return s.maxAttachmentSize + multipartOverhead
+}
+
+// MaxChatRequestSize 返回聊天请求体的最大字节数，请求中最多包含 IMAGE_MAX_COUNT 张图片
+func (s *AttachmentService) MaxChatRequestSize() int64 {
+	return s.maxImageSize*int64(s.maxImages) + multipartOverhead
+}
+
+// Upload 保存上传的文件，sessionID 不为空时附件归属该会话。
+// 附件在被消息引用前处于待关联状态，超过 ATTACHMENT_ORPHAN_TTL_HOURS 仍未关联的会被清理。
+func (s *AttachmentService) Upload(ctx context.Context, userID *uint, sessionID string, fileHeader *multipart.FileHeader) (*models.Attachment, error) {
+	if sessionID != "" {
+		if _, err := s.ConversationService.ResolveSessionOwner(sessionID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := readUpload(fileHeader, s.maxAttachmentSize)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("%s: %w", fileHeader.Filename, err))
+	}
+	return s.store(ctx, userID, sessionID, fileHeader.Filename, data, http.DetectContentType(data))
+}
+
+// store 把文件写入存储后端并保存附件元数据
+func (s *AttachmentService) store(ctx context.Context, userID *uint, sessionID, fileName string, data []byte, contentType string) (*models.Attachment, error) {
+	checksum := sha256.Sum256(data)
+	key := path.Join("attachments", time.Now().UTC().Format("2006/01/02"), uuid.New().String())
+	if err := s.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+
+	attachment := &models.Attachment{
+		SessionID:   sessionID,
+		UserID:      userID,
+		Source:      models.AttachmentSourceUpload,
+		FileName:    path.Base(fileName),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(checksum[:]),
+		StorageKey:  key,
+	}
+	if err := s.DB.Create(attachment).Error; err != nil {
+		_ = s.Storage.Delete(ctx, key)
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return attachment, nil
+}
+
+// Get 返回用户自己上传的附件
+func (s *AttachmentService) Get(id uint, userID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &attachment, nil
+}
+
+// Open 读取附件内容，调用方负责关闭
+func (s *AttachmentService) Open(ctx context.Context, attachment *models.Attachment) (io.ReadCloser, error) {
+	if attachment.StorageKey == "" {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+	}
+	r, err := s.Storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		return nil, domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	return r, nil
+}
+
+// Delete 删除用户自己上传的附件及其内容
+func (s *AttachmentService) Delete(ctx context.Context, id uint, userID uint) error {
+	attachment, err := s.Get(id, userID)
+	if err != nil {
+		return err
+	}
+	return s.remove(ctx, attachment)
+}
+
+func (s *AttachmentService) remove(ctx context.Context, attachment *models.Attachment) error {
+	if attachment.StorageKey != "" {
+		if err := s.Storage.Delete(ctx, attachment.StorageKey); err != nil {
+			return domainErrors.NewAppError(err, domainErrors.UnknownError)
+		}
+	}
+	if err := s.DB.Delete(attachment).Error; err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	return nil
+}
+
+// ListBySession 返回用户在会话中的附件
+func (s *AttachmentService) ListBySession(sessionID string, userID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := s.DB.Where("session_id = ? AND user_id = ?", sessionID, userID).Order("created_at asc, id asc").Find(&attachments).Error
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return attachments, nil
+}
+
+// PrepareAttachments 校验并保存消息附带的图片、图片地址以及引用的已上传附件，
+// 返回的附件随消息一起保存后即与消息关联
+func (s *AttachmentService) PrepareAttachments(
+	ctx context.Context,
+	userID *uint,
+	sessionID string,
+	files []*multipart.FileHeader,
+	urls []string,
+	attachmentIDs []uint,
+) ([]models.Attachment, error) {
+	if len(attachmentIDs) > maxAttachmentsPerMessage {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "too_many_attachments", maxAttachmentsPerMessage)
+	}
+
+	var referenced []models.Attachment
+	if len(attachmentIDs) > 0 {
+		if userID == nil {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "attachment_requires_auth")
+		}
+		if err := s.DB.Where("id IN ? AND user_id = ?", attachmentIDs, *userID).Find(&referenced).Error; err != nil {
+			return nil, domainErrors.TranslateDBError(err)
+		}
+		if len(referenced) != len(attachmentIDs) {
+			return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		for _, attachment := range referenced {
+			if attachment.MessageID != nil || (attachment.SessionID != "" && attachment.SessionID != sessionID) {
+				return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "attachment_in_use", attachment.ID)
+			}
+		}
+	}
+
+	imageCount := len(files) + len(urls)
+	for _, attachment := range referenced {
+		if attachment.IsImage() {
+			imageCount++
+		}
+	}
+	if imageCount > s.maxImages {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "too_many_images", s.maxImages)
+	}
+
+	// 先完成全部校验，再写入存储
+	uploads := make([][]byte, 0, len(files))
+	for _, fileHeader := range files {
+		data, err := readUpload(fileHeader, s.maxImageSize)
+		if err != nil {
+			return nil, validationError(fmt.Errorf("%s: %w", fileHeader.Filename, err))
+		}
+		if contentType := http.DetectContentType(data); !supportedImageTypes[contentType] {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "unsupported_image_type", fileHeader.Filename, contentType)
+		}
+		uploads = append(uploads, data)
+	}
+
+	attachments := make([]models.Attachment, 0, len(files)+len(urls)+len(referenced))
+	for _, rawURL := range urls {
+		contentType, err := validateImageURL(rawURL)
+		if err != nil {
+			return nil, validationError(err)
+		}
+		attachments = append(attachments, models.Attachment{
+			SessionID:   sessionID,
+			UserID:      userID,
+			Source:      models.AttachmentSourceURL,
+			URL:         rawURL,
+			ContentType: contentType,
+		})
+	}
+	for i, data := range uploads {
+		attachment, err := s.store(ctx, userID, sessionID, files[i].Filename, data, http.DetectContentType(data))
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	for _, attachment := range referenced {
+		attachment.SessionID = sessionID
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// LoadImages 把消息中的图片附件转换为模型输入，上传的图片以 data URL 发送
+func (s *AttachmentService) LoadImages(ctx context.Context, attachments []models.Attachment) []ImageInput {
+	var images []ImageInput
+	for _, attachment := range attachments {
+		switch {
+		case attachment.Source == models.AttachmentSourceURL:
+			images = append(images, ImageInput{URL: attachment.URL})
+		case supportedImageTypes[attachment.ContentType]:
+			data, err := s.readAll(ctx, &attachment)
+			if err != nil {
+				log.Printf("failed to load attachment %d: %v", attachment.ID, err)
+				continue
+			}
+			images = append(images, ImageInput{
+				URL: "data:" + attachment.ContentType + ";base64," + base64.StdEncoding.EncodeToString(data),
+			})
+		}
+	}
+	return images
+}
+
+func (s *AttachmentService) readAll(ctx context.Context, attachment *models.Attachment) ([]byte, error) {
+	r, err := s.Open(ctx, attachment)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// CleanupOrphans 删除超过保留时间仍未关联消息的上传附件，返回删除的数量
+func (s *AttachmentService) CleanupOrphans(ctx context.Context) (int, error) {
+	var orphans []models.Attachment
+	cutoff := time.Now().Add(-s.orphanTTL)
+	err := s.DB.Where("message_id IS NULL AND created_at < ?", cutoff).Limit(500).Find(&orphans).Error
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range orphans {
+		if err := s.remove(ctx, &orphans[i]); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartOrphanCleanup 在后台定期清理未关联消息的附件，直到 ctx 被取消。后台任务退出时调用 wg.Done
+func (s *AttachmentService) StartOrphanCleanup(ctx context.Context, interval time.Duration, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := s.CleanupOrphans(ctx)
+				if err != nil {
+					log.Printf("attachment cleanup failed: %v", err)
+				} else if removed > 0 {
+					log.Printf("removed %d orphaned attachments", removed)
+				}
+			}
+		}
+	}()
+}
+
+// readUpload 读取上传的文件并检查大小
+func readUpload(fileHeader *multipart.FileHeader, maxSize int64) ([]byte, error) {
+	if fileHeader.Size > maxSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxSize)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxSize)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	return data, nil
+}
+
+// validateImageURL 检查图片地址，并根据扩展名推断图片类型（无法推断时返回空字符串）
+func validateImageURL(rawURL string) (string, error) {
+	if len(rawURL) > maxImageURLLength {
+		return "", domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "image_url_too_long", maxImageURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "image_url_invalid", rawURL)
+	}
+
+	ext := path.Ext(u.Path)
+	if ext == "" {
+		return "", nil
+	}
+	contentType, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if contentType != "" && !supportedImageTypes[contentType] {
+		return "", domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "unsupported_image_url", rawURL)
+	}
+	return contentType, nil
+}
+
+// validationError 把错误包装为 ValidationError，已经是 AppError 的错误原样返回以保留其消息ID
+func validationError(err error) error {
+	var appErr *domainErrors.AppError
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return domainErrors.NewAppError(err, domainErrors.ValidationError)
+}
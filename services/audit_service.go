@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+// auditedConfigPrefixes 是启动时记录到审计日志的环境变量前缀
+var auditedConfigPrefixes = []string{
+	"ADMIN_USER_IDS", "AI_MAX_TOOL_ITERATIONS", "API_KEY_", "APP_BASE_URL", "ATTACHMENT_", "AZURE_OPENAI_",
+	"EMAIL_", "EMBEDDING_", "HEALTH_", "HTTP_", "IMAGE_", "JWT_", "KB_", "LOG_", "MAIL_", "METRICS_",
+	"MODERATION_", "MYSQL_", "OIDC_", "OTEL_", "PASSWORD_", "PII_", "PORT", "RESPONSE_CACHE", "S3_", "SHUTDOWN_", "SMTP_",
+	"STORAGE_", "TOOL_",
+}
+
+// plainConfigKeys 是不含密钥、可以原样记录的环境变量。
+// 其他被审计的环境变量（包括 OTEL_EXPORTER_OTLP_HEADERS 这类可能带凭据的配置）只记录摘要
+var plainConfigKeys = []string{
+	"ADMIN_USER_IDS", "AI_MAX_TOOL_ITERATIONS", "API_KEY_DEFAULT_TTL_DAYS", "API_KEY_MAX_PER_USER", "APP_BASE_URL",
+	"ATTACHMENT_MAX_SIZE_MB", "ATTACHMENT_ORPHAN_TTL_HOURS", "AZURE_OPENAI_DEPLOYMENT_NAME",
+	"AZURE_OPENAI_EMBEDDING_DEPLOYMENT_NAME", "AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_VISION_DEPLOYMENT_NAME",
+	"EMAIL_CHANGE_TTL_SECONDS", "EMAIL_DAILY_LIMIT", "EMAIL_RESEND_INTERVAL_SECONDS", "EMAIL_VERIFICATION_TTL_SECONDS",
+	"EMBEDDING_BATCH_SIZE", "EMBEDDING_MAX_INPUTS", "EMBEDDING_MAX_INPUT_CHARS", "HEALTH_CHECK_TIMEOUT_SECONDS",
+	"HEALTH_LLM_PROBE", "HEALTH_LLM_PROBE_TTL_SECONDS", "HTTP_IDLE_TIMEOUT_SECONDS", "HTTP_READ_TIMEOUT_SECONDS",
+	"HTTP_WRITE_TIMEOUT_SECONDS", "IMAGE_MAX_COUNT", "IMAGE_MAX_SIZE_MB", "JWT_TTL_HOURS", "KB_CHUNK_OVERLAP",
+	"KB_CHUNK_SIZE", "KB_MIN_SCORE", "KB_TOP_K", "KB_VECTOR_STORE", "LOG_BODY", "LOG_BODY_MAX_BYTES", "LOG_FORMAT",
+	"LOG_LEVEL", "LOG_REDACT_FIELDS", "MAIL_BACKEND", "MAIL_FROM", "MAIL_LOG_FILE", "METRICS_ADDR", "MODERATION_CONFIG",
+	"MYSQL_HOST", "MYSQL_PORT", "MYSQL_USER", "OIDC_AUTO_PROVISION", "OIDC_POST_LOGIN_URL", "OIDC_PROVIDERS",
+	"OIDC_REDIRECT_BASE_URL", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	"OTEL_SERVICE_NAME", "PASSWORD_RESET_TTL_SECONDS", "PII_REDACTION", "PII_REDACTION_PATTERNS", "PORT",
+	"RESPONSE_CACHE", "RESPONSE_CACHE_MAX_ENTRIES", "RESPONSE_CACHE_MAX_ENTRY_SIZE", "RESPONSE_CACHE_TTL_SECONDS",
+	"S3_BUCKET", "S3_ENDPOINT", "S3_REGION", "S3_USE_SSL", "SHUTDOWN_TIMEOUT_SECONDS", "SMTP_HOST", "SMTP_PORT",
+	"SMTP_USERNAME", "STORAGE_BACKEND", "STORAGE_LOCAL_DIR", "TOOL_ORDER_STATUS_URL", "TOOL_TICKET_URL",
+}
+
+// plainOIDCProviderKeys 是 OIDC_<名称>_ 形式的身份提供方配置中可以原样记录的部分
+var plainOIDCProviderKeys = []string{"_ISSUER", "_CLIENT_ID", "_SCOPES", "_DISPLAY_NAME"}
+
+// AuditService 记录管理操作和安全相关操作。审计日志只能追加，不提供修改和删除
+type AuditService struct {
+	DB *gorm.DB
+	// configKey 是计算密钥类配置摘要的 HMAC 密钥
+	configKey []byte
+}
+
+func NewAuditService(db *gorm.DB, authService *AuthService) (*AuditService, error) {
+	return &AuditService{DB: db, configKey: authService.signingKey("audit-config")}, nil
+}
+
+// Record 追加一条审计日志，Outcome 为空时按成功记录
+func (s *AuditService) Record(ctx context.Context, entry models.AuditLog) error {
+	if entry.Outcome == "" {
+		entry.Outcome = models.AuditOutcomeSuccess
+	}
+	if err := s.DB.WithContext(ctx).Create(&entry).Error; err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	return nil
+}
+
+// List 按条件查询审计日志，按时间倒序返回，同时返回符合条件的总数
+func (s *AuditService) List(ctx context.Context, query models.AuditLogQuery) ([]models.AuditLog, int64, error) {
+	db := s.DB.WithContext(ctx).Model(&models.AuditLog{})
+	for column, value := range map[string]string{
+		"action":      query.Action,
+		"outcome":     query.Outcome,
+		"target_type": query.TargetType,
+		"target_id":   query.TargetID,
+		"request_id":  query.RequestID,
+		"ip":          query.IP,
+	} {
+		if value != "" {
+			db = db.Where(column+" = ?", value)
+		}
+	}
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at < ?", query.To)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, domainErrors.TranslateDBError(err)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	var entries []models.AuditLog
+	if err := db.Order("created_at desc, id desc").Limit(limit).Offset(query.Offset).Find(&entries).Error; err != nil {
+		return nil, 0, domainErrors.TranslateDBError(err)
+	}
+	return entries, total, nil
+}
+
+// RecordConfiguration 比较当前配置与上次记录的配置，有变化时记录被修改的环境变量。
+// 密钥类配置只记录以 JWT_SECRET 派生的密钥计算的 HMAC-SHA256，可以看出是否被更换，
+// 拿到审计日志也无法离线穷举出原值。未配置 JWT_SECRET 时每次启动的密钥不同，密钥类配置都会记录为已修改
+func (s *AuditService) RecordConfiguration(ctx context.Context) error {
+	current := s.currentConfiguration()
+
+	var last models.AuditLog
+	previous := map[string]any{}
+	err := s.DB.WithContext(ctx).Where("action = ?", models.AuditActionConfigChange).Order("id desc").First(&last).Error
+	switch {
+	case err == nil:
+		if config, ok := last.Metadata["config"].(map[string]any); ok {
+			previous = config
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	changes := models.AuditChanges{}
+	for name, value := range current {
+		if before, ok := previous[name]; !ok || before != value {
+			changes[name] = models.AuditChange{Before: previous[name], After: value}
+		}
+	}
+	for name, before := range previous {
+		if _, ok := current[name]; !ok {
+			changes[name] = models.AuditChange{Before: before, After: nil}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	config := make(map[string]any, len(current))
+	for name, value := range current {
+		config[name] = value
+	}
+	return s.Record(ctx, models.AuditLog{
+		Action:     models.AuditActionConfigChange,
+		TargetType: models.AuditTargetConfig,
+		Changes:    changes,
+		Metadata:   models.AuditMetadata{"config": config},
+	})
+}
+
+// currentConfiguration 返回需要审计的环境变量，不在 plainConfigKeys 中的配置替换为摘要
+func (s *AuditService) currentConfiguration() map[string]string {
+	config := map[string]string{}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !slices.ContainsFunc(auditedConfigPrefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			continue
+		}
+		if !isPlainConfig(name) {
+			mac := hmac.New(sha256.New, s.configKey)
+			mac.Write([]byte(value))
+			value = "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+		}
+		config[name] = value
+	}
+	return config
+}
+
+// isPlainConfig 判断环境变量是否不含密钥，可以原样记录
+func isPlainConfig(name string) bool {
+	if slices.Contains(plainConfigKeys, name) {
+		return true
+	}
+	provider, ok := strings.CutPrefix(name, "OIDC_")
+	return ok && slices.ContainsFunc(plainOIDCProviderKeys, func(suffix string) bool {
+		return strings.HasSuffix(provider, suffix) && len(provider) > len(suffix)
+	})
+}
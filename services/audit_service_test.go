@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+// configAudit 使用指定的 JWT_SECRET 记录当前配置，返回审计日志中保存的配置
+func configAudit(t *testing.T, secret string) map[string]any {
+	t.Helper()
+	t.Setenv("JWT_SECRET", secret)
+	db := newTestDB(t)
+	auth, err := NewAuthService(db)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	audit, err := NewAuditService(db, auth)
+	if err != nil {
+		t.Fatalf("NewAuditService: %v", err)
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := audit.RecordConfiguration(ctx); err != nil {
+			t.Fatalf("RecordConfiguration: %v", err)
+		}
+	}
+
+	var logs []models.AuditLog
+	if err := db.Where("action = ?", models.AuditActionConfigChange).Find(&logs).Error; err != nil {
+		t.Fatalf("loading audit logs: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("recorded %d configuration changes, want 1 for an unchanged configuration", len(logs))
+	}
+	return logs[0].Metadata["config"].(map[string]any)
+}
+
+func TestRecordConfigurationDigestsSecrets(t *testing.T) {
+	plain := map[string]string{
+		"MYSQL_HOST":                  "db.internal",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+		"OIDC_CORP_CLIENT_ID":         "chatbot",
+		"METRICS_ADDR":                "127.0.0.1:9090",
+	}
+	secret := map[string]string{
+		"MYSQL_PASSWORD":             "hunter2",
+		"OTEL_EXPORTER_OTLP_HEADERS": "Authorization=Bearer collector-token",
+		"OIDC_CORP_CLIENT_SECRET":    "oidc-secret",
+		"METRICS_TOKEN":              "scrape-token",
+	}
+	for name, value := range plain {
+		t.Setenv(name, value)
+	}
+	for name, value := range secret {
+		t.Setenv(name, value)
+	}
+
+	var first, second map[string]any
+	t.Run("first", func(t *testing.T) { first = configAudit(t, "first-secret") })
+	t.Run("second", func(t *testing.T) { second = configAudit(t, "second-secret") })
+	if first == nil || second == nil {
+		t.FailNow()
+	}
+
+	for name, value := range plain {
+		if first[name] != value {
+			t.Errorf("%s = %v, want %s", name, first[name], value)
+		}
+	}
+	for name, value := range secret {
+		digest, _ := first[name].(string)
+		if !strings.HasPrefix(digest, "hmac-sha256:") || strings.Contains(digest, value) {
+			t.Errorf("%s = %q, want an HMAC digest", name, digest)
+		}
+		// 换一个服务端密钥，相同的值得到不同的摘要
+		if second[name] == first[name] {
+			t.Errorf("%s digest does not depend on the server secret", name)
+		}
+	}
+}
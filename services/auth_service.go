@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const defaultTokenTTL = 24 * time.Hour
+
+type AuthService struct {
+	DB       *gorm.DB
+	secret   []byte
+	tokenTTL time.Duration
+}
+
+func NewAuthService(db *gorm.DB) (*AuthService, error) {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	if len(secret) == 0 {
+		// 未配置密钥时使用随机密钥，服务重启后已签发的令牌会失效
+		log.Println("JWT_SECRET is not set, using a random secret")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenTTL := defaultTokenTTL
+	if v := os.Getenv("JWT_TTL_HOURS"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours <= 0 {
+			return nil, errors.New("JWT_TTL_HOURS must be a positive integer")
+		}
+		tokenTTL = time.Duration(hours) * time.Hour
+	}
+
+	return &AuthService{DB: db, secret: secret, tokenTTL: tokenTTL}, nil
+}
+
+// HashPassword 使用 bcrypt 计算密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Login 校验用户名和密码，成功后签发访问令牌
+func (s *AuthService) Login(userName, password string) (*models.LoginResponse, error) {
+	var user models.User
+	err := s.DB.Preload("Roles").Where("user_name = ?", userName).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "invalid_credentials")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.HashPassword), []byte(password)) != nil {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "invalid_credentials")
+	}
+	// 密码正确后才提示账户已停用，避免泄露账户状态
+	if user.DeactivatedAt != nil {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthorized, "account_deactivated")
+	}
+
+	token, expiresAt, err := s.GenerateToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{Token: token, ExpiresAt: expiresAt, User: models.NewUserResponse(&user)}, nil
+}
+
+// GenerateToken 为指定用户签发 HS256 令牌
+func (s *AuthService) GenerateToken(userID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(s.tokenTTL)
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", time.Time{}, domainErrors.NewAppError(err, domainErrors.TokenGeneratorError)
+	}
+	return token, expiresAt, nil
+}
+
+// ParseToken 校验令牌并返回其中的用户ID
+func (s *AuthService) ParseToken(tokenString string) (uint, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "invalid_token")
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "invalid_token")
+	}
+	// 用户被删除或停用后，之前签发的令牌立即失效
+	user, err := activeUser(s.DB, uint(userID))
+	if err != nil {
+		return 0, err
+	}
+	// 重置密码后，之前签发的令牌同样失效。iat 只精确到秒，因此与修改时间按秒比较
+	if user.PasswordChangedAt != nil &&
+		(claims.IssuedAt == nil || claims.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second))) {
+		return 0, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "invalid_token")
+	}
+	return uint(userID), nil
+}
+
+// checkUserActive 确认用户存在、未被删除且未被停用
+func checkUserActive(db *gorm.DB, userID uint) error {
+	_, err := activeUser(db, userID)
+	return err
+}
+
+// activeUser 返回存在、未被删除且未被停用的用户，只包含校验令牌需要的字段
+func activeUser(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := db.Select("id", "deactivated_at", "password_changed_at").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "account_deactivated")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if user.DeactivatedAt != nil {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "account_deactivated")
+	}
+	return &user, nil
+}
+
+// signingKey 从 JWT_SECRET 为指定用途派生独立的签名密钥，使访问令牌和各类账户邮件令牌不能互相替代
+func (s *AuthService) signingKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -5,29 +5,148 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/thoulee21/go-learn/metrics"
+	"github.com/thoulee21/go-learn/models"
+	"github.com/thoulee21/go-learn/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaxToolIterations = 5
+	// defaultEmbeddingBatchSize 是单次 embeddings 请求包含的最大文本数
+	defaultEmbeddingBatchSize = 16
+	// defaultMaxEmbeddingInputs 和 defaultMaxEmbeddingInputChars 限制 /embeddings 接口的输入规模，
+	// 按每个 token 约 4 个字符估算，单条文本不超过模型 8191 token 的上限
+	defaultMaxEmbeddingInputs     = 256
+	defaultMaxEmbeddingInputChars = 24000
 )
 
 type AIService struct {
-	client          *azopenai.Client
-	deploymentName  string
-	maxTokens       int32
-	temperature     float32
-	topP            float32
-	freqPenalty     float32
-	presencePenalty float32
-	stop            []string
+	client                 *azopenai.Client
+	deploymentName         string
+	visionDeployment       string
+	embeddingDeployment    string
+	maxTokens              int32
+	temperature            float32
+	topP                   float32
+	freqPenalty            float32
+	presencePenalty        float32
+	stop                   []string
+	tools                  *ToolRegistry
+	maxToolIterations      int
+	embeddingBatchSize     int
+	maxEmbeddingInputs     int
+	maxEmbeddingInputChars int
+	cache                  ResponseCache
 }
 
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string            `json:"role"`
+	Content    string            `json:"content"`
+	ToolCalls  []models.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	// Images 是 user 消息附带的图片
+	Images []ImageInput `json:"images,omitempty"`
+}
+
+// ChatResult 是一次生成的结果
+type ChatResult struct {
+	// Content 是最终回复的文本
+	Content string
+	// Messages 是工具调用过程中产生的 assistant/tool 消息，按顺序排列，不包含最终回复
+	Messages []ChatMessage
+	// FilterDetections 是服务商内容过滤检测到但未拦截的类别
+	FilterDetections []string
+	// CacheStatus 是回复缓存的状态（HIT、MISS、BYPASS），未启用缓存时为空
+	CacheStatus string
+	// CachedAt 是命中缓存时该回复写入缓存的时间
+	CachedAt time.Time
+	// PromptTokens 和 CompletionTokens 是本次生成（包括工具调用的各轮）使用的 token 数
+	PromptTokens     int
+	CompletionTokens int
+	// FinishReason 是最后一次调用的结束原因，例如 stop、length
+	FinishReason string
+}
+
+// addUsage 累加一次调用的 token 用量
+func (r *ChatResult) addUsage(usage *azopenai.CompletionsUsage) {
+	if usage == nil {
+		return
+	}
+	if usage.PromptTokens != nil {
+		r.PromptTokens += int(*usage.PromptTokens)
+	}
+	if usage.CompletionTokens != nil {
+		r.CompletionTokens += int(*usage.CompletionTokens)
+	}
+}
+
+// observeUsage 记录一次生成的 token 用量
+func observeUsage(deployment string, result *ChatResult) {
+	if result.PromptTokens == 0 && result.CompletionTokens == 0 {
+		return
+	}
+	metrics.LLMTokens.WithLabelValues(deployment, "prompt").Observe(float64(result.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(deployment, "completion").Observe(float64(result.CompletionTokens))
+}
+
+// startChatSpan 为一次生成创建 span，属性命名遵循 OpenTelemetry 的 gen_ai 语义约定
+func (s *AIService) startChatSpan(ctx context.Context, operation string, messages []ChatMessage) (context.Context, trace.Span) {
+	deployment := *s.deploymentFor(messages)
+	return tracing.Tracer().Start(ctx, operation+" "+deployment,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.system", "az.ai.openai"),
+			attribute.String("gen_ai.operation.name", operation),
+			attribute.String("gen_ai.request.model", deployment),
+			attribute.Int("gen_ai.request.max_tokens", int(s.maxTokens)),
+			attribute.Float64("gen_ai.request.temperature", float64(s.temperature)),
+			attribute.Int("chat.messages", len(messages)),
+		))
+}
+
+// endChatSpan 记录生成结果并结束 span
+func endChatSpan(span trace.Span, result *ChatResult, err error) {
+	defer span.End()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", result.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", result.CompletionTokens),
+		attribute.Int("chat.tool_messages", len(result.Messages)),
+	)
+	if result.FinishReason != "" {
+		span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{result.FinishReason}))
+	}
+	if result.CacheStatus != "" {
+		span.SetAttributes(attribute.String("chat.cache", result.CacheStatus))
+	}
+}
+
+// observeLLMCall 记录一次服务商调用的耗时和错误类型
+func observeLLMCall(operation, deployment string, start time.Time, err error) {
+	metrics.LLMRequestDuration.WithLabelValues(operation, deployment).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.LLMErrors.WithLabelValues(operation, metrics.ErrorClass(err)).Inc()
+	}
 }
 
-func NewAIService() (*AIService, error) {
+// NewAIService 创建 AI 服务，tools 为 nil 时不启用工具调用，cache 为 nil 时不缓存回复
+func NewAIService(tools *ToolRegistry, cache ResponseCache) (*AIService, error) {
 	azureOpenAIEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
 	azureOpenAIKey := os.Getenv("AZURE_OPENAI_API_KEY")
 	deploymentName := os.Getenv("AZURE_OPENAI_DEPLOYMENT_NAME")
@@ -38,6 +157,30 @@ func NewAIService() (*AIService, error) {
 		)
 	}
 
+	maxToolIterations := defaultMaxToolIterations
+	if v := os.Getenv("AI_MAX_TOOL_ITERATIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, errors.New("AI_MAX_TOOL_ITERATIONS must be a non-negative integer")
+		}
+		maxToolIterations = n
+	}
+
+	embeddingLimits := map[string]int{
+		"EMBEDDING_BATCH_SIZE":      defaultEmbeddingBatchSize,
+		"EMBEDDING_MAX_INPUTS":      defaultMaxEmbeddingInputs,
+		"EMBEDDING_MAX_INPUT_CHARS": defaultMaxEmbeddingInputChars,
+	}
+	for name := range embeddingLimits {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", name)
+			}
+			embeddingLimits[name] = n
+		}
+	}
+
 	cred := azcore.NewKeyCredential(azureOpenAIKey)
 	client, err := azopenai.NewClientWithKeyCredential(azureOpenAIEndpoint, cred, nil)
 	if err != nil {
@@ -45,14 +188,22 @@ func NewAIService() (*AIService, error) {
 	}
 
 	return &AIService{
-		client:          client,
-		deploymentName:  deploymentName,
-		maxTokens:       800,
-		temperature:     0.7,
-		topP:            0.95,
-		freqPenalty:     0,
-		presencePenalty: 0,
-		stop:            []string{},
+		client:                 client,
+		deploymentName:         deploymentName,
+		visionDeployment:       os.Getenv("AZURE_OPENAI_VISION_DEPLOYMENT_NAME"),
+		embeddingDeployment:    os.Getenv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT_NAME"),
+		maxTokens:              800,
+		temperature:            0.7,
+		topP:                   0.95,
+		freqPenalty:            0,
+		presencePenalty:        0,
+		stop:                   []string{},
+		tools:                  tools,
+		maxToolIterations:      maxToolIterations,
+		embeddingBatchSize:     embeddingLimits["EMBEDDING_BATCH_SIZE"],
+		maxEmbeddingInputs:     embeddingLimits["EMBEDDING_MAX_INPUTS"],
+		maxEmbeddingInputChars: embeddingLimits["EMBEDDING_MAX_INPUT_CHARS"],
+		cache:                  cache,
 	}, nil
 }
 
@@ -67,11 +218,27 @@ func (s *AIService) convertToAzureMessages(messages []ChatMessage) ([]azopenai.C
 			})
 		case "user":
 			azMessages = append(azMessages, &azopenai.ChatRequestUserMessage{
-				Content: azopenai.NewChatRequestUserMessageContent(msg.Content),
+				Content: userMessageContent(msg),
 			})
 		case "assistant":
-			azMessages = append(azMessages, &azopenai.ChatRequestAssistantMessage{
+			assistantMessage := &azopenai.ChatRequestAssistantMessage{
 				Content: azopenai.NewChatRequestAssistantMessageContent(msg.Content),
+			}
+			for _, call := range msg.ToolCalls {
+				assistantMessage.ToolCalls = append(assistantMessage.ToolCalls, &azopenai.ChatCompletionsFunctionToolCall{
+					ID:   toPtr(call.ID),
+					Type: toPtr("function"),
+					Function: &azopenai.FunctionCall{
+						Name:      toPtr(call.Name),
+						Arguments: toPtr(call.Arguments),
+					},
+				})
+			}
+			azMessages = append(azMessages, assistantMessage)
+		case "tool":
+			azMessages = append(azMessages, &azopenai.ChatRequestToolMessage{
+				Content:    azopenai.NewChatRequestToolMessageContent(msg.Content),
+				ToolCallID: toPtr(msg.ToolCallID),
 			})
 		default:
 			return nil, fmt.Errorf("unsupported role: %s", msg.Role)
@@ -81,64 +248,329 @@ func (s *AIService) convertToAzureMessages(messages []ChatMessage) ([]azopenai.C
 	return azMessages, nil
 }
 
-func (s *AIService) GenerateResponse(ctx context.Context, messages []ChatMessage) (string, error) {
-	// 将我们的消息格式转换为 Azure SDK 的消息格式
-	azMessages, err := s.convertToAzureMessages(messages)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := s.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
-		Messages:         azMessages,
-		DeploymentName:   &s.deploymentName,
-		MaxTokens:        &s.maxTokens,
-		Temperature:      &s.temperature,
-		TopP:             &s.topP,
-		FrequencyPenalty: &s.freqPenalty,
-		PresencePenalty:  &s.presencePenalty,
-		Stop:             s.stop,
-	}, nil)
+// userMessageContent 构造 user 消息内容，带图片时以多段内容发送
+func userMessageContent(msg ChatMessage) *azopenai.ChatRequestUserMessageContent {
+	if len(msg.Images) == 0 {
+		return azopenai.NewChatRequestUserMessageContent(msg.Content)
+	}
 
-	if err != nil {
-		return "", err
+	parts := make([]azopenai.ChatCompletionRequestMessageContentPartClassification, 0, len(msg.Images)+1)
+	if msg.Content != "" {
+		parts = append(parts, &azopenai.ChatCompletionRequestMessageContentPartText{Text: toPtr(msg.Content)})
+	}
+	for _, image := range msg.Images {
+		parts = append(parts, &azopenai.ChatCompletionRequestMessageContentPartImage{
+			ImageURL: &azopenai.ChatCompletionRequestMessageContentPartImageURL{
+				URL:    toPtr(image.URL),
+				Detail: toPtr(azopenai.ChatCompletionRequestMessageContentPartImageURLDetailAuto),
+			},
+		})
+	}
+	return azopenai.NewChatRequestUserMessageContent(parts)
+}
+
+// deploymentFor 对包含图片的对话使用支持视觉的部署，未配置时使用默认部署
+func (s *AIService) deploymentFor(messages []ChatMessage) *string {
+	if s.visionDeployment != "" {
+		for _, msg := range messages {
+			if len(msg.Images) > 0 {
+				return &s.visionDeployment
+			}
+		}
+	}
+	return &s.deploymentName
+}
+
+// toolsForIteration 返回本轮可用的工具；达到最大轮数后不再提供工具，迫使模型给出最终回复。
+// 此后模型仍然请求调用工具时不再执行，返回 ErrToolIterationsExceeded
+func (s *AIService) toolsForIteration(ctx context.Context, iteration int) []azopenai.ChatCompletionsToolDefinitionClassification {
+	if iteration >= s.maxToolIterations {
+		return nil
+	}
+	return s.tools.definitions(ctx)
+}
+
+// runToolCalls 执行模型请求的工具调用，并将 assistant 和 tool 消息追加到对话与结果中
+func (s *AIService) runToolCalls(
+	ctx context.Context,
+	messages []ChatMessage,
+	result *ChatResult,
+	content string,
+	calls []models.ToolCall,
+) []ChatMessage {
+	// 开启脱敏时，发送给模型的消息使用占位符，工具执行和返回给调用方的消息使用原始值
+	vault := PIIVaultFrom(ctx)
+	messages = append(messages, ChatMessage{Role: "assistant", Content: content, ToolCalls: calls})
+	result.Messages = append(result.Messages, ChatMessage{
+		Role:      "assistant",
+		Content:   vault.Restore(content),
+		ToolCalls: vault.RestoreToolCalls(calls),
+	})
+
+	for _, call := range vault.RestoreToolCalls(calls) {
+		toolCtx, span := tracing.Tracer().Start(ctx, "execute_tool "+call.Name,
+			trace.WithAttributes(attribute.String("gen_ai.tool.name", call.Name), attribute.String("gen_ai.tool.call.id", call.ID)))
+		output := s.tools.Execute(toolCtx, call)
+		span.End()
+		messages = append(messages, ChatMessage{Role: "tool", Content: vault.Redact(output), ToolCallID: call.ID})
+		result.Messages = append(result.Messages, ChatMessage{Role: "tool", Content: output, ToolCallID: call.ID})
+	}
+	return messages
+}
+
+func toToolCalls(calls []azopenai.ChatCompletionsToolCallClassification) []models.ToolCall {
+	toolCalls := make([]models.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		fc, ok := call.(*azopenai.ChatCompletionsFunctionToolCall)
+		if !ok || fc.ID == nil || fc.Function == nil || fc.Function.Name == nil {
+			continue
+		}
+		toolCall := models.ToolCall{ID: *fc.ID, Name: *fc.Function.Name}
+		if fc.Function.Arguments != nil {
+			toolCall.Arguments = *fc.Function.Arguments
+		}
+		toolCalls = append(toolCalls, toolCall)
+	}
+	return toolCalls
+}
+
+// GenerateResponse 生成回复。ctx 携带 PIIVault 时，消息中的个人信息在发送前替换为占位符，回复中的占位符会被还原。
+func (s *AIService) GenerateResponse(ctx context.Context, messages []ChatMessage) (*ChatResult, error) {
+	ctx, span := s.startChatSpan(ctx, "chat", messages)
+	result, err := s.generateResponse(ctx, messages)
+	endChatSpan(span, result, err)
+	return result, err
+}
+
+func (s *AIService) generateResponse(ctx context.Context, messages []ChatMessage) (*ChatResult, error) {
+	result := &ChatResult{}
+	vault := PIIVaultFrom(ctx)
+	messages = vault.RedactMessages(messages)
+
+	// 缓存键基于脱敏后的消息计算，缓存中不保存个人信息
+	cacheKey := s.lookupCache(ctx, messages, result)
+	if result.CacheStatus == CacheHit {
+		result.Content = vault.Restore(result.Content)
+		return result, nil
+	}
+
+	for iteration := 0; ; iteration++ {
+		// 将我们的消息格式转换为 Azure SDK 的消息格式
+		azMessages, err := s.convertToAzureMessages(messages)
+		if err != nil {
+			return nil, err
+		}
+
+		deployment := s.deploymentFor(messages)
+		start := time.Now()
+		resp, err := s.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
+			Messages:         azMessages,
+			DeploymentName:   deployment,
+			MaxTokens:        &s.maxTokens,
+			Temperature:      &s.temperature,
+			TopP:             &s.topP,
+			FrequencyPenalty: &s.freqPenalty,
+			PresencePenalty:  &s.presencePenalty,
+			Stop:             s.stop,
+			Tools:            s.toolsForIteration(ctx, iteration),
+		}, nil)
+		observeLLMCall("chat", *deployment, start, err)
+
+		if err != nil {
+			return nil, asContentFilterError(err)
+		}
+		result.addUsage(resp.Usage)
+
+		if len(resp.Choices) == 0 {
+			return nil, errors.New("no response generated")
+		}
+		filtered, detected := choiceFilterResults(resp.Choices[0].ContentFilterResults)
+		if isContentFiltered(resp.Choices[0].FinishReason) {
+			metrics.LLMErrors.WithLabelValues("chat", "content_filter").Inc()
+			return nil, &ContentFilterError{Stage: models.ModerationStageOutput, Categories: filtered}
+		}
+		result.FilterDetections = appendUnique(result.FilterDetections, detected...)
+		if resp.Choices[0].Message == nil {
+			return nil, errors.New("no response generated")
+		}
+
+		if reason := resp.Choices[0].FinishReason; reason != nil {
+			result.FinishReason = string(*reason)
+		}
+		message := resp.Choices[0].Message
+		content := ""
+		if message.Content != nil {
+			content = *message.Content
+		}
+
+		// 模型请求调用工具时执行工具并继续下一轮
+		if calls := toToolCalls(message.ToolCalls); len(calls) > 0 {
+			if iteration >= s.maxToolIterations {
+				return nil, ErrToolIterationsExceeded
+			}
+			messages = s.runToolCalls(ctx, messages, result, content, calls)
+			continue
+		}
+
+		observeUsage(*deployment, result)
+		s.storeCache(ctx, cacheKey, content, result)
+		result.Content = vault.Restore(content)
+		return result, nil
+	}
+}
+
+// lookupCache 查询回复缓存，命中时把缓存的回复写入 result。返回的缓存键为空表示不写入缓存。
+func (s *AIService) lookupCache(ctx context.Context, messages []ChatMessage, result *ChatResult) string {
+	control := cacheControlFrom(ctx)
+	if s.cache == nil || control.NoStore {
+		if s.cache != nil {
+			result.CacheStatus = CacheBypass
+		}
+		return ""
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", errors.New("no response generated")
+	key := s.responseCacheKey(ctx, messages, *s.deploymentFor(messages))
+	if control.NoRead {
+		result.CacheStatus = CacheBypass
+		return key
+	}
+	cached, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read response cache", slog.Any("error", err))
+	}
+	if ok {
+		result.Content = cached.Content
+		result.CacheStatus = CacheHit
+		result.CachedAt = cached.StoredAt
+		return ""
 	}
+	result.CacheStatus = CacheMiss
+	return key
+}
 
-	return *resp.Choices[0].Message.Content, nil
+// storeCache 缓存回复。调用了工具或被内容过滤标记的回复依赖当时的上下文，不写入缓存
+func (s *AIService) storeCache(ctx context.Context, key, content string, result *ChatResult) {
+	if key == "" || content == "" || len(result.Messages) > 0 || len(result.FilterDetections) > 0 {
+		return
+	}
+	if err := s.cache.Set(ctx, key, content); err != nil {
+		slog.WarnContext(ctx, "failed to write response cache", slog.Any("error", err))
+	}
 }
 
+// GenerateStreamResponse 流式生成回复，callback 收到的内容已还原占位符。
+// 模型在输出文本后转而调用工具时，这部分文本属于调用工具的 assistant 消息而不是最终回复，
+// 每轮工具调用开始前会调用 onToolRound（可以为 nil）通知调用方
 func (s *AIService) GenerateStreamResponse(
 	ctx context.Context,
 	messages []ChatMessage,
 	callback func(chunk string),
-) error {
+	onToolRound func(),
+) (*ChatResult, error) {
+	ctx, span := s.startChatSpan(ctx, "chat_stream", messages)
+	result, err := s.generateStreamResponse(ctx, messages, callback, onToolRound)
+	endChatSpan(span, result, err)
+	return result, err
+}
+
+func (s *AIService) generateStreamResponse(
+	ctx context.Context,
+	messages []ChatMessage,
+	callback func(chunk string),
+	onToolRound func(),
+) (*ChatResult, error) {
+	metrics.InflightStreams.Inc()
+	defer metrics.InflightStreams.Dec()
+
+	result := &ChatResult{}
+	vault := PIIVaultFrom(ctx)
+	messages = vault.RedactMessages(messages)
+	flush := func() {}
+	if vault != nil {
+		restorer := vault.NewStreamRestorer()
+		send := callback
+		callback = func(chunk string) {
+			if out := restorer.Write(chunk); out != "" {
+				send(out)
+			}
+		}
+		flush = func() {
+			if out := restorer.Flush(); out != "" {
+				send(out)
+			}
+		}
+		defer flush()
+	}
+
+	for iteration := 0; ; iteration++ {
+		content, calls, err := s.streamOnce(ctx, messages, s.toolsForIteration(ctx, iteration), result, callback)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(calls) > 0 {
+			if iteration >= s.maxToolIterations {
+				return nil, ErrToolIterationsExceeded
+			}
+			// 本轮的文本全部交给调用方后再开始新的一轮
+			flush()
+			if onToolRound != nil {
+				onToolRound()
+			}
+			messages = s.runToolCalls(ctx, messages, result, content, calls)
+			continue
+		}
+
+		observeUsage(*s.deploymentFor(messages), result)
+		result.Content = vault.Restore(content)
+		return result, nil
+	}
+}
+
+// streamOnce 发起一次流式请求，返回生成的文本以及模型请求的工具调用
+func (s *AIService) streamOnce(
+	ctx context.Context,
+	messages []ChatMessage,
+	tools []azopenai.ChatCompletionsToolDefinitionClassification,
+	result *ChatResult,
+	callback func(chunk string),
+) (string, []models.ToolCall, error) {
 	// 将我们的消息格式转换为 Azure SDK 的消息格式
 	azMessages, err := s.convertToAzureMessages(messages)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	// 创建流式请求
+	// 创建流式请求，要求服务商在最后一个数据块中返回 token 用量
+	deployment := s.deploymentFor(messages)
+	start := time.Now()
 	streamResp, err := s.client.GetChatCompletionsStream(
 		ctx,
 		azopenai.ChatCompletionsStreamOptions{
 			Messages:         azMessages,
-			DeploymentName:   &s.deploymentName,
+			DeploymentName:   deployment,
 			MaxTokens:        &s.maxTokens,
 			Temperature:      &s.temperature,
 			TopP:             &s.topP,
 			FrequencyPenalty: &s.freqPenalty,
 			PresencePenalty:  &s.presencePenalty,
 			Stop:             s.stop,
+			Tools:            tools,
+			StreamOptions:    &azopenai.ChatCompletionStreamOptions{IncludeUsage: toPtr(true)},
 		},
 		nil,
 	)
 	if err != nil {
-		return err
+		observeLLMCall("chat_stream", *deployment, start, err)
+		return "", nil, asContentFilterError(err)
 	}
+	defer streamResp.ChatCompletionsStream.Close()
+
+	var (
+		content    string
+		calls      []models.ToolCall
+		firstToken = true
+	)
 
 	// 处理流式响应
 	for {
@@ -148,14 +580,176 @@ func (s *AIService) GenerateStreamResponse(
 			if errors.Is(err, io.EOF) {
 				break // 流已结束，正常退出
 			}
-			return err
+			observeLLMCall("chat_stream", *deployment, start, err)
+			return "", nil, asContentFilterError(err)
 		}
+		result.addUsage(resp.Usage)
+
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		if reason := resp.Choices[0].FinishReason; reason != nil {
+			result.FinishReason = string(*reason)
+		}
+		filtered, detected := choiceFilterResults(resp.Choices[0].ContentFilterResults)
+		if isContentFiltered(resp.Choices[0].FinishReason) {
+			observeLLMCall("chat_stream", *deployment, start, nil)
+			metrics.LLMErrors.WithLabelValues("chat_stream", "content_filter").Inc()
+			return "", nil, &ContentFilterError{Stage: models.ModerationStageOutput, Categories: filtered}
+		}
+		result.FilterDetections = appendUnique(result.FilterDetections, detected...)
+		if resp.Choices[0].Delta == nil {
+			continue
+		}
+		delta := resp.Choices[0].Delta
 
 		// 检查并处理响应内容
-		if len(resp.Choices) > 0 && resp.Choices[0].Delta != nil && resp.Choices[0].Delta.Content != nil {
-			callback(*resp.Choices[0].Delta.Content)
+		if delta.Content != nil {
+			if firstToken && *delta.Content != "" {
+				metrics.LLMTimeToFirstToken.WithLabelValues(*deployment).Observe(time.Since(start).Seconds())
+				firstToken = false
+			}
+			content += *delta.Content
+			callback(*delta.Content)
+		}
+
+		// 工具调用以增量形式返回：带 ID 的片段开始一个新调用，其余片段追加参数
+		for _, tc := range delta.ToolCalls {
+			fc, ok := tc.(*azopenai.ChatCompletionsFunctionToolCall)
+			if !ok {
+				continue
+			}
+			if fc.ID != nil && *fc.ID != "" {
+				calls = append(calls, models.ToolCall{ID: *fc.ID})
+			}
+			if len(calls) == 0 || fc.Function == nil {
+				continue
+			}
+			last := &calls[len(calls)-1]
+			if fc.Function.Name != nil {
+				last.Name += *fc.Function.Name
+			}
+			if fc.Function.Arguments != nil {
+				last.Arguments += *fc.Function.Arguments
+			}
 		}
 	}
 
+	observeLLMCall("chat_stream", *deployment, start, nil)
+	return content, calls, nil
+}
+
+// Probe 以最小的请求（只生成 1 个 token）检查服务商是否可用，不经过缓存、脱敏和工具调用
+func (s *AIService) Probe(ctx context.Context) error {
+	maxTokens := int32(1)
+	start := time.Now()
+	_, err := s.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
+		Messages: []azopenai.ChatRequestMessageClassification{
+			&azopenai.ChatRequestUserMessage{Content: azopenai.NewChatRequestUserMessageContent("ping")},
+		},
+		DeploymentName: &s.deploymentName,
+		MaxTokens:      &maxTokens,
+	}, nil)
+	observeLLMCall("probe", s.deploymentName, start, err)
+	return err
+}
+
+// EmbeddingsEnabled 返回是否配置了 embeddings 部署
+func (s *AIService) EmbeddingsEnabled() bool {
+	return s.embeddingDeployment != ""
+}
+
+// CreateEmbeddings 计算文本的向量，按批次请求并按输入顺序返回结果
+func (s *AIService) CreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	result, err := s.Embed(ctx, texts, 0)
+	if err != nil {
+		return nil, err
+	}
+	return result.Vectors, nil
+}
+
+// EmbeddingResult 是一次 embeddings 请求的结果，Vectors 与输入顺序一致
+type EmbeddingResult struct {
+	Model        string
+	Vectors      [][]float32
+	PromptTokens int
+	TotalTokens  int
+}
+
+// ValidateEmbeddingInput 检查输入数量和每条文本的长度
+func (s *AIService) ValidateEmbeddingInput(texts []string) error {
+	if len(texts) == 0 {
+		return errors.New("input must not be empty")
+	}
+	if len(texts) > s.maxEmbeddingInputs {
+		return fmt.Errorf("input must contain at most %d texts", s.maxEmbeddingInputs)
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return fmt.Errorf("input %d is empty", i)
+		}
+		if utf8.RuneCountInString(text) > s.maxEmbeddingInputChars {
+			return fmt.Errorf("input %d exceeds %d characters", i, s.maxEmbeddingInputChars)
+		}
+	}
 	return nil
 }
+
+// Embed 计算文本的向量并统计 token 用量，dimensions 为零时使用模型默认维度
+func (s *AIService) Embed(ctx context.Context, texts []string, dimensions int32) (*EmbeddingResult, error) {
+	if !s.EmbeddingsEnabled() {
+		return nil, errors.New("AZURE_OPENAI_EMBEDDING_DEPLOYMENT_NAME environment variable must be set")
+	}
+
+	// 开启脱敏时，检索问题中的个人信息同样不发送给服务商
+	vault := PIIVaultFrom(ctx)
+	result := &EmbeddingResult{Model: s.embeddingDeployment, Vectors: make([][]float32, len(texts))}
+	for start := 0; start < len(texts); start += s.embeddingBatchSize {
+		end := min(start+s.embeddingBatchSize, len(texts))
+		options := azopenai.EmbeddingsOptions{
+			Input:          redactAll(vault, texts[start:end]),
+			DeploymentName: &s.embeddingDeployment,
+		}
+		if dimensions > 0 {
+			options.Dimensions = &dimensions
+		}
+		callStart := time.Now()
+		resp, err := s.client.GetEmbeddings(ctx, options, nil)
+		observeLLMCall("embeddings", s.embeddingDeployment, callStart, err)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Data {
+			if item.Index == nil || int(*item.Index) >= end-start {
+				return nil, errors.New("unexpected embedding index in response")
+			}
+			result.Vectors[start+int(*item.Index)] = item.Embedding
+		}
+		if resp.Usage != nil {
+			if resp.Usage.PromptTokens != nil {
+				result.PromptTokens += int(*resp.Usage.PromptTokens)
+			}
+			if resp.Usage.TotalTokens != nil {
+				result.TotalTokens += int(*resp.Usage.TotalTokens)
+			}
+		}
+	}
+
+	for i, vector := range result.Vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return result, nil
+}
+
+func redactAll(vault *PIIVault, texts []string) []string {
+	if vault == nil {
+		return texts
+	}
+	redacted := make([]string, len(texts))
+	for i, text := range texts {
+		redacted[i] = vault.Redact(text)
+	}
+	return redacted
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/thoulee21/go-learn/models"
+)
+
+// chatRequest 是聊天补全请求中测试关心的部分
+type chatRequest struct {
+	Messages []struct {
+		Role    string `json:"role"`
+		Content any    `json:"content"`
+	} `json:"messages"`
+	Tools  []json.RawMessage `json:"tools"`
+	Stream bool              `json:"stream"`
+}
+
+// chatReply 描述模型的一次回复，Detected 是内容过滤检测到但未拦截的类别
+type chatReply struct {
+	Content   string
+	ToolCalls []models.ToolCall
+	Detected  string
+}
+
+// chatStandIn 是一个 Azure OpenAI 聊天补全接口的替身，按 reply 生成回复并记录收到的请求
+type chatStandIn struct {
+	mu       sync.Mutex
+	requests []chatRequest
+	reply    func(request chatRequest) chatReply
+}
+
+func (c *chatStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var request chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, request)
+	c.mu.Unlock()
+
+	reply := c.reply(request)
+	message := map[string]any{"role": "assistant", "content": reply.Content}
+	finishReason := "stop"
+	var toolCalls []map[string]any
+	for _, call := range reply.ToolCalls {
+		toolCalls = append(toolCalls, map[string]any{"id": call.ID, "type": "function",
+			"function": map[string]any{"name": call.Name, "arguments": call.Arguments}})
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+		finishReason = "tool_calls"
+	}
+	choice := map[string]any{"index": 0, "finish_reason": finishReason}
+	if reply.Detected != "" {
+		choice["content_filter_results"] = map[string]any{reply.Detected: map[string]any{"filtered": false, "severity": "medium"}}
+	}
+	usage := map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+
+	if !request.Stream {
+		choice["message"] = message
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "standin", "choices": []any{choice}, "usage": usage})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	event := func(data any) {
+		encoded, _ := json.Marshal(data)
+		fmt.Fprintf(w, "data: %s\n\n", encoded)
+	}
+	delta := map[string]any{"role": "assistant", "content": reply.Content}
+	for i, call := range toolCalls {
+		call["index"] = i
+	}
+	if len(toolCalls) > 0 {
+		delta["tool_calls"] = toolCalls
+	}
+	event(map[string]any{"choices": []any{map[string]any{"index": 0, "delta": delta}}})
+	choice["delta"] = map[string]any{}
+	event(map[string]any{"choices": []any{choice}})
+	event(map[string]any{"choices": []any{}, "usage": usage})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// received 返回目前为止收到的请求
+func (c *chatStandIn) received() []chatRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]chatRequest(nil), c.requests...)
+}
+
+// newAITest 创建连接到 chatStandIn 的 AIService，注册了 get_current_time 工具，最多进行 2 轮工具调用
+func newAITest(t *testing.T, cache ResponseCache, reply func(request chatRequest) chatReply) (*AIService, *chatStandIn) {
+	t.Helper()
+	stub := &chatStandIn{reply: reply}
+	server := httptest.NewTLSServer(stub)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL)
+	t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT_NAME", "chat")
+	t.Setenv("AI_MAX_TOOL_ITERATIONS", "2")
+	registry := NewToolRegistry(time.Second)
+	if err := registry.Register(NewCurrentTimeTool()); err != nil {
+		t.Fatalf("registering tool: %v", err)
+	}
+	ai, err := NewAIService(registry, cache)
+	if err != nil {
+		t.Fatalf("NewAIService: %v", err)
+	}
+	// 信任测试服务器的自签名证书
+	ai.client, err = azopenai.NewClientWithKeyCredential(server.URL, azcore.NewKeyCredential("test-key"),
+		&azopenai.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: server.Client()}})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	return ai, stub
+}
+
+// timeToolCall 是调用 get_current_time 的工具调用
+func timeToolCall(id string) []models.ToolCall {
+	return []models.ToolCall{{ID: id, Name: "get_current_time", Arguments: `{"time_zone":"UTC"}`}}
+}
+
+func TestToolLoopStopsAtMaxIterations(t *testing.T) {
+	question := []ChatMessage{{Role: "user", Content: "what time is it?"}}
+	generators := map[string]func(ai *AIService) (*ChatResult, error){
+		"GenerateResponse": func(ai *AIService) (*ChatResult, error) {
+			return ai.GenerateResponse(context.Background(), question)
+		},
+		"GenerateStreamResponse": func(ai *AIService) (*ChatResult, error) {
+			return ai.GenerateStreamResponse(context.Background(), question, func(string) {}, nil)
+		},
+	}
+	for name, generate := range generators {
+		t.Run(name, func(t *testing.T) {
+			// 模型无论是否提供了工具都请求调用工具
+			var calls int
+			ai, stub := newAITest(t, nil, func(chatRequest) chatReply {
+				calls++
+				return chatReply{ToolCalls: timeToolCall(fmt.Sprintf("call_%d", calls))}
+			})
+
+			result, err := generate(ai)
+			if !errors.Is(err, ErrToolIterationsExceeded) {
+				t.Fatalf("got result %+v, error %v; want ErrToolIterationsExceeded", result, err)
+			}
+			requests := stub.received()
+			if len(requests) != 3 {
+				t.Fatalf("sent %d requests, want 2 tool rounds and 1 final request", len(requests))
+			}
+			for i, request := range requests {
+				if offered := len(request.Tools) > 0; offered != (i < 2) {
+					t.Errorf("request %d offered tools: %v", i, offered)
+				}
+			}
+			// 最后一次请求中的工具调用没有执行：最后一条消息是第 2 轮工具调用的结果
+			last := requests[2].Messages
+			if role := last[len(last)-1].Role; role != "tool" || len(last) != 5 {
+				t.Fatalf("final request has %d messages ending with %s, want 5 ending with a tool result", len(last), role)
+			}
+		})
+	}
+}
+
+func TestStoreCacheSkipsContextDependentReplies(t *testing.T) {
+	question := []ChatMessage{{Role: "user", Content: "what time is it?"}}
+	tests := []struct {
+		name   string
+		reply  func(request chatRequest) chatReply
+		stored bool
+	}{
+		{"plain reply", func(chatRequest) chatReply { return chatReply{Content: "Hello."} }, true},
+		{"reply after a tool call", func(request chatRequest) chatReply {
+			if request.Messages[len(request.Messages)-1].Role == "tool" {
+				return chatReply{Content: "It is noon."}
+			}
+			return chatReply{ToolCalls: timeToolCall("call_1")}
+		}, false},
+		{"reply flagged by the content filter", func(chatRequest) chatReply {
+			return chatReply{Content: "Hello.", Detected: "violence"}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ai, _ := newAITest(t, NewMemoryResponseCache(time.Hour, 10, 1<<10), tt.reply)
+			first, err := ai.GenerateResponse(context.Background(), question)
+			if err != nil {
+				t.Fatalf("first request: %v", err)
+			}
+			if first.CacheStatus != CacheMiss {
+				t.Fatalf("first request cache status = %s, want MISS", first.CacheStatus)
+			}
+			second, err := ai.GenerateResponse(context.Background(), question)
+			if err != nil {
+				t.Fatalf("second request: %v", err)
+			}
+			want := CacheMiss
+			if tt.stored {
+				want = CacheHit
+			}
+			if second.CacheStatus != want || second.Content != first.Content {
+				t.Fatalf("second request got %q (%s), want %q (%s)", second.Content, second.CacheStatus, first.Content, want)
+			}
+		})
+	}
+}
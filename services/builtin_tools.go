@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxToolResponseSize 限制 HTTP 工具返回给模型的内容长度
+const maxToolResponseSize = 8 << 10
+
+// NewCurrentTimeTool 返回查询当前时间的工具
+func NewCurrentTimeTool() Tool {
+	return Tool{
+		Name:        "get_current_time",
+		Description: "Get the current date and time, optionally in a given IANA time zone such as Asia/Shanghai.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"time_zone": {"type": "string", "description": "IANA time zone name, defaults to UTC"}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				TimeZone string `json:"time_zone"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			loc := time.UTC
+			if args.TimeZone != "" {
+				var err error
+				if loc, err = time.LoadLocation(args.TimeZone); err != nil {
+					return "", err
+				}
+			}
+			return time.Now().In(loc).Format(time.RFC3339), nil
+		},
+	}
+}
+
+// NewHTTPLookupTool 返回一个通过 GET 请求查询内部系统的工具，只提供给已登录用户。
+// urlTemplate 中的 {param} 会被替换为模型传入的参数值，例如 https://orders.internal/api/orders/{order_id}。
+// 当前用户的 ID 通过 X-User-ID 请求头发送，内部系统应只返回该用户的记录；
+// 返回的 JSON 对象带有 user_id 字段且与当前用户不符时，按记录不存在处理
+func NewHTTPLookupTool(name, description, urlTemplate, param, paramDescription, token string) Tool {
+	schema, _ := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			param: map[string]string{"type": "string", "description": paramDescription},
+		},
+		"required": []string{param},
+	})
+
+	return Tool{
+		Name:         name,
+		Description:  description,
+		Parameters:   schema,
+		RequiresUser: true,
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			userID, ok := ToolCallerFrom(ctx)
+			if !ok {
+				return "", errors.New("this tool requires a signed-in user")
+			}
+
+			var args map[string]any
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			value, ok := args[param].(string)
+			if !ok || strings.TrimSpace(value) == "" {
+				return "", fmt.Errorf("%s is required", param)
+			}
+
+			target := strings.ReplaceAll(urlTemplate, "{"+param+"}", url.PathEscape(strings.TrimSpace(value)))
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+			if err != nil {
+				return "", err
+			}
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("X-User-ID", strconv.FormatUint(uint64(userID), 10))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResponseSize))
+			if err != nil {
+				return "", err
+			}
+			if resp.StatusCode == http.StatusNotFound || (resp.StatusCode < 400 && !ownedBy(body, userID)) {
+				return "", fmt.Errorf("%s %q not found", param, value)
+			}
+			if resp.StatusCode >= 400 {
+				return "", fmt.Errorf("upstream returned status %d", resp.StatusCode)
+			}
+			return string(body), nil
+		},
+	}
+}
+
+// ownedBy 检查内部系统返回的记录是否属于用户，没有 user_id 字段的记录由内部系统负责筛选
+func ownedBy(body []byte, userID uint) bool {
+	var record map[string]any
+	if json.Unmarshal(body, &record) != nil {
+		return true
+	}
+	owner, ok := record["user_id"]
+	if !ok || owner == nil {
+		return true
+	}
+	return fmt.Sprint(owner) == strconv.FormatUint(uint64(userID), 10)
+}
+
+// RegisterBuiltinTools 注册内置工具以及通过环境变量配置的内部系统查询工具
+func RegisterBuiltinTools(registry *ToolRegistry) error {
+	if err := registry.Register(NewCurrentTimeTool()); err != nil {
+		return err
+	}
+
+	token := os.Getenv("TOOL_HTTP_TOKEN")
+	if orderURL := os.Getenv("TOOL_ORDER_STATUS_URL"); orderURL != "" {
+		tool := NewHTTPLookupTool(
+			"get_order_status",
+			"Look up the current status of a customer order by its order ID.",
+			orderURL, "order_id", "The order ID, for example SO-2024-000123", token,
+		)
+		if err := registry.Register(tool); err != nil {
+			return err
+		}
+	}
+	if ticketURL := os.Getenv("TOOL_TICKET_URL"); ticketURL != "" {
+		tool := NewHTTPLookupTool(
+			"get_ticket",
+			"Look up a support ticket, including its status and latest updates, by its ticket ID.",
+			ticketURL, "ticket_id", "The support ticket ID", token,
+		)
+		if err := registry.Register(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/thoulee21/go-learn/models"
+)
+
+// ContentFilterError 表示请求或回复被服务商的内容过滤拦截
+type ContentFilterError struct {
+	Stage      string // input, output
+	Categories []string
+}
+
+func (e *ContentFilterError) Error() string {
+	if len(e.Categories) == 0 {
+		return "content blocked by provider content filter"
+	}
+	return "content blocked by provider content filter: " + strings.Join(e.Categories, ", ")
+}
+
+// asContentFilterError 把服务商拒绝请求的错误转换为 ContentFilterError，其他错误原样返回
+func asContentFilterError(err error) error {
+	var filterErr *azopenai.ContentFilterResponseError
+	if errors.As(err, &filterErr) {
+		return &ContentFilterError{Stage: models.ModerationStageInput, Categories: filteredCategories(filterErr.ContentFilterResults)}
+	}
+
+	// 流式接口返回的是普通的 ResponseError，需要自行解析错误内容
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && respErr.ErrorCode == "content_filter" {
+		return &ContentFilterError{Stage: models.ModerationStageInput, Categories: filteredCategories(responseFilterResults(respErr))}
+	}
+	return err
+}
+
+func filteredCategories(results *azopenai.ContentFilterResults) []string {
+	if results == nil {
+		return nil
+	}
+	var categories []string
+	for name, result := range map[string]*azopenai.ContentFilterResult{
+		"hate":      results.Hate,
+		"self_harm": results.SelfHarm,
+		"sexual":    results.Sexual,
+		"violence":  results.Violence,
+	} {
+		if result != nil && result.Filtered != nil && *result.Filtered {
+			categories = append(categories, name)
+		}
+	}
+	slices.Sort(categories)
+	return categories
+}
+
+// responseFilterResults 从错误响应的 innererror.content_filter_result 中读取过滤结果
+func responseFilterResults(respErr *azcore.ResponseError) *azopenai.ContentFilterResults {
+	if respErr.RawResponse == nil {
+		return nil
+	}
+	payload, err := runtime.Payload(respErr.RawResponse)
+	if err != nil {
+		return nil
+	}
+	var body struct {
+		Error struct {
+			InnerError struct {
+				ContentFilterResults *azopenai.ContentFilterResults `json:"content_filter_result"`
+			} `json:"innererror"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(payload, &body) != nil {
+		return nil
+	}
+	return body.Error.InnerError.ContentFilterResults
+}
+
+// choiceFilterResults 返回回复中被过滤的类别，以及检测到但未过滤的类别
+func choiceFilterResults(results *azopenai.ContentFilterResultsForChoice) (filtered, detected []string) {
+	if results == nil {
+		return nil, nil
+	}
+
+	for name, result := range map[string]*azopenai.ContentFilterResult{
+		"hate":      results.Hate,
+		"self_harm": results.SelfHarm,
+		"sexual":    results.Sexual,
+		"violence":  results.Violence,
+	} {
+		switch {
+		case result == nil:
+		case result.Filtered != nil && *result.Filtered:
+			filtered = append(filtered, name)
+		case result.Severity != nil && *result.Severity != azopenai.ContentFilterSeveritySafe:
+			detected = append(detected, name)
+		}
+	}
+	for name, result := range map[string]*azopenai.ContentFilterDetectionResult{
+		"profanity":          results.Profanity,
+		"protected_material": results.ProtectedMaterialText,
+	} {
+		switch {
+		case result == nil:
+		case result.Filtered != nil && *result.Filtered:
+			filtered = append(filtered, name)
+		case result.Detected != nil && *result.Detected:
+			detected = append(detected, name)
+		}
+	}
+	slices.Sort(filtered)
+	slices.Sort(detected)
+	return filtered, detected
+}
+
+// isContentFiltered 判断回复是否因内容过滤而终止
+func isContentFiltered(reason *azopenai.CompletionsFinishReason) bool {
+	return reason != nil && *reason == azopenai.CompletionsFinishReasonContentFiltered
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
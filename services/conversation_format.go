@@ -0,0 +1,483 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+// 支持的导出/导入格式
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatJSONL    = "jsonl"
+	FormatChatGPT  = "chatgpt"
+)
+
+// maxArchiveFileSize 限制 ChatGPT 导出压缩包中 conversations.json 解压后的大小
+const maxArchiveFileSize = 64 << 20
+
+var markdownRoles = map[string]string{
+	"user":      "User",
+	"assistant": "Assistant",
+	"system":    "System",
+}
+
+// FormatContentType 返回导出格式对应的 Content-Type 和文件扩展名
+func FormatContentType(format string) (string, string, error) {
+	switch format {
+	case FormatJSON:
+		return "application/json; charset=utf-8", "json", nil
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8", "md", nil
+	case FormatJSONL:
+		return "application/jsonl; charset=utf-8", "jsonl", nil
+	default:
+		return "", "", fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ConversationTitle 使用第一条用户消息作为会话标题
+func ConversationTitle(messages []models.ChatMessage) string {
+	for _, msg := range messages {
+		if msg.Role != "user" {
+			continue
+		}
+		title := strings.Join(strings.Fields(msg.Content), " ")
+		if utf8.RuneCountInString(title) > 50 {
+			title = string([]rune(title)[:50]) + "…"
+		}
+		return title
+	}
+	return ""
+}
+
+// WriteConversations 将会话按指定格式写入 w。
+// single 为 true 时 JSON 格式输出单个对象，否则输出数组。
+func WriteConversations(w io.Writer, format string, conversations []models.Conversation, single bool) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if single && len(conversations) == 1 {
+			return enc.Encode(conversations[0])
+		}
+		return enc.Encode(conversations)
+	case FormatMarkdown:
+		return writeMarkdown(w, conversations)
+	case FormatJSONL:
+		return writeFineTuneJSONL(w, conversations)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+func writeMarkdown(w io.Writer, conversations []models.Conversation) error {
+	bw := bufio.NewWriter(w)
+	for i, conv := range conversations {
+		if i > 0 {
+			fmt.Fprint(bw, "\n---\n\n")
+		}
+		title := conv.Title
+		if title == "" {
+			title = "Conversation " + conv.SessionID
+		}
+		fmt.Fprintf(bw, "# %s\n\n", title)
+		fmt.Fprintf(bw, "> session_id: %s\n", conv.SessionID)
+		fmt.Fprintf(bw, "> created_at: %s\n", conv.CreatedAt.Format(time.RFC3339))
+
+		for _, msg := range conv.Messages {
+			role, ok := markdownRoles[msg.Role]
+			if !ok || msg.Content == "" {
+				continue
+			}
+			// 正文放在比其中任何反引号串都长的围栏中，正文里的标题和分隔线在导入时不会被当作结构
+			fence := strings.Repeat("`", max(3, longestBacktickRun(msg.Content)+1))
+			fmt.Fprintf(bw, "\n## %s\n\n%s\n%s\n%s\n", role, fence, msg.Content, fence)
+		}
+	}
+	return bw.Flush()
+}
+
+func longestBacktickRun(s string) int {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+type fineTuneMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type fineTuneExample struct {
+	Messages []fineTuneMessage `json:"messages"`
+}
+
+// writeFineTuneJSONL 输出 OpenAI 对话微调格式，每行一个会话。
+// 末尾没有助手回复的消息会被裁剪，没有助手回复的会话会被跳过。
+func writeFineTuneJSONL(w io.Writer, conversations []models.Conversation) error {
+	enc := json.NewEncoder(w)
+	for _, conv := range conversations {
+		var example fineTuneExample
+		lastAssistant := -1
+		for _, msg := range conv.Messages {
+			if _, ok := markdownRoles[msg.Role]; !ok || msg.Content == "" {
+				continue
+			}
+			example.Messages = append(example.Messages, fineTuneMessage{Role: msg.Role, Content: msg.Content})
+			if msg.Role == "assistant" {
+				lastAssistant = len(example.Messages) - 1
+			}
+		}
+		if lastAssistant < 0 {
+			continue
+		}
+		example.Messages = example.Messages[:lastAssistant+1]
+		if err := enc.Encode(example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseConversations 解析导入数据，只保留 system/user/assistant 的文本消息
+func ParseConversations(data []byte, format string) ([]models.Conversation, error) {
+	var (
+		conversations []models.Conversation
+		err           error
+	)
+	switch format {
+	case FormatJSON:
+		conversations, err = parseJSON(data)
+	case FormatMarkdown:
+		conversations = parseMarkdown(data)
+	case FormatJSONL:
+		conversations, err = parseFineTuneJSONL(data)
+	case FormatChatGPT:
+		conversations, err = parseChatGPTExport(data)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Conversation, 0, len(conversations))
+	for _, conv := range conversations {
+		messages := conv.Messages[:0]
+		for _, msg := range conv.Messages {
+			if _, ok := markdownRoles[msg.Role]; ok && strings.TrimSpace(msg.Content) != "" {
+				messages = append(messages, msg)
+			}
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		conv.Messages = messages
+		result = append(result, conv)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no conversations found")
+	}
+	return result, nil
+}
+
+func parseJSON(data []byte) ([]models.Conversation, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var conv models.Conversation
+		if err := json.Unmarshal(data, &conv); err != nil {
+			return nil, err
+		}
+		return []models.Conversation{conv}, nil
+	}
+	var conversations []models.Conversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// parseMarkdown 解析 writeMarkdown 的输出。正文不在围栏中时（手写的或旧版本导出的文件）
+// 正文到下一个标题为止，代码块中的行不会被当作标题
+func parseMarkdown(data []byte) []models.Conversation {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var (
+		conversations []models.Conversation
+		current       *models.Conversation
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "# ") {
+			conversations = append(conversations, models.Conversation{Title: strings.TrimSpace(line[2:])})
+			current = &conversations[len(conversations)-1]
+			continue
+		}
+		role, ok := markdownRole(line)
+		if !ok {
+			continue
+		}
+		if current == nil {
+			conversations = append(conversations, models.Conversation{})
+			current = &conversations[len(conversations)-1]
+		}
+
+		end, content, ok := fencedMarkdownBody(lines, i+1)
+		if !ok {
+			end, content = markdownBody(lines, i+1)
+		}
+		current.Messages = append(current.Messages, models.ChatMessage{Role: role, Content: content})
+		i = end - 1
+	}
+	return conversations
+}
+
+// markdownRole 判断一行是否为 "## User" 这样的消息标题
+func markdownRole(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, "## ")
+	if !ok {
+		return "", false
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	_, ok = markdownRoles[name]
+	return name, ok
+}
+
+// fencedMarkdownBody 读取从 start 开始、由 writeMarkdown 写入围栏中的正文，返回正文之后的行号。
+// 围栏之后紧跟的不是标题、分隔线或文件结尾时说明围栏属于正文本身，返回 false
+func fencedMarkdownBody(lines []string, start int) (int, string, bool) {
+	open := nextNonBlank(lines, start)
+	if open == len(lines) || len(lines[open]) < 3 || strings.Trim(lines[open], "`") != "" {
+		return 0, "", false
+	}
+	end := open + 1
+	for end < len(lines) && lines[end] != lines[open] {
+		end++
+	}
+	if end == len(lines) {
+		return 0, "", false
+	}
+	next := nextNonBlank(lines, end+1)
+	if next < len(lines) && lines[next] != "---" && !strings.HasPrefix(lines[next], "# ") {
+		if _, ok := markdownRole(lines[next]); !ok {
+			return 0, "", false
+		}
+	}
+	return end + 1, strings.Join(lines[open+1:end], "\n"), true
+}
+
+// markdownBody 读取从 start 开始到下一个标题为止的正文，返回正文之后的行号。
+// 后面紧跟会话标题的 --- 是会话之间的分隔线，不属于正文
+func markdownBody(lines []string, start int) (int, string) {
+	var fence string
+	end := start
+	for ; end < len(lines); end++ {
+		line := lines[end]
+		if fence != "" {
+			if closesCodeFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if _, ok := markdownRole(line); ok || strings.HasPrefix(line, "# ") {
+			break
+		}
+		if line == "---" {
+			if next := nextNonBlank(lines, end+1); next < len(lines) && strings.HasPrefix(lines[next], "# ") {
+				break
+			}
+		}
+		fence = codeFence(line)
+	}
+	return end, strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+}
+
+func nextNonBlank(lines []string, start int) int {
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	return start
+}
+
+// codeFence 返回开始代码块的围栏（至少 3 个 ` 或 ~），不是围栏时返回空字符串
+func codeFence(line string) string {
+	line = strings.TrimLeft(line, " ")
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// closesCodeFence 判断一行是否结束以 fence 开始的代码块：由同样的字符组成且不短于 fence
+func closesCodeFence(line, fence string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= len(fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+func parseFineTuneJSONL(data []byte) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var example fineTuneExample
+		if err := json.Unmarshal(line, &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		var conv models.Conversation
+		for _, msg := range example.Messages {
+			conv.Messages = append(conv.Messages, models.ChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+		conversations = append(conversations, conv)
+	}
+	return conversations, scanner.Err()
+}
+
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  *string         `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+}
+
+// parseChatGPTExport 解析 ChatGPT 导出的 conversations.json 或包含它的 zip 压缩包
+func parseChatGPTExport(data []byte) ([]models.Conversation, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		var err error
+		data, err = readConversationsFromArchive(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var exported []chatGPTConversation
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, err
+	}
+
+	conversations := make([]models.Conversation, 0, len(exported))
+	for _, item := range exported {
+		conv := models.Conversation{Title: item.Title, CreatedAt: unixFloatToTime(item.CreateTime)}
+
+		// 从当前节点沿 parent 回溯得到实际展示的分支
+		var chain []chatGPTMessage
+		visited := make(map[string]bool)
+		for id := item.CurrentNode; id != "" && !visited[id]; {
+			visited[id] = true
+			node, ok := item.Mapping[id]
+			if !ok {
+				break
+			}
+			if node.Message != nil {
+				chain = append(chain, *node.Message)
+			}
+			if node.Parent == nil {
+				break
+			}
+			id = *node.Parent
+		}
+
+		for i := len(chain) - 1; i >= 0; i-- {
+			msg := chain[i]
+			if msg.Content.ContentType != "text" {
+				continue
+			}
+			var parts []string
+			for _, raw := range msg.Content.Parts {
+				var text string
+				if json.Unmarshal(raw, &text) == nil && text != "" {
+					parts = append(parts, text)
+				}
+			}
+			chatMessage := models.ChatMessage{Role: msg.Author.Role, Content: strings.Join(parts, "\n")}
+			if msg.CreateTime != nil {
+				chatMessage.CreatedAt = unixFloatToTime(*msg.CreateTime)
+			}
+			conv.Messages = append(conv.Messages, chatMessage)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	// ChatGPT 导出按更新时间倒序排列，这里按创建时间导入
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].CreatedAt.Before(conversations[j].CreatedAt)
+	})
+	return conversations, nil
+}
+
+func readConversationsFromArchive(data []byte) ([]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range reader.File {
+		if path.Base(file.Name) != "conversations.json" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		content, err := io.ReadAll(io.LimitReader(rc, maxArchiveFileSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > maxArchiveFileSize {
+			return nil, errors.New("conversations.json is too large")
+		}
+		return content, nil
+	}
+	return nil, errors.New("conversations.json not found in archive")
+}
+
+func unixFloatToTime(ts float64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	sec := int64(ts)
+	return time.Unix(sec, int64((ts-float64(sec))*float64(time.Second)))
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+// tricky 是容易被误当作 Markdown 结构的回复
+const tricky = "# Plan\n\n## User\n\nnot a new message\n\n```go\n// ## Assistant\nfmt.Println(\"```\")\n```\n\n---"
+
+// exportedConversations 包含标题、分隔线、代码块、空消息和多个会话
+func exportedConversations() []models.Conversation {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []models.Conversation{
+		{
+			SessionID: "first",
+			Title:     "Markdown in replies",
+			CreatedAt: created,
+			Messages: []models.ChatMessage{
+				{Role: "system", Content: "You are terse."},
+				{Role: "user", Content: "Show me a plan"},
+				{Role: "assistant", Content: tricky},
+				{Role: "user", Content: ""},
+				{Role: "user", Content: "---\nand ````four```` backticks"},
+				{Role: "assistant", Content: "Done.\n---"},
+			},
+		},
+		{
+			SessionID: "second",
+			Title:     "Second",
+			CreatedAt: created.Add(time.Hour),
+			Messages: []models.ChatMessage{
+				{Role: "user", Content: "# hi"},
+				{Role: "assistant", Content: "  indented\n\ntrailing newline\n"},
+			},
+		},
+	}
+}
+
+type parsedMessage struct {
+	Role    string
+	Content string
+}
+
+// messagesOf 返回会话中的角色和内容，忽略 ID、时间等导入时重新生成的字段
+func messagesOf(conversations []models.Conversation) [][]parsedMessage {
+	var result [][]parsedMessage
+	for _, conv := range conversations {
+		var messages []parsedMessage
+		for _, msg := range conv.Messages {
+			messages = append(messages, parsedMessage{Role: msg.Role, Content: msg.Content})
+		}
+		result = append(result, messages)
+	}
+	return result
+}
+
+// withoutEmpty 去掉导入时会被跳过的空消息
+func withoutEmpty(conversations []models.Conversation) []models.Conversation {
+	var result []models.Conversation
+	for _, conv := range conversations {
+		var messages []models.ChatMessage
+		for _, msg := range conv.Messages {
+			if strings.TrimSpace(msg.Content) != "" {
+				messages = append(messages, msg)
+			}
+		}
+		conv.Messages = messages
+		result = append(result, conv)
+	}
+	return result
+}
+
+func TestConversationFormatRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		titles bool
+	}{
+		{FormatJSON, true},
+		{FormatMarkdown, true},
+		{FormatJSONL, false},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			exported := exportedConversations()
+			var buf bytes.Buffer
+			if err := WriteConversations(&buf, tt.format, exported, false); err != nil {
+				t.Fatalf("WriteConversations: %v", err)
+			}
+			imported, err := ParseConversations(buf.Bytes(), tt.format)
+			if err != nil {
+				t.Fatalf("ParseConversations: %v\n%s", err, buf.String())
+			}
+
+			want := withoutEmpty(exported)
+			if got := messagesOf(imported); !reflect.DeepEqual(got, messagesOf(want)) {
+				t.Fatalf("round trip changed the messages:\n got %q\nwant %q\nexported:\n%s", got, messagesOf(want), buf.String())
+			}
+			if tt.titles {
+				for i := range want {
+					if imported[i].Title != want[i].Title {
+						t.Errorf("conversation %d title = %q, want %q", i, imported[i].Title, want[i].Title)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseMarkdownWithoutFences(t *testing.T) {
+	// 手写的或旧版本导出的文件，正文没有放在围栏中
+	input := strings.Join([]string{
+		"# First",
+		"",
+		"> session_id: a",
+		"",
+		"## User",
+		"",
+		"How do I comment?",
+		"",
+		"## Assistant",
+		"",
+		"```python",
+		"# a comment",
+		"## User",
+		"```",
+		"",
+		"---",
+		"Below the rule.",
+		"",
+		"---",
+		"",
+		"# Second",
+		"",
+		"## User",
+		"",
+		"Hello",
+	}, "\n")
+
+	imported, err := ParseConversations([]byte(input), FormatMarkdown)
+	if err != nil {
+		t.Fatalf("ParseConversations: %v", err)
+	}
+	want := [][]parsedMessage{
+		{
+			{"user", "How do I comment?"},
+			{"assistant", "```python\n# a comment\n## User\n```\n\n---\nBelow the rule."},
+		},
+		{{"user", "Hello"}},
+	}
+	if got := messagesOf(imported); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if imported[0].Title != "First" || imported[1].Title != "Second" {
+		t.Fatalf("titles = %q, %q", imported[0].Title, imported[1].Title)
+	}
+}
+
+func TestWriteFineTuneJSONLTrimsToLastAssistantReply(t *testing.T) {
+	conversations := []models.Conversation{
+		{Messages: []models.ChatMessage{{Role: "user", Content: "no reply"}}},
+		{Messages: []models.ChatMessage{
+			{Role: "user", Content: "q"}, {Role: "tool", Content: "{}"}, {Role: "assistant", Content: "a"},
+			{Role: "user", Content: "unanswered"},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := WriteConversations(&buf, FormatJSONL, conversations, false); err != nil {
+		t.Fatalf("WriteConversations: %v", err)
+	}
+	want := `{"messages":[{"role":"user","content":"q"},{"role":"assistant","content":"a"}]}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("got %s, want %s", buf.String(), want)
+	}
+}
+
+// chatGPTExport 构造 ChatGPT 导出的 conversations.json，第一个会话有一个被编辑掉的分支
+func chatGPTExport(t *testing.T) []byte {
+	t.Helper()
+	text := func(role string, created float64, parts ...any) map[string]any {
+		return map[string]any{
+			"author":      map[string]any{"role": role},
+			"create_time": created,
+			"content":     map[string]any{"content_type": "text", "parts": parts},
+		}
+	}
+	node := func(parent string, message map[string]any) map[string]any {
+		n := map[string]any{"message": message}
+		if parent != "" {
+			n["parent"] = parent
+		}
+		return n
+	}
+	exported := []map[string]any{
+		{
+			"title": "Newer", "create_time": 1700000100.5, "current_node": "b1",
+			"mapping": map[string]any{
+				"root": node("", nil),
+				"sys":  node("root", text("system", 1700000100, "")),
+				"u1":   node("sys", text("user", 1700000101, "## User\n# not a title")),
+				"a0":   node("u1", text("assistant", 1700000102, "edited away")),
+				"b1":   node("u1", text("assistant", 1700000103, "part one", map[string]any{"image": true}, "part two")),
+			},
+		},
+		{
+			"title": "Older", "create_time": 1600000000, "current_node": "a",
+			"mapping": map[string]any{
+				"u": node("", text("user", 1600000001, "hi")),
+				"c": node("u", map[string]any{"author": map[string]any{"role": "tool"},
+					"content": map[string]any{"content_type": "code", "parts": []any{"print(1)"}}}),
+				"a": node("c", text("assistant", 1600000002, "hello")),
+			},
+		},
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("encoding export: %v", err)
+	}
+	return data
+}
+
+func TestParseChatGPTExport(t *testing.T) {
+	conversationsJSON := chatGPTExport(t)
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string][]byte{
+		"chat.html":                 []byte("<html></html>"),
+		"export/conversations.json": conversationsJSON,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("creating %s: %v", name, err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing archive: %v", err)
+	}
+
+	want := [][]parsedMessage{
+		{{"user", "hi"}, {"assistant", "hello"}},
+		{{"user", "## User\n# not a title"}, {"assistant", "part one\npart two"}},
+	}
+	for name, data := range map[string][]byte{"json": conversationsJSON, "zip": archive.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			imported, err := ParseConversations(data, FormatChatGPT)
+			if err != nil {
+				t.Fatalf("ParseConversations: %v", err)
+			}
+			if got := messagesOf(imported); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if imported[0].Title != "Older" || !imported[1].CreatedAt.Equal(time.Unix(1700000100, 5e8)) {
+				t.Fatalf("got %q created at %v first, want conversations ordered by creation time",
+					imported[0].Title, imported[1].CreatedAt)
+			}
+		})
+	}
+
+	if _, err := ParseConversations([]byte("PK\x03\x04not a zip"), FormatChatGPT); err == nil {
+		t.Error("parsing a corrupt archive succeeded")
+	}
+}
+
+func TestParseConversationsRejectsEmptyImports(t *testing.T) {
+	for format, data := range map[string]string{
+		FormatJSON:     `[{"messages":[{"role":"tool","content":"{}"},{"role":"user","content":"  "}]}]`,
+		FormatMarkdown: "# Only a title\n",
+		FormatJSONL:    "\n\n",
+	} {
+		if _, err := ParseConversations([]byte(data), format); err == nil {
+			t.Errorf("%s: importing nothing succeeded", format)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+type ConversationService struct {
+	DB *gorm.DB
+}
+
+func NewConversationService(db *gorm.DB) (*ConversationService, error) {
+	return &ConversationService{DB: db}, nil
+}
+
+// SessionOwner 返回会话所属用户，匿名会话返回 nil
+func SessionOwner(messages []models.ChatMessage) *uint {
+	for _, msg := range messages {
+		if msg.UserID != nil {
+			return msg.UserID
+		}
+	}
+	return nil
+}
+
+// CanAccessSession 判断用户是否可以访问会话：匿名会话对所有人可见，否则仅所有者可见
+func CanAccessSession(owner *uint, userID *uint) bool {
+	if owner == nil {
+		return true
+	}
+	return userID != nil && *owner == *userID
+}
+
+// ResolveSessionOwner 返回新消息应归属的用户。
+// 会话归属在第一条消息写入时确定，之后只有所有者可以继续向已归属的会话发送消息。
+func (s *ConversationService) ResolveSessionOwner(sessionID string, userID *uint) (*uint, error) {
+	var first models.ChatMessage
+	err := s.DB.Where("session_id = ?", sessionID).Order("id asc").Limit(1).Find(&first).Error
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if first.ID == 0 {
+		return userID, nil
+	}
+	if !CanAccessSession(first.UserID, userID) {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.NotAuthorized)
+	}
+	return first.UserID, nil
+}
+
+// GetConversation 获取单个会话，无权访问时按不存在处理
+func (s *ConversationService) GetConversation(sessionID string, userID *uint) (*models.Conversation, error) {
+	var messages []models.ChatMessage
+	if err := s.DB.Where("session_id = ?", sessionID).Order("created_at asc, id asc").Find(&messages).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if len(messages) == 0 || !CanAccessSession(SessionOwner(messages), userID) {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+	}
+
+	return &models.Conversation{
+		SessionID: sessionID,
+		Title:     ConversationTitle(messages),
+		CreatedAt: messages[0].CreatedAt,
+		Messages:  messages,
+	}, nil
+}
+
+// GetUserConversations 获取用户的所有会话，按会话创建时间排序
+func (s *ConversationService) GetUserConversations(userID uint) ([]models.Conversation, error) {
+	var messages []models.ChatMessage
+	err := s.DB.Where("user_id = ?", userID).Order("created_at asc, id asc").Find(&messages).Error
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+
+	index := make(map[string]int)
+	var conversations []models.Conversation
+	for _, msg := range messages {
+		i, ok := index[msg.SessionID]
+		if !ok {
+			i = len(conversations)
+			index[msg.SessionID] = i
+			conversations = append(conversations, models.Conversation{
+				SessionID: msg.SessionID,
+				CreatedAt: msg.CreatedAt,
+			})
+		}
+		conversations[i].Messages = append(conversations[i].Messages, msg)
+	}
+	for i := range conversations {
+		conversations[i].Title = ConversationTitle(conversations[i].Messages)
+	}
+	return conversations, nil
+}
+
+// ImportConversations 将会话导入为用户的新会话
+func (s *ConversationService) ImportConversations(userID uint, conversations []models.Conversation) (*models.ImportResponse, error) {
+	response := &models.ImportResponse{SessionIDs: make([]string, 0, len(conversations))}
+	now := time.Now()
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, conv := range conversations {
+			sessionID := uuid.New().String()
+			base := conv.CreatedAt
+			if base.IsZero() {
+				base = now
+			}
+
+			messages := make([]models.ChatMessage, 0, len(conv.Messages))
+			for i, msg := range conv.Messages {
+				createdAt := msg.CreatedAt
+				if createdAt.IsZero() {
+					// 保证缺少时间戳的消息仍然按原顺序排列
+					createdAt = base.Add(time.Duration(i) * time.Millisecond)
+				}
+				messages = append(messages, models.ChatMessage{
+					CreatedAt: createdAt,
+					SessionID: sessionID,
+					UserID:    &userID,
+					Role:      msg.Role,
+					Content:   msg.Content,
+				})
+			}
+			if err := tx.CreateInBatches(&messages, 100).Error; err != nil {
+				return err
+			}
+			response.SessionIDs = append(response.SessionIDs, sessionID)
+			response.Messages += len(messages)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return response, nil
+}
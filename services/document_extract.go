@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+)
+
+// 支持的文档类型
+const (
+	DocumentTypeText     = "text/plain"
+	DocumentTypeMarkdown = "text/markdown"
+	DocumentTypeHTML     = "text/html"
+	DocumentTypePDF      = "application/pdf"
+)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// DetectDocumentType 根据文件扩展名和内容判断文档类型
+func DetectDocumentType(filename string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return DocumentTypeMarkdown, nil
+	case ".html", ".htm":
+		return DocumentTypeHTML, nil
+	case ".pdf":
+		return DocumentTypePDF, nil
+	case ".txt", ".text", ".log":
+		return DocumentTypeText, nil
+	}
+
+	sniffed := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(sniffed, DocumentTypePDF):
+		return DocumentTypePDF, nil
+	case strings.HasPrefix(sniffed, DocumentTypeHTML):
+		return DocumentTypeHTML, nil
+	case strings.HasPrefix(sniffed, DocumentTypeText):
+		return DocumentTypeText, nil
+	}
+	return "", fmt.Errorf("unsupported document type: %s", sniffed)
+}
+
+// ExtractText 提取文档的纯文本内容
+func ExtractText(contentType string, data []byte) (string, error) {
+	var (
+		text string
+		err  error
+	)
+	switch contentType {
+	case DocumentTypeText, DocumentTypeMarkdown:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("document is not valid UTF-8 text")
+		}
+		text = string(data)
+	case DocumentTypeHTML:
+		text, err = extractHTML(data)
+	case DocumentTypePDF:
+		text, err = extractPDF(data)
+	default:
+		return "", fmt.Errorf("unsupported document type: %s", contentType)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "table": true,
+}
+
+func extractHTML(data []byte) (string, error) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	var (
+		sb   strings.Builder
+		skip int
+	)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return sb.String(), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" || tag == "noscript" {
+				skip++
+			}
+			if htmlBlockElements[tag] {
+				sb.WriteString("\n\n")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if (tag == "script" || tag == "style" || tag == "noscript") && skip > 0 {
+				skip--
+			}
+			if htmlBlockElements[tag] {
+				sb.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.WriteString(strings.Join(strings.Fields(string(tokenizer.Text())), " "))
+				sb.WriteString(" ")
+			}
+		}
+	}
+}
+
+func extractPDF(data []byte) (text string, err error) {
+	// pdf 库在遇到损坏的文件时可能 panic
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", p)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ChunkText 按段落将文本切分为不超过 size 个字符的片段，相邻片段重叠 overlap 个字符
+func ChunkText(text string, size, overlap int) []string {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = size / 5
+	}
+
+	// 超长段落按字符切分
+	var pieces [][]rune
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		runes := []rune(strings.TrimSpace(paragraph))
+		for len(runes) > size {
+			pieces = append(pieces, runes[:size])
+			runes = runes[size:]
+		}
+		if len(runes) > 0 {
+			pieces = append(pieces, runes)
+		}
+	}
+
+	var (
+		chunks  []string
+		current []rune
+		fresh   bool // current 中是否有上一个片段之外的新内容
+	)
+	for _, piece := range pieces {
+		if fresh && len(current)+2+len(piece) > size {
+			chunks = append(chunks, string(current))
+			// 保留末尾部分作为下一个片段的开头
+			tail := current
+			if len(tail) > overlap {
+				tail = tail[len(tail)-overlap:]
+			}
+			if len(tail)+2+len(piece) > size {
+				tail = nil
+			}
+			current = append([]rune(nil), tail...)
+			fresh = false
+		}
+		if len(current) > 0 {
+			current = append(current, '\n', '\n')
+		}
+		current = append(current, piece...)
+		fresh = true
+	}
+	if fresh {
+		chunks = append(chunks, string(current))
+	}
+	return chunks
+}
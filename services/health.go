@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHealthCheckTimeout = 2 * time.Second
+	defaultLLMProbeTTL        = 5 * time.Minute
+)
+
+// HealthService 检查服务依赖的状态，用于就绪探针
+type HealthService struct {
+	db        *gorm.DB
+	aiService *AIService
+	timeout   time.Duration
+	// llmProbe 为 false 时不检查模型服务商，避免每次探针都消耗 token
+	llmProbe    bool
+	llmProbeTTL time.Duration
+
+	mu        sync.Mutex
+	llmStatus *models.DependencyStatus
+}
+
+// NewHealthService 创建健康检查服务。
+// HEALTH_CHECK_TIMEOUT_SECONDS 是单个依赖检查的超时时间，默认 2 秒；
+// HEALTH_LLM_PROBE=true 时就绪检查包含模型服务商，结果缓存 HEALTH_LLM_PROBE_TTL_SECONDS 秒，默认 300 秒。
+func NewHealthService(db *gorm.DB, aiService *AIService) (*HealthService, error) {
+	timeout, err := durationSecondsFromEnv("HEALTH_CHECK_TIMEOUT_SECONDS", defaultHealthCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := durationSecondsFromEnv("HEALTH_LLM_PROBE_TTL_SECONDS", defaultLLMProbeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &HealthService{
+		db:          db,
+		aiService:   aiService,
+		timeout:     timeout,
+		llmProbe:    os.Getenv("HEALTH_LLM_PROBE") == "true" && aiService != nil,
+		llmProbeTTL: ttl,
+	}, nil
+}
+
+func durationSecondsFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// Readiness 并发检查所有依赖。关键依赖（数据库）不可用时整体状态为 unavailable，
+// 模型服务商不可用时为 degraded：此时其他实例通常也无法访问服务商，把实例标记为未就绪并不能恢复服务。
+func (s *HealthService) Readiness(ctx context.Context) models.HealthResponse {
+	checks := map[string]func(context.Context) models.DependencyStatus{
+		"database": s.checkDatabase,
+	}
+	if s.llmProbe {
+		checks["llm"] = s.checkLLM
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]models.DependencyStatus, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := check(ctx)
+			mu.Lock()
+			results[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	overall := models.HealthStatusOK
+	for _, status := range results {
+		if status.Status == models.HealthStatusOK {
+			continue
+		}
+		if status.Critical {
+			overall = models.HealthStatusUnavailable
+			break
+		}
+		overall = models.HealthStatusDegraded
+	}
+	return models.HealthResponse{Status: overall, Checks: results}
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) models.DependencyStatus {
+	return s.measure(ctx, "database", true, func(ctx context.Context) error {
+		sqlDB, err := s.db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// checkLLM 返回缓存的服务商检查结果，过期后重新检查。并发的请求等待同一次检查完成。
+func (s *HealthService) checkLLM(ctx context.Context) models.DependencyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.llmStatus != nil && time.Since(s.llmStatus.CheckedAt) < s.llmProbeTTL {
+		status := *s.llmStatus
+		status.Cached = true
+		return status
+	}
+	status := s.measure(ctx, "llm", false, s.aiService.Probe)
+	s.llmStatus = &status
+	return status
+}
+
+// measure 执行一次依赖检查。/readyz 不需要认证，响应中只给出固定的错误描述，
+// 具体错误可能包含数据库地址或服务商的响应内容，只写入日志
+func (s *HealthService) measure(ctx context.Context, name string, critical bool, check func(context.Context) error) models.DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := models.DependencyStatus{
+		Status:    models.HealthStatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+		Critical:  critical,
+	}
+	if err != nil {
+		status.Status = models.HealthStatusUnavailable
+		status.Error = models.HealthErrorCheckFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			status.Error = models.HealthErrorTimeout
+		}
+		slog.WarnContext(ctx, "dependency check failed", slog.String("dependency", name), slog.Any("error", err))
+	}
+	return status
+}
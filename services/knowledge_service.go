@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 150
+	defaultTopK         = 4
+	maxExcerptLength    = 200
+)
+
+type KnowledgeService struct {
+	DB           *gorm.DB
+	AIService    *AIService
+	Store        VectorStore
+	chunkSize    int
+	chunkOverlap int
+	topK         int
+	minScore     float32
+}
+
+func NewKnowledgeService(db *gorm.DB, aiService *AIService, store VectorStore) (*KnowledgeService, error) {
+	s := &KnowledgeService{
+		DB:           db,
+		AIService:    aiService,
+		Store:        store,
+		chunkSize:    defaultChunkSize,
+		chunkOverlap: defaultChunkOverlap,
+		topK:         defaultTopK,
+	}
+
+	for name, target := range map[string]*int{
+		"KB_CHUNK_SIZE":    &s.chunkSize,
+		"KB_CHUNK_OVERLAP": &s.chunkOverlap,
+		"KB_TOP_K":         &s.topK,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*target = n
+		}
+	}
+	if s.chunkSize == 0 || s.topK == 0 {
+		return nil, errors.New("KB_CHUNK_SIZE and KB_TOP_K must be positive")
+	}
+
+	if v := os.Getenv("KB_MIN_SCORE"); v != "" {
+		score, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return nil, errors.New("KB_MIN_SCORE must be a number")
+		}
+		s.minScore = float32(score)
+	}
+	return s, nil
+}
+
+// Upload 提取文档文本、切分并计算向量后写入知识库
+func (s *KnowledgeService) Upload(ctx context.Context, userID *uint, filename string, data []byte) (*models.KnowledgeDocument, error) {
+	if !s.AIService.EmbeddingsEnabled() {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "knowledge_not_configured")
+	}
+	contentType, err := DetectDocumentType(filename, data)
+	if err != nil {
+		return nil, domainErrors.NewAppError(err, domainErrors.ValidationError)
+	}
+	text, err := ExtractText(contentType, data)
+	if err != nil {
+		return nil, domainErrors.NewAppError(err, domainErrors.ValidationError)
+	}
+	chunks := ChunkText(text, s.chunkSize, s.chunkOverlap)
+	if len(chunks) == 0 {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "document_empty")
+	}
+
+	vectors, err := s.AIService.CreateEmbeddings(ctx, chunks)
+	if err != nil {
+		return nil, domainErrors.NewAppError(err, domainErrors.UpstreamError)
+	}
+
+	document := &models.KnowledgeDocument{
+		Name:        filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		ChunkCount:  len(chunks),
+		UserID:      userID,
+	}
+	records := make([]models.KnowledgeChunk, 0, len(chunks))
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+		for i, chunk := range chunks {
+			records = append(records, models.KnowledgeChunk{
+				DocumentID: document.ID,
+				Position:   i,
+				Content:    chunk,
+				Embedding:  vectors[i],
+			})
+		}
+		return tx.CreateInBatches(&records, 100).Error
+	})
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+
+	vectorRecords := make([]VectorRecord, 0, len(records))
+	for _, record := range records {
+		vectorRecords = append(vectorRecords, VectorRecord{ChunkID: record.ID, DocumentID: document.ID, Vector: record.Embedding})
+	}
+	if err := s.Store.Index(ctx, vectorRecords); err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return document, nil
+}
+
+// List 返回知识库中的全部文档
+func (s *KnowledgeService) List() ([]models.KnowledgeDocument, error) {
+	var documents []models.KnowledgeDocument
+	if err := s.DB.Order("created_at desc").Find(&documents).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return documents, nil
+}
+
+// Delete 删除文档及其全部片段
+func (s *KnowledgeService) Delete(ctx context.Context, id uint) error {
+	var rowsAffected int64
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", id).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.KnowledgeDocument{}, id)
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	if rowsAffected == 0 {
+		return domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+	}
+	return s.Store.DeleteDocument(ctx, id)
+}
+
+// Search 检索与查询最相关的片段，topK 为零时使用默认值
+func (s *KnowledgeService) Search(ctx context.Context, query string, topK int) ([]models.Citation, []models.KnowledgeChunk, error) {
+	if !s.AIService.EmbeddingsEnabled() {
+		return nil, nil, domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "knowledge_not_configured")
+	}
+	if topK <= 0 {
+		topK = s.topK
+	}
+
+	vectors, err := s.AIService.CreateEmbeddings(ctx, []string{query})
+	if err != nil {
+		return nil, nil, domainErrors.NewAppError(err, domainErrors.UpstreamError)
+	}
+	matches, err := s.Store.Search(ctx, vectors[0], topK)
+	if err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+
+	chunkIDs := make([]uint, 0, len(matches))
+	for _, match := range matches {
+		if match.Score >= s.minScore {
+			chunkIDs = append(chunkIDs, match.ChunkID)
+		}
+	}
+	if len(chunkIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	var chunks []models.KnowledgeChunk
+	if err := s.DB.Where("id IN ?", chunkIDs).Find(&chunks).Error; err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	var documents []models.KnowledgeDocument
+	documentIDs := make([]uint, 0, len(chunks))
+	for _, chunk := range chunks {
+		documentIDs = append(documentIDs, chunk.DocumentID)
+	}
+	if err := s.DB.Where("id IN ?", documentIDs).Find(&documents).Error; err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+
+	chunkByID := make(map[uint]models.KnowledgeChunk, len(chunks))
+	for _, chunk := range chunks {
+		chunkByID[chunk.ID] = chunk
+	}
+	documentNames := make(map[uint]string, len(documents))
+	for _, document := range documents {
+		documentNames[document.ID] = document.Name
+	}
+
+	// 按相似度顺序返回，跳过检索期间被删除的片段
+	var (
+		citations []models.Citation
+		ordered   []models.KnowledgeChunk
+	)
+	for _, match := range matches {
+		chunk, ok := chunkByID[match.ChunkID]
+		if !ok || match.Score < s.minScore {
+			continue
+		}
+		ordered = append(ordered, chunk)
+		citations = append(citations, models.Citation{
+			Index:        len(citations) + 1,
+			DocumentID:   chunk.DocumentID,
+			DocumentName: documentNames[chunk.DocumentID],
+			ChunkID:      chunk.ID,
+			Score:        match.Score,
+			Excerpt:      excerpt(chunk.Content),
+		})
+	}
+	return citations, ordered, nil
+}
+
+// Retrieve 检索相关片段并构造注入到对话开头的系统消息
+func (s *KnowledgeService) Retrieve(ctx context.Context, query string, topK int) ([]models.Citation, *ChatMessage, error) {
+	citations, chunks, err := s.Search(ctx, query, topK)
+	if err != nil || len(citations) == 0 {
+		return nil, nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Answer the user's question using the knowledge base excerpts below. ")
+	sb.WriteString("Cite the excerpts you use with their number in square brackets, for example [1]. ")
+	sb.WriteString("If the excerpts do not contain the answer, say that you don't know instead of guessing.\n")
+	for i, chunk := range chunks {
+		fmt.Fprintf(&sb, "\n[%d] %s\n%s\n", citations[i].Index, citations[i].DocumentName, chunk.Content)
+	}
+	return citations, &ChatMessage{Role: "system", Content: sb.String()}, nil
+}
+
+func excerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= maxExcerptLength {
+		return content
+	}
+	return string([]rune(content)[:maxExcerptLength]) + "…"
+}
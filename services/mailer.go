@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail 是一封纯文本邮件
+type Mail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer 负责投递邮件，可替换为不同的实现
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// NewMailer 根据 MAIL_BACKEND 创建邮件投递方式：log（默认，只写日志，设置 MAIL_LOG_FILE 时同时写入文件）或 smtp
+func NewMailer() (Mailer, error) {
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		return NewLogMailer(slog.Default(), os.Getenv("MAIL_LOG_FILE")), nil
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported MAIL_BACKEND: %s", backend)
+	}
+}
+
+// LogMailer 不发送邮件，而是把邮件写入日志；path 不为空时还会以 JSON Lines 追加到文件，便于开发和测试时读取链接
+type LogMailer struct {
+	logger *slog.Logger
+	path   string
+	mu     sync.Mutex
+}
+
+func NewLogMailer(logger *slog.Logger, path string) *LogMailer {
+	return &LogMailer{logger: logger, path: path}
+}
+
+func (m *LogMailer) Send(ctx context.Context, mail Mail) error {
+	m.logger.InfoContext(ctx, "mail", slog.String("to", mail.To), slog.String("subject", mail.Subject))
+	if m.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(mail)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// From 是发件人地址，可以包含名称，例如 "AI Chatbot <noreply@example.com>"
+	From string
+}
+
+// SMTPMailer 通过 SMTP 发送邮件。465 端口使用隐式 TLS，其他端口在服务器支持时使用 STARTTLS
+type SMTPMailer struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP_HOST is required")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("MAIL_FROM is invalid: %w", err)
+	}
+	return &SMTPMailer{config: config, from: from}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Mail) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	data, err := m.message(to, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.config.Host, m.config.Port)
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+	var conn net.Conn
+	if m.config.Port == "465" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if m.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message 生成 UTF-8 纯文本邮件，正文使用 quoted-printable 编码
+func (m *SMTPMailer) message(to *mail.Address, msg Mail) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := m.from.Address[strings.LastIndex(m.from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,556 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+// ModerationFinding 是审核规则在文本中的一次命中，Start 和 End 为字节偏移
+type ModerationFinding struct {
+	Checker  string
+	Category string
+	Start    int
+	End      int
+}
+
+// ModerationChecker 检查文本并返回命中的内容
+type ModerationChecker interface {
+	Name() string
+	Check(ctx context.Context, text string) []ModerationFinding
+}
+
+// KeywordChecker 按关键词和正则表达式检查文本
+type KeywordChecker struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// NewKeywordChecker 创建关键词检查器，关键词不区分大小写，英文关键词按整词匹配
+func NewKeywordChecker(category string, keywords, patterns []string) (*KeywordChecker, error) {
+	alternatives := make([]string, 0, len(keywords)+len(patterns))
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		quoted := regexp.QuoteMeta(keyword)
+		if isASCIIWord(keyword) {
+			quoted = `\b` + quoted + `\b`
+		}
+		alternatives = append(alternatives, quoted)
+	}
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern %q: %w", pattern, err)
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	if len(alternatives) == 0 {
+		return nil, fmt.Errorf("no keywords or patterns for category %s", category)
+	}
+
+	pattern, err := regexp.Compile("(?i)" + strings.Join(alternatives, "|"))
+	if err != nil {
+		return nil, err
+	}
+	return &KeywordChecker{category: category, pattern: pattern}, nil
+}
+
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func (k *KeywordChecker) Name() string {
+	return "keyword"
+}
+
+func (k *KeywordChecker) Check(_ context.Context, text string) []ModerationFinding {
+	var findings []ModerationFinding
+	for _, loc := range k.pattern.FindAllStringIndex(text, -1) {
+		findings = append(findings, ModerationFinding{Checker: k.Name(), Category: k.category, Start: loc[0], End: loc[1]})
+	}
+	return findings
+}
+
+// PIIChecker 识别邮箱、电话、身份证号、银行卡号等个人信息
+type PIIChecker struct {
+	patterns []PIIPattern
+}
+
+func NewPIIChecker(patterns []PIIPattern) *PIIChecker {
+	return &PIIChecker{patterns: patterns}
+}
+
+func (p *PIIChecker) Name() string {
+	return "pii"
+}
+
+func (p *PIIChecker) Check(_ context.Context, text string) []ModerationFinding {
+	var findings []ModerationFinding
+	for _, match := range FindPII(text, p.patterns) {
+		findings = append(findings, ModerationFinding{Checker: p.Name(), Category: match.Category, Start: match.Start, End: match.End})
+	}
+	return findings
+}
+
+// ModerationConfig 是 MODERATION_CONFIG 指向的 JSON 配置文件的内容
+type ModerationConfig struct {
+	// DefaultAction 是未单独配置的类别使用的动作，默认为 flag
+	DefaultAction string `json:"default_action"`
+	// Actions 按类别配置动作，类别可以写完整名称（pii.email）或前缀（pii）
+	Actions map[string]string `json:"actions"`
+	// Keywords 和 Patterns 按类别配置关键词和正则表达式
+	Keywords map[string][]string `json:"keywords"`
+	Patterns map[string][]string `json:"patterns"`
+	// PII 为 true 时启用个人信息识别
+	PII bool `json:"pii"`
+	// Stages 限制检查的阶段，默认同时检查输入和输出
+	Stages []string `json:"stages"`
+}
+
+// ModerationResult 是一段文本的审核结果
+type ModerationResult struct {
+	// Text 是执行 redact 动作之后的文本
+	Text     string
+	Action   string
+	Findings []ModerationFlagFinding
+}
+
+// ModerationFlagFinding 是带有动作和命中内容的审核结果
+type ModerationFlagFinding struct {
+	ModerationFinding
+	Action  string
+	Excerpt string
+}
+
+// Blocked 判断内容是否应被拦截
+func (r *ModerationResult) Blocked() bool {
+	return r.Action == models.ModerationActionBlock
+}
+
+var actionSeverity = map[string]int{
+	models.ModerationActionAllow:  0,
+	models.ModerationActionFlag:   1,
+	models.ModerationActionRedact: 2,
+	models.ModerationActionBlock:  3,
+}
+
+type ModerationService struct {
+	DB            *gorm.DB
+	checkers      []ModerationChecker
+	actions       map[string]string
+	defaultAction string
+	stages        []string
+}
+
+// NewModerationService 从 MODERATION_CONFIG 指定的文件加载审核规则。
+// 未配置时只记录服务商内容过滤检测到的类别。
+func NewModerationService(db *gorm.DB) (*ModerationService, error) {
+	config := ModerationConfig{}
+	if path := os.Getenv("MODERATION_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("invalid MODERATION_CONFIG: %w", err)
+		}
+	}
+	return NewModerationServiceWithConfig(db, config)
+}
+
+func NewModerationServiceWithConfig(db *gorm.DB, config ModerationConfig) (*ModerationService, error) {
+	s := &ModerationService{
+		DB:            db,
+		actions:       make(map[string]string),
+		defaultAction: models.ModerationActionFlag,
+		stages:        []string{models.ModerationStageInput, models.ModerationStageOutput},
+	}
+	if config.DefaultAction != "" {
+		if _, ok := actionSeverity[config.DefaultAction]; !ok {
+			return nil, fmt.Errorf("unknown moderation action: %s", config.DefaultAction)
+		}
+		s.defaultAction = config.DefaultAction
+	}
+	for category, action := range config.Actions {
+		if _, ok := actionSeverity[action]; !ok {
+			return nil, fmt.Errorf("unknown moderation action for %s: %s", category, action)
+		}
+		s.actions[category] = action
+	}
+	if len(config.Stages) > 0 {
+		s.stages = config.Stages
+	}
+
+	categories := make(map[string]bool)
+	for category := range config.Keywords {
+		categories[category] = true
+	}
+	for category := range config.Patterns {
+		categories[category] = true
+	}
+	for category := range categories {
+		checker, err := NewKeywordChecker(category, config.Keywords[category], config.Patterns[category])
+		if err != nil {
+			return nil, err
+		}
+		s.checkers = append(s.checkers, checker)
+	}
+	if config.PII {
+		s.checkers = append(s.checkers, NewPIIChecker(DefaultPIIPatterns))
+	}
+	return s, nil
+}
+
+// AddChecker 注册额外的检查器
+func (s *ModerationService) AddChecker(checker ModerationChecker) {
+	s.checkers = append(s.checkers, checker)
+}
+
+// ActionFor 返回类别对应的动作：先匹配完整类别，再匹配前缀，最后使用默认动作
+func (s *ModerationService) ActionFor(category string) string {
+	if action, ok := s.actions[category]; ok {
+		return action
+	}
+	if prefix, _, found := strings.Cut(category, "."); found {
+		if action, ok := s.actions[prefix]; ok {
+			return action
+		}
+	}
+	return s.defaultAction
+}
+
+func (s *ModerationService) checksStage(stage string) bool {
+	return slices.Contains(s.stages, stage)
+}
+
+// Check 使用全部检查器审核文本
+func (s *ModerationService) Check(ctx context.Context, stage, text string) *ModerationResult {
+	var findings []ModerationFinding
+	if s.checksStage(stage) {
+		for _, checker := range s.checkers {
+			findings = append(findings, checker.Check(ctx, text)...)
+		}
+	}
+	return s.evaluate(text, findings, 0, len(text))
+}
+
+// evaluate 根据命中的内容计算动作，并对 [from, to) 范围内的文本执行 redact
+func (s *ModerationService) evaluate(text string, findings []ModerationFinding, from, to int) *ModerationResult {
+	result := &ModerationResult{Action: models.ModerationActionAllow}
+	slices.SortFunc(findings, func(a, b ModerationFinding) int { return a.Start - b.Start })
+
+	var sb strings.Builder
+	pos := from
+	for _, finding := range findings {
+		action := s.ActionFor(finding.Category)
+		if action == models.ModerationActionAllow {
+			continue
+		}
+		result.Findings = append(result.Findings, ModerationFlagFinding{
+			ModerationFinding: finding,
+			Action:            action,
+			Excerpt:           maskExcerpt(text[finding.Start:finding.End]),
+		})
+		if actionSeverity[action] > actionSeverity[result.Action] {
+			result.Action = action
+		}
+
+		// 重叠的命中只替换一次
+		if action == models.ModerationActionRedact && finding.Start >= pos && finding.End <= to {
+			sb.WriteString(text[pos:finding.Start])
+			sb.WriteString("[REDACTED:" + finding.Category + "]")
+			pos = finding.End
+		}
+	}
+	sb.WriteString(text[pos:to])
+	result.Text = sb.String()
+	return result
+}
+
+// ProviderResult 把服务商内容过滤的结果转换为审核结果，被拦截的类别始终按 block 处理
+func (s *ModerationService) ProviderResult(text string, blocked *ContentFilterError, detected []string) *ModerationResult {
+	result := &ModerationResult{Text: text, Action: models.ModerationActionAllow}
+	add := func(category, action string) {
+		if action == models.ModerationActionAllow {
+			return
+		}
+		result.Findings = append(result.Findings, ModerationFlagFinding{
+			ModerationFinding: ModerationFinding{Checker: "provider", Category: "provider." + category},
+			Action:            action,
+		})
+		if actionSeverity[action] > actionSeverity[result.Action] {
+			result.Action = action
+		}
+	}
+
+	if blocked != nil {
+		if len(blocked.Categories) == 0 {
+			add("content_filter", models.ModerationActionBlock)
+		}
+		for _, category := range blocked.Categories {
+			add(category, models.ModerationActionBlock)
+		}
+	}
+	for _, category := range detected {
+		// 服务商检测结果无法定位到具体文本，redact 按 flag 处理
+		action := s.ActionFor("provider." + category)
+		if action == models.ModerationActionRedact {
+			action = models.ModerationActionFlag
+		}
+		add(category, action)
+	}
+	return result
+}
+
+// Merge 合并多个审核结果，文本取最后一个结果的文本
+func (s *ModerationService) Merge(results ...*ModerationResult) *ModerationResult {
+	merged := &ModerationResult{Action: models.ModerationActionAllow}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		merged.Text = result.Text
+		merged.Findings = append(merged.Findings, result.Findings...)
+		if actionSeverity[result.Action] > actionSeverity[merged.Action] {
+			merged.Action = result.Action
+		}
+	}
+	return merged
+}
+
+// Record 保存审核结果中需要复核的记录，保存失败只记录日志，不影响对话
+func (s *ModerationService) Record(ctx context.Context, stage, sessionID string, userID, messageID *uint, result *ModerationResult) {
+	if result == nil || len(result.Findings) == 0 {
+		return
+	}
+
+	flags := make([]models.ModerationFlag, 0, len(result.Findings))
+	for _, finding := range result.Findings {
+		flags = append(flags, models.ModerationFlag{
+			SessionID: sessionID,
+			UserID:    userID,
+			MessageID: messageID,
+			Stage:     stage,
+			Checker:   finding.Checker,
+			Category:  finding.Category,
+			Action:    finding.Action,
+			Excerpt:   finding.Excerpt,
+		})
+	}
+	if err := s.DB.Create(&flags).Error; err != nil {
+		slog.WarnContext(ctx, "failed to save moderation flags", slog.Any("error", err))
+	}
+}
+
+// BlockedError 返回内容被拦截时的错误
+func BlockedError(result *ModerationResult) error {
+	categories := make([]string, 0, len(result.Findings))
+	for _, finding := range result.Findings {
+		if finding.Action == models.ModerationActionBlock {
+			categories = appendUnique(categories, finding.Category)
+		}
+	}
+	return domainErrors.NewAppErrorWithMessage(domainErrors.ContentFiltered, "content_blocked_categories", strings.Join(categories, ", "))
+}
+
+// ListFlags 按条件查询审核记录，返回当前页和总数
+func (s *ModerationService) ListFlags(query models.ModerationFlagQuery) ([]models.ModerationFlag, int64, error) {
+	db := s.DB.Model(&models.ModerationFlag{})
+	if query.SessionID != "" {
+		db = db.Where("session_id = ?", query.SessionID)
+	}
+	if query.UserID != 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Stage != "" {
+		db = db.Where("stage = ?", query.Stage)
+	}
+	if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.Reviewed != nil {
+		if *query.Reviewed {
+			db = db.Where("reviewed_at IS NOT NULL")
+		} else {
+			db = db.Where("reviewed_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, domainErrors.TranslateDBError(err)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	var flags []models.ModerationFlag
+	if err := db.Order("created_at desc, id desc").Limit(limit).Offset(query.Offset).Find(&flags).Error; err != nil {
+		return nil, 0, domainErrors.TranslateDBError(err)
+	}
+	return flags, total, nil
+}
+
+// ReviewFlag 把审核记录标记为已复核
+func (s *ModerationService) ReviewFlag(id, reviewerID uint) (*models.ModerationFlag, error) {
+	var flag models.ModerationFlag
+	if err := s.DB.First(&flag, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+
+	now := time.Now()
+	flag.ReviewedAt = &now
+	flag.ReviewedBy = &reviewerID
+	if err := s.DB.Model(&flag).Updates(map[string]any{"reviewed_at": now, "reviewed_by": reviewerID}).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &flag, nil
+}
+
+// maskExcerpt 只保留命中内容的首尾字符，避免在审核记录中保存完整的敏感信息
+func maskExcerpt(s string) string {
+	runes := []rune(s)
+	if len(runes) > 64 {
+		runes = runes[:64]
+	}
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// streamHoldback 是流式审核时暂不输出的字节数，保证跨数据块的关键词也能被识别
+const streamHoldback = 256
+
+// StreamModerator 审核流式输出，命中规则的内容在发送给用户之前被替换或拦截
+type StreamModerator struct {
+	service *ModerationService
+	stage   string
+	pending string
+	result  *ModerationResult
+	blocked bool
+}
+
+// NewStreamModerator 创建流式审核器
+func (s *ModerationService) NewStreamModerator(stage string) *StreamModerator {
+	return &StreamModerator{
+		service: s,
+		stage:   stage,
+		result:  &ModerationResult{Action: models.ModerationActionAllow},
+	}
+}
+
+// Write 追加一个数据块，返回可以发送给用户的内容；blocked 为 true 时应停止输出
+func (m *StreamModerator) Write(ctx context.Context, chunk string) (out string, blocked bool) {
+	if m.blocked {
+		return "", true
+	}
+	m.pending += chunk
+	return m.emit(ctx, false)
+}
+
+// Flush 在流结束时返回剩余的内容
+func (m *StreamModerator) Flush(ctx context.Context) (out string, blocked bool) {
+	if m.blocked {
+		return "", true
+	}
+	return m.emit(ctx, true)
+}
+
+// EndRound 在模型转而调用工具时输出本轮剩余的内容，之后 Result 的 Text 只包含下一轮的内容，
+// 审核结论仍然累计
+func (m *StreamModerator) EndRound(ctx context.Context) (out string, blocked bool) {
+	out, blocked = m.Flush(ctx)
+	if !blocked {
+		m.result.Text = ""
+	}
+	return out, blocked
+}
+
+// Result 返回到目前为止的审核结果，Text 为本轮已发送给用户的内容
+func (m *StreamModerator) Result() *ModerationResult {
+	return m.result
+}
+
+func (m *StreamModerator) emit(ctx context.Context, final bool) (string, bool) {
+	// 没有需要执行的检查器时直接输出
+	if !m.service.checksStage(m.stage) || len(m.service.checkers) == 0 {
+		out := m.pending
+		m.pending = ""
+		m.result.Text += out
+		return out, false
+	}
+
+	cut := len(m.pending)
+	if !final {
+		cut -= streamHoldback
+	}
+	if cut <= 0 {
+		return "", false
+	}
+
+	var findings []ModerationFinding
+	for _, checker := range m.service.checkers {
+		findings = append(findings, checker.Check(ctx, m.pending)...)
+	}
+
+	// 不在多字节字符或命中内容的中间截断
+	for cut > 0 && cut < len(m.pending) && !utf8.RuneStart(m.pending[cut]) {
+		cut--
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, finding := range findings {
+			if finding.Start < cut && finding.End > cut {
+				cut = finding.Start
+				moved = true
+			}
+		}
+	}
+	if cut == 0 && !final {
+		return "", false
+	}
+
+	ready := findings[:0]
+	for _, finding := range findings {
+		if finding.End <= cut {
+			ready = append(ready, finding)
+		}
+	}
+	result := m.service.evaluate(m.pending, ready, 0, cut)
+	sent := m.result.Text
+	m.result = m.service.Merge(m.result, result)
+	if result.Blocked() {
+		m.result.Text = sent
+		m.blocked = true
+		return "", true
+	}
+	m.result.Text = sent + result.Text
+	m.pending = m.pending[cut:]
+	return result.Text, false
+}
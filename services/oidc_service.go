@@ -0,0 +1,444 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// OIDCLoginCookie 保存登录过程中的 state、nonce 和 PKCE verifier，回调时校验
+	OIDCLoginCookie = "oidc_login"
+	// OIDCLoginTTL 是从开始登录到完成回调的最长时间
+	OIDCLoginTTL = 10 * time.Minute
+
+	oidcLoginPurpose       = "oidc_login"
+	defaultOIDCScopes      = "openid email profile"
+	defaultOIDCRedirectURL = "http://localhost:8080"
+	oidcHTTPTimeout        = 10 * time.Second
+)
+
+var (
+	oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	// oidcUserNameInvalid 匹配用户名中不允许的字符，与注册时的 username 规则一致
+	oidcUserNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// OIDCService 通过 OpenID Connect 授权码流程（PKCE）登录。身份提供方在首次使用时通过 discovery 获取配置，
+// 签名公钥（JWKS）由 go-oidc 缓存，遇到未知的 kid 时重新获取。
+// 外部身份通过 UserIdentity 关联到用户，首次登录时按已验证的邮箱关联已有用户或自动创建用户
+type OIDCService struct {
+	DB            *gorm.DB
+	auth          *AuthService
+	providers     map[string]*oidcProvider
+	names         []string
+	redirectBase  string
+	postLoginURL  string
+	autoProvision bool
+	secureCookie  bool
+	httpClient    *http.Client
+}
+
+type oidcProvider struct {
+	name        string
+	displayName string
+	issuer      string
+	oauth2      oauth2.Config
+
+	mu       sync.Mutex
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcLoginClaims 是登录 cookie 的内容
+type oidcLoginClaims struct {
+	jwt.RegisteredClaims
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// oidcUserClaims 是从 ID 令牌中读取的用户信息
+type oidcUserClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// NewOIDCService 读取 OIDC_PROVIDERS 中以逗号分隔的身份提供方名称，以及每个提供方的
+// OIDC_<名称>_ISSUER、OIDC_<名称>_CLIENT_ID、OIDC_<名称>_CLIENT_SECRET、OIDC_<名称>_SCOPES 和 OIDC_<名称>_DISPLAY_NAME。
+// 回调地址为 OIDC_REDIRECT_BASE_URL/auth/oidc/<名称>/callback，需要在身份提供方处登记
+func NewOIDCService(db *gorm.DB, authService *AuthService) (*OIDCService, error) {
+	redirectBase := strings.TrimRight(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+	if redirectBase == "" {
+		redirectBase = defaultOIDCRedirectURL
+	}
+	if _, err := url.ParseRequestURI(redirectBase); err != nil {
+		return nil, errors.New("OIDC_REDIRECT_BASE_URL must be an absolute URL")
+	}
+	autoProvision := true
+	if v := os.Getenv("OIDC_AUTO_PROVISION"); v != "" {
+		autoProvision = v == "true"
+	}
+
+	s := &OIDCService{
+		DB:            db,
+		auth:          authService,
+		providers:     map[string]*oidcProvider{},
+		redirectBase:  redirectBase,
+		postLoginURL:  os.Getenv("OIDC_POST_LOGIN_URL"),
+		autoProvision: autoProvision,
+		secureCookie:  strings.HasPrefix(redirectBase, "https://"),
+		httpClient:    &http.Client{Timeout: oidcHTTPTimeout},
+	}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !oidcProviderNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("OIDC provider name %q may only contain lowercase letters, digits, '_' and '-'", name)
+		}
+		if _, ok := s.providers[name]; ok {
+			return nil, fmt.Errorf("OIDC provider %q is configured twice", name)
+		}
+		provider, err := s.newProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		s.providers[name] = provider
+		s.names = append(s.names, name)
+	}
+	return s, nil
+}
+
+func (s *OIDCService) newProvider(name string) (*oidcProvider, error) {
+	env := func(key string) string {
+		return os.Getenv("OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + key)
+	}
+	issuer := env("ISSUER")
+	clientID := env("CLIENT_ID")
+	if issuer == "" || clientID == "" {
+		return nil, fmt.Errorf("OIDC provider %q requires an issuer and a client ID", name)
+	}
+	scopes := env("SCOPES")
+	if scopes == "" {
+		scopes = defaultOIDCScopes
+	}
+	displayName := env("DISPLAY_NAME")
+	if displayName == "" {
+		displayName = name
+	}
+	return &oidcProvider{
+		name:        name,
+		displayName: displayName,
+		issuer:      issuer,
+		oauth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  s.redirectBase + "/auth/oidc/" + name + "/callback",
+			Scopes:       strings.Fields(scopes),
+		},
+	}, nil
+}
+
+// PostLoginURL 是登录成功后浏览器跳转的前端地址，为空时回调直接返回登录结果
+func (s *OIDCService) PostLoginURL() string {
+	return s.postLoginURL
+}
+
+// SecureCookie 判断登录 cookie 是否只能通过 HTTPS 发送
+func (s *OIDCService) SecureCookie() bool {
+	return s.secureCookie
+}
+
+// Providers 返回已配置的身份提供方
+func (s *OIDCService) Providers() []models.OIDCProvider {
+	providers := make([]models.OIDCProvider, 0, len(s.names))
+	for _, name := range s.names {
+		providers = append(providers, models.OIDCProvider{
+			Name:        name,
+			DisplayName: s.providers[name].displayName,
+			LoginURL:    "/auth/oidc/" + name + "/login",
+		})
+	}
+	return providers
+}
+
+// BeginLogin 生成身份提供方的授权地址，以及需要在回调时提交的签名 cookie
+func (s *OIDCService) BeginLogin(ctx context.Context, name string) (authURL, cookie string, err error) {
+	p, err := s.provider(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := time.Now()
+	claims := oidcLoginClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCLoginTTL)),
+		},
+		Provider: name,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}
+	cookie, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.auth.signingKey(oidcLoginPurpose))
+	if err != nil {
+		return "", "", domainErrors.NewAppError(err, domainErrors.TokenGeneratorError)
+	}
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), cookie, nil
+}
+
+// OIDCLogin 是一次单点登录的结果
+type OIDCLogin struct {
+	Response models.LoginResponse
+	// Created 和 Linked 表示首次登录时自动创建了用户，或把外部身份关联到了已有用户
+	Created bool
+	Linked  bool
+}
+
+// CompleteLogin 校验回调的 state，用授权码和 PKCE verifier 换取 ID 令牌并校验，然后为对应的用户签发访问令牌
+func (s *OIDCService) CompleteLogin(ctx context.Context, name, code, state, cookie string) (*OIDCLogin, error) {
+	p, err := s.provider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var login oidcLoginClaims
+	_, err = jwt.ParseWithClaims(cookie, &login, func(token *jwt.Token) (any, error) {
+		return s.auth.signingKey(oidcLoginPurpose), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || login.Provider != name || subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_state_invalid")
+	}
+
+	ctx = oidc.ClientContext(ctx, s.httpClient)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		appErr := domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_failed", oauth2ErrorCode(err))
+		appErr.Err = fmt.Errorf("%s: %w", appErr.Err, err)
+		return nil, appErr
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_failed", "missing id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_failed", "invalid id_token")
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_failed", "nonce mismatch")
+	}
+	var claims oidcUserClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_login_failed", "invalid claims")
+	}
+
+	result, user, err := s.resolveUser(ctx, p, idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, expiresAt, err := s.auth.GenerateToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Response = models.LoginResponse{Token: accessToken, ExpiresAt: expiresAt, User: models.NewUserResponse(user)}
+	return result, nil
+}
+
+// provider 返回名称为 name 的身份提供方，首次使用时执行 discovery。discovery 失败时不缓存结果，下次请求重试
+func (s *OIDCService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "oidc_provider_unknown", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.verifier != nil {
+		return p, nil
+	}
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.httpClient), p.issuer)
+	if err != nil {
+		appErr := domainErrors.NewAppErrorWithMessage(domainErrors.ServiceUnavailable, "oidc_provider_unavailable", name)
+		appErr.Err = fmt.Errorf("%s: %w", appErr.Err, err)
+		return nil, appErr
+	}
+	p.oauth2.Endpoint = provider.Endpoint()
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.oauth2.ClientID})
+	return p, nil
+}
+
+// resolveUser 返回外部身份关联的用户。尚未关联时，如果身份提供方和本地都已验证同一邮箱则关联该用户，
+// 否则在 OIDC_AUTO_PROVISION 未关闭时创建新用户
+func (s *OIDCService) resolveUser(ctx context.Context, p *oidcProvider, issuer, subject string, claims oidcUserClaims) (*OIDCLogin, *models.User, error) {
+	emailVerified := claims.EmailVerified != nil && *claims.EmailVerified
+	var user models.User
+	login := &OIDCLogin{}
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+		if err == nil {
+			if err := checkUserActive(tx, identity.UserID); err != nil {
+				return err
+			}
+			if err := tx.Model(&identity).Updates(map[string]any{"email": claims.Email, "last_login_at": now}).Error; err != nil {
+				return err
+			}
+			return tx.Preload("Roles").First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" {
+			return domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "oidc_email_missing")
+		}
+		err = tx.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil:
+			// 只有双方都验证过邮箱时才能确认是同一个人，否则可能有人预先用他人的邮箱注册
+			if !emailVerified || user.EmailVerifiedAt == nil {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "oidc_email_in_use", claims.Email)
+			}
+			if user.DeactivatedAt != nil {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthenticated, "account_deactivated")
+			}
+			login.Linked = true
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !s.autoProvision {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.NotAuthorized, "oidc_account_not_found")
+			}
+			userName, err := availableUserName(tx, claims)
+			if err != nil {
+				return err
+			}
+			// 外部用户没有密码，只能通过身份提供方登录
+			user = models.User{UserName: userName, Email: claims.Email}
+			if emailVerified {
+				user.EmailVerifiedAt = &now
+			}
+			if err := createUser(tx, &user); err != nil {
+				return err
+			}
+			login.Created = true
+		default:
+			return err
+		}
+
+		identity = models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    p.name,
+			Issuer:      issuer,
+			Subject:     subject,
+			Email:       claims.Email,
+			LastLoginAt: now,
+		}
+		if err := tx.Create(&identity).Error; err != nil {
+			return err
+		}
+		return tx.Preload("Roles").First(&user, user.ID).Error
+	})
+	if err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	return login, &user, nil
+}
+
+// availableUserName 根据 preferred_username 或邮箱生成符合注册规则且未被使用的用户名
+func availableUserName(tx *gorm.DB, claims oidcUserClaims) (string, error) {
+	base := claims.PreferredUsername
+	if local, _, found := strings.Cut(base, "@"); found {
+		base = local
+	}
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.TrimLeft(oidcUserNameInvalid.ReplaceAllString(base, ""), "_.-")
+	base = base[:min(len(base), 27)]
+	if len(base) < 4 {
+		base = strings.TrimSuffix("user-"+base, "-")
+	}
+
+	name := base
+	for range 5 {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("user_name = ?", name).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return name, nil
+		}
+		suffix := make([]byte, 2)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		name = base + "-" + hex.EncodeToString(suffix)
+	}
+	return "", domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "field_already_exists", "user_name")
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// oauth2AuthorizationErrors 是 RFC 6749 和 OpenID Connect Core 定义的授权接口错误码
+var oauth2AuthorizationErrors = []string{
+	"access_denied", "invalid_request", "invalid_scope", "server_error", "temporarily_unavailable",
+	"unauthorized_client", "unsupported_response_type", "interaction_required", "login_required",
+	"account_selection_required", "consent_required", "invalid_request_uri", "invalid_request_object",
+	"request_not_supported", "request_uri_not_supported", "registration_not_supported",
+}
+
+// OAuth2AuthorizationError 返回回调中 error 参数对应的标准错误码，用于日志和审计。
+// 参数可能被任意构造，不是标准错误码时返回 unknown_error
+func OAuth2AuthorizationError(code string) string {
+	if slices.Contains(oauth2AuthorizationErrors, code) {
+		return code
+	}
+	return "unknown_error"
+}
+
+// oauth2ErrorCode 返回令牌接口返回的错误码，不包含可能含有敏感信息的响应内容
+func oauth2ErrorCode(err error) string {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode != "" {
+		return retrieveErr.ErrorCode
+	}
+	return "token exchange failed"
+}
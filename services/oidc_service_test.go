@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/thoulee21/go-learn/models"
+)
+
+const stubClientID = "chatbot"
+
+// stubGrant 是授权码对应的登录结果，登录时的 PKCE challenge 和 nonce 来自授权地址
+type stubGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+// stubIssuer 是一个本地的 OpenID Connect 身份提供方，提供 discovery、JWKS 和令牌接口
+type stubIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]stubGrant
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	issuer := &stubIssuer{key: key, grants: map[string]stubGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /jwks", issuer.jwks)
+	mux.HandleFunc("POST /token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (s *stubIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeStubJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.server.URL,
+		"authorization_endpoint":                s.server.URL + "/authorize",
+		"token_endpoint":                        s.server.URL + "/token",
+		"jwks_uri":                              s.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *stubIssuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	writeStubJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(s.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token 用授权码换取 ID 令牌，code_verifier 与登录时的 challenge 不符时按 RFC 7636 返回 invalid_grant
+func (s *stubIssuer) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostFormValue("code")]
+	delete(s.grants, r.PostFormValue("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   s.server.URL,
+		"aud":   stubClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub"
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeStubJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeStubJSON(w, http.StatusOK, map[string]any{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func writeStubJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// stubLogin 是一次进行中的单点登录：授权地址中的参数和需要在回调时提交的 cookie
+type stubLogin struct {
+	state, nonce, challenge, cookie string
+}
+
+type oidcTest struct {
+	issuer *stubIssuer
+	oidc   *OIDCService
+}
+
+func newOIDCTest(t *testing.T, autoProvision bool) (*oidcTest, *accountTest) {
+	t.Helper()
+	issuer := newStubIssuer(t)
+	t.Setenv("OIDC_PROVIDERS", "stub")
+	t.Setenv("OIDC_STUB_ISSUER", issuer.server.URL)
+	t.Setenv("OIDC_STUB_CLIENT_ID", stubClientID)
+	t.Setenv("OIDC_STUB_CLIENT_SECRET", "stub-secret")
+	if !autoProvision {
+		t.Setenv("OIDC_AUTO_PROVISION", "false")
+	}
+
+	a := newAccountTest(t)
+	service, err := NewOIDCService(a.db, a.auth)
+	if err != nil {
+		t.Fatalf("NewOIDCService: %v", err)
+	}
+	return &oidcTest{issuer: issuer, oidc: service}, a
+}
+
+// begin 开始登录并读取授权地址中的 state、nonce 和 PKCE challenge
+func (o *oidcTest) begin(t *testing.T) stubLogin {
+	t.Helper()
+	authURL, cookie, err := o.oidc.BeginLogin(context.Background(), "stub")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing authorization URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization URL %s does not use PKCE S256", authURL)
+	}
+	return stubLogin{
+		state:     query.Get("state"),
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+		cookie:    cookie,
+	}
+}
+
+// authorize 模拟用户在身份提供方登录成功，返回回调中的授权码
+func (o *oidcTest) authorize(login stubLogin, claims jwt.MapClaims) string {
+	code := rand.Text()
+	o.issuer.mu.Lock()
+	o.issuer.grants[code] = stubGrant{challenge: login.challenge, nonce: login.nonce, claims: claims}
+	o.issuer.mu.Unlock()
+	return code
+}
+
+// signIn 完成一次身份提供方返回 claims 的登录
+func (o *oidcTest) signIn(t *testing.T, claims jwt.MapClaims) (*OIDCLogin, error) {
+	t.Helper()
+	login := o.begin(t)
+	return o.oidc.CompleteLogin(context.Background(), "stub", o.authorize(login, claims), login.state, login.cookie)
+}
+
+func TestOIDCRejectsForgedCallbacks(t *testing.T) {
+	o, _ := newOIDCTest(t, true)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"sub": "user-1", "email": "alice@example.com", "email_verified": true}
+
+	t.Run("state", func(t *testing.T) {
+		login := o.begin(t)
+		_, err := o.oidc.CompleteLogin(ctx, "stub", o.authorize(login, claims), "forged-state", login.cookie)
+		requireMessage(t, err, "oidc_state_invalid")
+	})
+
+	t.Run("tampered cookie", func(t *testing.T) {
+		login := o.begin(t)
+		_, err := o.oidc.CompleteLogin(ctx, "stub", o.authorize(login, claims), login.state, login.cookie+"x")
+		requireMessage(t, err, "oidc_state_invalid")
+	})
+
+	t.Run("pkce", func(t *testing.T) {
+		// 攻击者的授权码注入到受害者的登录会话中：授权码绑定的是攻击者的 challenge
+		victim := o.begin(t)
+		attacker := o.begin(t)
+		code := o.authorize(attacker, claims)
+		_, err := o.oidc.CompleteLogin(ctx, "stub", code, victim.state, victim.cookie)
+		requireMessage(t, err, "oidc_login_failed")
+	})
+
+	t.Run("nonce", func(t *testing.T) {
+		login := o.begin(t)
+		replayed := login
+		replayed.nonce = "nonce-from-another-login"
+		_, err := o.oidc.CompleteLogin(ctx, "stub", o.authorize(replayed, claims), login.state, login.cookie)
+		requireMessage(t, err, "oidc_login_failed")
+	})
+
+	var count int64
+	o.oidc.DB.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("rejected logins created %d users", count)
+	}
+}
+
+func TestOIDCLinksVerifiedEmail(t *testing.T) {
+	o, a := newOIDCTest(t, true)
+	user := createTestUser(t, a.db, "alice")
+	if err := a.db.Model(user).Update("email_verified_at", time.Now()).Error; err != nil {
+		t.Fatalf("verifying email: %v", err)
+	}
+
+	claims := jwt.MapClaims{"sub": "user-1", "email": user.Email, "email_verified": true}
+	login, err := o.signIn(t, claims)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if !login.Linked || login.Created || login.Response.User.ID != user.ID {
+		t.Fatalf("got linked=%v created=%v user=%d, want identity linked to user %d",
+			login.Linked, login.Created, login.Response.User.ID, user.ID)
+	}
+	if _, err := a.auth.ParseToken(login.Response.Token); err != nil {
+		t.Fatalf("issued access token is invalid: %v", err)
+	}
+
+	// 再次登录按外部身份找到用户，即使身份提供方上的邮箱已经改变
+	claims["email"] = "alice@corp.example.com"
+	again, err := o.signIn(t, claims)
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+	if again.Linked || again.Created || again.Response.User.ID != user.ID {
+		t.Fatalf("second login resolved user %d (linked=%v created=%v), want existing user %d",
+			again.Response.User.ID, again.Linked, again.Created, user.ID)
+	}
+}
+
+func TestOIDCRefusesToLinkUnverifiedEmail(t *testing.T) {
+	o, a := newOIDCTest(t, true)
+	unverified := createTestUser(t, a.db, "alice")
+	verified := createTestUser(t, a.db, "bobby")
+	if err := a.db.Model(verified).Update("email_verified_at", time.Now()).Error; err != nil {
+		t.Fatalf("verifying email: %v", err)
+	}
+
+	// 本地账户没有验证邮箱
+	_, err := o.signIn(t, jwt.MapClaims{"sub": "user-1", "email": unverified.Email, "email_verified": true})
+	requireMessage(t, err, "oidc_email_in_use")
+
+	// 身份提供方没有验证邮箱
+	_, err = o.signIn(t, jwt.MapClaims{"sub": "user-2", "email": verified.Email, "email_verified": false})
+	requireMessage(t, err, "oidc_email_in_use")
+	_, err = o.signIn(t, jwt.MapClaims{"sub": "user-3", "email": verified.Email})
+	requireMessage(t, err, "oidc_email_in_use")
+
+	var count int64
+	a.db.Model(&models.UserIdentity{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("refused logins created %d identities", count)
+	}
+}
+
+func TestOIDCAutoProvisioning(t *testing.T) {
+	o, a := newOIDCTest(t, true)
+	createTestUser(t, a.db, "carol")
+
+	login, err := o.signIn(t, jwt.MapClaims{
+		"sub": "user-1", "email": "carol.smith@example.com", "email_verified": true, "preferred_username": "carol",
+	})
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if !login.Created || login.Linked {
+		t.Fatalf("got created=%v linked=%v, want a new user", login.Created, login.Linked)
+	}
+	created := login.Response.User
+	if created.UserName == "carol" || len(created.UserName) < 4 {
+		t.Fatalf("user name %q collides with an existing user or is too short", created.UserName)
+	}
+	if created.Email != "carol.smith@example.com" || created.EmailVerifiedAt == nil {
+		t.Fatalf("got email %s verified at %v, want the verified email from the ID token", created.Email, created.EmailVerifiedAt)
+	}
+	if len(created.Roles) != 1 || created.Roles[0] != models.RoleUser {
+		t.Fatalf("new user has roles %v, want [%s]", created.Roles, models.RoleUser)
+	}
+
+	// 身份提供方没有验证的邮箱在本地同样视为未验证
+	unverified, err := o.signIn(t, jwt.MapClaims{"sub": "user-2", "email": "dave@example.com"})
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if !unverified.Created || unverified.Response.User.EmailVerifiedAt != nil {
+		t.Fatal("email not verified by the identity provider was marked as verified")
+	}
+
+	_, err = o.signIn(t, jwt.MapClaims{"sub": "user-3"})
+	requireMessage(t, err, "oidc_email_missing")
+}
+
+func TestOIDCWithoutAutoProvisioning(t *testing.T) {
+	o, _ := newOIDCTest(t, false)
+	_, err := o.signIn(t, jwt.MapClaims{"sub": "user-1", "email": "erin@example.com", "email_verified": true})
+	requireMessage(t, err, "oidc_account_not_found")
+}
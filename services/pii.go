@@ -0,0 +1,109 @@
+package services
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PII 类别
+const (
+	PIIEmail      = "pii.email"
+	PIIPhone      = "pii.phone"
+	PIICreditCard = "pii.credit_card"
+	PIINationalID = "pii.national_id"
+)
+
+// PIIPattern 是一种个人信息的识别规则，Validate 用于排除格式相同但校验位不正确的误报
+type PIIPattern struct {
+	Category string
+	Regexp   *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// DefaultPIIPatterns 是内置的个人信息识别规则，靠前的规则优先
+var DefaultPIIPatterns = []PIIPattern{
+	{Category: PIIEmail, Regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	// 中国居民身份证号和美国社会安全号
+	{Category: PIINationalID, Regexp: regexp.MustCompile(`\b\d{17}[\dXx]\b`), Validate: validChineseID},
+	{Category: PIINationalID, Regexp: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{Category: PIICreditCard, Regexp: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), Validate: luhnValid},
+	// 中国大陆手机号、带国家代码的号码以及北美格式的号码
+	{Category: PIIPhone, Regexp: regexp.MustCompile(`(?:\+?86[ \-]?)?\b1[3-9]\d[ \-]?\d{4}[ \-]?\d{4}\b`)},
+	{Category: PIIPhone, Regexp: regexp.MustCompile(`\+\d{1,3}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,3}\b`)},
+	{Category: PIIPhone, Regexp: regexp.MustCompile(`\(?\b\d{3}\)?[ .\-]\d{3}[ .\-]\d{4}\b`)},
+}
+
+// PIIMatch 是文本中识别到的一处个人信息，Start 和 End 为字节偏移
+type PIIMatch struct {
+	Category string
+	Start    int
+	End      int
+}
+
+// FindPII 返回文本中不重叠的个人信息，按出现位置排序
+func FindPII(text string, patterns []PIIPattern) []PIIMatch {
+	var matches []PIIMatch
+	for _, pattern := range patterns {
+		for _, loc := range pattern.Regexp.FindAllStringIndex(text, -1) {
+			if pattern.Validate != nil && !pattern.Validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			if overlaps(matches, loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, PIIMatch{Category: pattern.Category, Start: loc[0], End: loc[1]})
+		}
+	}
+	slices.SortFunc(matches, func(a, b PIIMatch) int { return a.Start - b.Start })
+	return matches
+}
+
+func overlaps(matches []PIIMatch, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && m.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid 使用 Luhn 算法校验银行卡号
+func luhnValid(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validChineseID 校验18位居民身份证号的校验码
+func validChineseID(s string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	const checkCodes = "10X98765432"
+
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	return strings.ToUpper(s[17:]) == string(checkCodes[sum%11])
+}
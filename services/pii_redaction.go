@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+// maxPlaceholderLength 是占位符的最大长度，流式还原时用于判断未闭合的 "[" 是否可能是占位符的开头
+const maxPlaceholderLength = 64
+
+var placeholderPattern = regexp.MustCompile(`\[[A-Z][A-Z0-9_]*_\d+\]`)
+
+// PIIRedactor 在请求发送给模型之前把个人信息替换为占位符
+type PIIRedactor struct {
+	patterns []PIIPattern
+}
+
+// NewPIIRedactor 根据 PII_REDACTION 创建脱敏器，未开启时返回 nil。
+// PII_REDACTION_PATTERNS 可以指向一个 JSON 文件，以 {"类别": "正则表达式"} 的形式追加自定义规则。
+func NewPIIRedactor() (*PIIRedactor, error) {
+	if os.Getenv("PII_REDACTION") != "true" {
+		return nil, nil
+	}
+
+	patterns := append([]PIIPattern(nil), DefaultPIIPatterns...)
+	if path := os.Getenv("PII_REDACTION_PATTERNS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var custom map[string]string
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("invalid PII_REDACTION_PATTERNS: %w", err)
+		}
+		for name, expr := range custom {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PII pattern %s: %w", name, err)
+			}
+			patterns = append(patterns, PIIPattern{Category: "pii." + name, Regexp: re})
+		}
+	}
+	return &PIIRedactor{patterns: patterns}, nil
+}
+
+// NewVault 创建一次请求使用的占位符映射
+func (r *PIIRedactor) NewVault() *PIIVault {
+	return &PIIVault{
+		patterns:     r.patterns,
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// Redact 替换文本中的个人信息，用于日志等不需要还原的场景
+func (r *PIIRedactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.NewVault().Redact(text)
+}
+
+// PIIVault 记录占位符与原始值的对应关系。同一个值在一次请求中始终使用同一个占位符，
+// 按历史消息的顺序编号，因此同一会话的多次请求中占位符也保持一致。
+// nil 表示未开启脱敏，所有方法原样返回输入。
+type PIIVault struct {
+	patterns     []PIIPattern
+	placeholders map[string]string // 原始值 -> 占位符
+	originals    map[string]string // 占位符 -> 原始值
+	counts       map[string]int
+}
+
+// Redact 把文本中的个人信息替换为形如 [EMAIL_1] 的占位符
+func (v *PIIVault) Redact(text string) string {
+	if v == nil {
+		return text
+	}
+	matches := FindPII(text, v.patterns)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	pos := 0
+	for _, match := range matches {
+		sb.WriteString(text[pos:match.Start])
+		sb.WriteString(v.placeholder(match.Category, text[match.Start:match.End]))
+		pos = match.End
+	}
+	sb.WriteString(text[pos:])
+	return sb.String()
+}
+
+func (v *PIIVault) placeholder(category, value string) string {
+	if p, ok := v.placeholders[value]; ok {
+		return p
+	}
+	label := strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(strings.TrimPrefix(category, "pii.")))
+	v.counts[label]++
+	p := fmt.Sprintf("[%s_%d]", label, v.counts[label])
+	v.placeholders[value] = p
+	v.originals[p] = value
+	return p
+}
+
+// Restore 把文本中的占位符还原为原始值，未知的占位符保持不变
+func (v *PIIVault) Restore(text string) string {
+	if v == nil || len(v.originals) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		if original, ok := v.originals[p]; ok {
+			return original
+		}
+		return p
+	})
+}
+
+// RedactMessages 返回脱敏后的消息副本，不修改传入的消息
+func (v *PIIVault) RedactMessages(messages []ChatMessage) []ChatMessage {
+	if v == nil {
+		return messages
+	}
+	redacted := make([]ChatMessage, len(messages))
+	for i, msg := range messages {
+		msg.Content = v.Redact(msg.Content)
+		msg.ToolCalls = v.mapToolCalls(msg.ToolCalls, v.Redact)
+		redacted[i] = msg
+	}
+	return redacted
+}
+
+// RestoreToolCalls 还原工具调用参数中的占位符
+func (v *PIIVault) RestoreToolCalls(calls []models.ToolCall) []models.ToolCall {
+	if v == nil {
+		return calls
+	}
+	return v.mapToolCalls(calls, v.Restore)
+}
+
+func (v *PIIVault) mapToolCalls(calls []models.ToolCall, fn func(string) string) []models.ToolCall {
+	if len(calls) == 0 {
+		return calls
+	}
+	mapped := make([]models.ToolCall, len(calls))
+	for i, call := range calls {
+		call.Arguments = fn(call.Arguments)
+		mapped[i] = call
+	}
+	return mapped
+}
+
+// PIIStreamRestorer 还原流式回复中的占位符，占位符可能被拆分到多个数据块中
+type PIIStreamRestorer struct {
+	vault   *PIIVault
+	pending string
+}
+
+// NewStreamRestorer 创建流式还原器
+func (v *PIIVault) NewStreamRestorer() *PIIStreamRestorer {
+	return &PIIStreamRestorer{vault: v}
+}
+
+// Write 追加一个数据块，返回可以发送给用户的内容。可能是占位符开头的部分会保留到下一个数据块。
+func (r *PIIStreamRestorer) Write(chunk string) string {
+	r.pending += chunk
+	cut := len(r.pending)
+	if i := strings.LastIndexByte(r.pending, '['); i >= 0 &&
+		!strings.Contains(r.pending[i:], "]") && len(r.pending)-i < maxPlaceholderLength {
+		cut = i
+	}
+	out := r.pending[:cut]
+	r.pending = r.pending[cut:]
+	return r.vault.Restore(out)
+}
+
+// Flush 返回剩余的内容
+func (r *PIIStreamRestorer) Flush() string {
+	out := r.pending
+	r.pending = ""
+	return r.vault.Restore(out)
+}
+
+type piiVaultKey struct{}
+
+// WithPIIVault 返回携带占位符映射的 context，AIService 会对使用该 context 的请求脱敏
+func WithPIIVault(ctx context.Context, vault *PIIVault) context.Context {
+	return context.WithValue(ctx, piiVaultKey{}, vault)
+}
+
+// PIIVaultFrom 返回 context 中的占位符映射，未开启脱敏时返回 nil
+func PIIVaultFrom(ctx context.Context) *PIIVault {
+	vault, _ := ctx.Value(piiVaultKey{}).(*PIIVault)
+	return vault
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+func newTestVault(t *testing.T) *PIIVault {
+	t.Helper()
+	t.Setenv("PII_REDACTION", "true")
+	t.Setenv("PII_REDACTION_PATTERNS", "")
+	redactor, err := NewPIIRedactor()
+	if err != nil {
+		t.Fatalf("NewPIIRedactor: %v", err)
+	}
+	return redactor.NewVault()
+}
+
+func TestNewPIIRedactor(t *testing.T) {
+	t.Setenv("PII_REDACTION", "")
+	if redactor, err := NewPIIRedactor(); redactor != nil || err != nil {
+		t.Fatalf("got %v, %v; want redaction off", redactor, err)
+	}
+
+	t.Setenv("PII_REDACTION", "true")
+	patterns := filepath.Join(t.TempDir(), "patterns.json")
+	t.Setenv("PII_REDACTION_PATTERNS", patterns)
+	if err := os.WriteFile(patterns, []byte(`{"employee-id": "EMP-\\d{6}"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	redactor, err := NewPIIRedactor()
+	if err != nil {
+		t.Fatalf("NewPIIRedactor: %v", err)
+	}
+	if got := redactor.Redact("EMP-123456 wrote to a@b.io"); got != "[EMPLOYEE_ID_1] wrote to [EMAIL_1]" {
+		t.Fatalf("Redact = %q", got)
+	}
+
+	if err := os.WriteFile(patterns, []byte(`{"broken": "("}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPIIRedactor(); err == nil {
+		t.Fatal("an invalid custom pattern was accepted")
+	}
+}
+
+func TestVaultPlaceholdersAreStable(t *testing.T) {
+	history := []ChatMessage{
+		{Role: "user", Content: "I am a@b.io, call 13912345678"},
+		{Role: "assistant", Content: "Noted, a@b.io."},
+		{Role: "user", Content: "Also c@d.io and card 4111 1111 1111 1111"},
+	}
+	original := append([]ChatMessage(nil), history...)
+
+	vault := newTestVault(t)
+	redacted := vault.RedactMessages(history)
+	want := []string{
+		"I am [EMAIL_1], call [PHONE_1]",
+		"Noted, [EMAIL_1].",
+		"Also [EMAIL_2] and card [CREDIT_CARD_1]",
+	}
+	for i, msg := range redacted {
+		if msg.Content != want[i] {
+			t.Errorf("message %d = %q, want %q", i, msg.Content, want[i])
+		}
+	}
+	if !reflect.DeepEqual(history, original) {
+		t.Fatal("RedactMessages modified its input")
+	}
+
+	// 同一会话的下一次请求重新创建 vault，历史消息得到相同的占位符
+	next := newTestVault(t).RedactMessages(append(history, ChatMessage{Role: "user", Content: "e@f.io"}))
+	for i := range redacted {
+		if next[i].Content != redacted[i].Content {
+			t.Errorf("message %d = %q in the next request, was %q", i, next[i].Content, redacted[i].Content)
+		}
+	}
+	if next[3].Content != "[EMAIL_3]" {
+		t.Errorf("new value got %q, want [EMAIL_3]", next[3].Content)
+	}
+}
+
+func TestVaultRestore(t *testing.T) {
+	vault := newTestVault(t)
+	vault.Redact("a@b.io 13912345678")
+
+	tests := map[string]string{
+		"Hi [EMAIL_1], I will call [PHONE_1].": "Hi a@b.io, I will call 13912345678.",
+		"unknown [EMAIL_2] stays":              "unknown [EMAIL_2] stays",
+		"[note] and [EMAIL_1":                  "[note] and [EMAIL_1",
+		"[[EMAIL_1]]":                          "[a@b.io]",
+	}
+	for input, want := range tests {
+		if got := vault.Restore(input); got != want {
+			t.Errorf("Restore(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	calls := []models.ToolCall{{ID: "1", Name: "send_mail", Arguments: `{"to":"[EMAIL_1]"}`}}
+	if got := vault.RestoreToolCalls(calls)[0].Arguments; got != `{"to":"a@b.io"}` {
+		t.Errorf("RestoreToolCalls = %s", got)
+	}
+	if calls[0].Arguments != `{"to":"[EMAIL_1]"}` {
+		t.Error("RestoreToolCalls modified its input")
+	}
+}
+
+func TestNilVaultPassesThrough(t *testing.T) {
+	var vault *PIIVault
+	if got := vault.Redact("a@b.io"); got != "a@b.io" {
+		t.Errorf("Redact = %q", got)
+	}
+	if got := vault.Restore("[EMAIL_1]"); got != "[EMAIL_1]" {
+		t.Errorf("Restore = %q", got)
+	}
+	var redactor *PIIRedactor
+	if got := redactor.Redact("a@b.io"); got != "a@b.io" {
+		t.Errorf("PIIRedactor.Redact = %q", got)
+	}
+}
+
+// streamAll 把 chunks 依次写入还原器，返回用户收到的全部内容
+func streamAll(vault *PIIVault, chunks ...string) (string, []string) {
+	restorer := vault.NewStreamRestorer()
+	var sent []string
+	for _, chunk := range chunks {
+		sent = append(sent, restorer.Write(chunk))
+	}
+	sent = append(sent, restorer.Flush())
+	return strings.Join(sent, ""), sent
+}
+
+func TestStreamRestorerSplitPlaceholders(t *testing.T) {
+	vault := newTestVault(t)
+	vault.Redact("a@b.io 13912345678")
+	reply := "Mail [EMAIL_1] or call [PHONE_1]; see [docs] and [EMAIL_9]."
+	want := vault.Restore(reply)
+
+	// 在任意位置拆成两块或三块，结果都与一次性还原相同
+	for i := 0; i <= len(reply); i++ {
+		if got, sent := streamAll(vault, reply[:i], reply[i:]); got != want {
+			t.Fatalf("split at %d: got %q (%q), want %q", i, got, sent, want)
+		}
+		for j := i; j <= len(reply); j += 3 {
+			if got, sent := streamAll(vault, reply[:i], reply[i:j], reply[j:]); got != want {
+				t.Fatalf("split at %d and %d: got %q (%q), want %q", i, j, got, sent, want)
+			}
+		}
+	}
+
+	// 可能是占位符开头的部分保留到下一个数据块，其余内容立即发送
+	_, sent := streamAll(vault, "Mail [EMA", "IL_1] now")
+	if want := []string{"Mail ", "a@b.io now", ""}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("sent %q, want %q", sent, want)
+	}
+	// 没有闭合的 "[" 在流结束时原样发送
+	if got, _ := streamAll(vault, "array[", "0"); got != "array[0" {
+		t.Fatalf("got %q", got)
+	}
+	// 超过占位符最大长度的 "[" 不再等待
+	long := "[" + strings.Repeat("x", maxPlaceholderLength)
+	if _, sent := streamAll(vault, long); sent[0] != long {
+		t.Fatalf("held back %q", long)
+	}
+}
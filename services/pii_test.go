@@ -0,0 +1,95 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPII(t *testing.T) {
+	type found struct {
+		Category string
+		Text     string
+	}
+	tests := []struct {
+		name string
+		text string
+		want []found
+	}{
+		{"email", "write to alice.w+chat@example.co.uk today", []found{{PIIEmail, "alice.w+chat@example.co.uk"}}},
+		{"chinese id", "身份证 11010519491231002X 和 440304199001011233", []found{
+			{PIINationalID, "11010519491231002X"}, {PIINationalID, "440304199001011233"},
+		}},
+		{"chinese id with wrong check digit", "id 110105194912310021", nil},
+		{"ssn", "SSN 123-45-6789.", []found{{PIINationalID, "123-45-6789"}}},
+		{"credit card", "card 4111 1111 1111 1111 or 5500-0055-5555-5559", []found{
+			{PIICreditCard, "4111 1111 1111 1111"}, {PIICreditCard, "5500-0055-5555-5559"},
+		}},
+		{"digits failing luhn", "order 1234567812345678", nil},
+		{"mainland mobile", "call +86 139 1234 5678 or 13912345678", []found{
+			{PIIPhone, "+86 139 1234 5678"}, {PIIPhone, "13912345678"},
+		}},
+		{"north american phone", "office (415) 555-0100", []found{{PIIPhone, "(415) 555-0100"}}},
+		{"international phone", "London +44 20 7946 0958", []found{{PIIPhone, "+44 20 7946 0958"}}},
+		// 靠前的规则优先，被覆盖的部分不会再被其他规则识别
+		{"email wins over phone", "13812345678@example.com", []found{{PIIEmail, "13812345678@example.com"}}},
+		{"card wins over phone when the digits pass luhn", "+86 138 1234 5678", []found{{PIICreditCard, "86 138 1234 5678"}}},
+		{"id wins over card", "440304199001011233", []found{{PIINationalID, "440304199001011233"}}},
+		{"no pii", "version 1.2.3 released on 2026-10-19", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []found
+			for _, match := range FindPII(tt.text, DefaultPIIPatterns) {
+				got = append(got, found{match.Category, tt.text[match.Start:match.End]})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("FindPII(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindPIIOrdersMatchesByPosition(t *testing.T) {
+	text := "13912345678, a@b.io, 123-45-6789"
+	matches := FindPII(text, DefaultPIIPatterns)
+	var categories []string
+	for i, match := range matches {
+		if i > 0 && match.Start < matches[i-1].End {
+			t.Fatalf("matches overlap: %+v", matches)
+		}
+		categories = append(categories, match.Category)
+	}
+	if want := []string{PIIPhone, PIIEmail, PIINationalID}; !reflect.DeepEqual(categories, want) {
+		t.Fatalf("categories = %v, want %v", categories, want)
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	for number, want := range map[string]bool{
+		"4111111111111111":     true,
+		"4111-1111-1111-1111":  true,
+		"4111111111111112":     false,
+		"5500005555555559":     true,
+		"0000000000000":        true,
+		"000000000000":         false, // 少于 13 位
+		"00000000000000000000": false, // 多于 19 位
+	} {
+		if got := luhnValid(number); got != want {
+			t.Errorf("luhnValid(%q) = %v, want %v", number, got, want)
+		}
+	}
+}
+
+func TestValidChineseID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"11010519491231002X": true,
+		"11010519491231002x": true,
+		"440304199001011233": true,
+		"440304199001011234": false,
+		"110105194912310021": false,
+	} {
+		if got := validChineseID(id); got != want {
+			t.Errorf("validChineseID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+// erasureBatchSize 是每轮处理的删除请求数量
+const erasureBatchSize = 20
+
+type PrivacyService struct {
+	DB                  *gorm.DB
+	AttachmentService   *AttachmentService
+	ConversationService *ConversationService
+	// ResponseCache 为 nil 表示未启用回复缓存
+	ResponseCache ResponseCache
+	// wake 在收到新的删除请求时唤醒后台任务，不必等到下一轮
+	wake chan struct{}
+}
+
+func NewPrivacyService(db *gorm.DB, attachmentService *AttachmentService, conversationService *ConversationService, responseCache ResponseCache) (*PrivacyService, error) {
+	return &PrivacyService{
+		DB:                  db,
+		AttachmentService:   attachmentService,
+		ConversationService: conversationService,
+		ResponseCache:       responseCache,
+		wake:                make(chan struct{}, 1),
+	}, nil
+}
+
+// RequestErasure 创建彻底删除用户数据的请求。用户立即被停用并软删除，
+// 数据由后台任务删除，完成后请求中保留删除回执
+func (s *PrivacyService) RequestErasure(ctx context.Context, userID, requestedBy uint) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 已软删除的用户同样可以被彻底删除
+		var user models.User
+		if err := tx.Unscoped().First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+			}
+			return domainErrors.TranslateDBError(err)
+		}
+
+		var pending int64
+		if err := tx.Model(&models.ErasureRequest{}).
+			Where("user_id = ? AND status = ?", userID, models.ErasureStatusPending).
+			Count(&pending).Error; err != nil {
+			return domainErrors.TranslateDBError(err)
+		}
+		if pending > 0 {
+			return domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "erasure_pending")
+		}
+
+		subject := sha256.Sum256([]byte(strings.ToLower(user.Email)))
+		request = models.ErasureRequest{
+			UserID:      userID,
+			SubjectHash: hex.EncodeToString(subject[:]),
+			RequestedBy: requestedBy,
+			Status:      models.ErasureStatusPending,
+		}
+		if err := tx.Create(&request).Error; err != nil {
+			return domainErrors.TranslateDBError(err)
+		}
+
+		now := time.Now()
+		if user.DeactivatedAt == nil {
+			if err := tx.Unscoped().Model(&user).Update("deactivated_at", now).Error; err != nil {
+				return domainErrors.TranslateDBError(err)
+			}
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return domainErrors.TranslateDBError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return &request, nil
+}
+
+// ListErasures 获取删除请求，status 为空时返回全部
+func (s *PrivacyService) ListErasures(ctx context.Context, status string) ([]models.ErasureRequest, error) {
+	query := s.DB.WithContext(ctx).Order("id desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []models.ErasureRequest
+	if err := query.Find(&requests).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return requests, nil
+}
+
+// GetErasure 获取单个删除请求
+func (s *PrivacyService) GetErasure(ctx context.Context, id uint) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	if err := s.DB.WithContext(ctx).First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "erasure_not_found")
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &request, nil
+}
+
+// ProcessErasures 执行待处理的删除请求，返回完成的数量。
+// 执行失败的请求记录错误后保持待处理状态，下一轮重试；每一步都可以重复执行
+func (s *PrivacyService) ProcessErasures(ctx context.Context) (int, error) {
+	var requests []models.ErasureRequest
+	err := s.DB.WithContext(ctx).Where("status = ?", models.ErasureStatusPending).
+		Order("id asc").Limit(erasureBatchSize).Find(&requests).Error
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for i := range requests {
+		request := &requests[i]
+		if err := s.erase(ctx, request); err != nil {
+			slog.ErrorContext(ctx, "erasure request failed", slog.Uint64("request_id", uint64(request.ID)),
+				slog.Uint64("user_id", uint64(request.UserID)), slog.Any("error", err))
+			updateErr := s.DB.WithContext(ctx).Model(request).Updates(map[string]any{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": err.Error(),
+				"receipt":    request.Receipt,
+			}).Error
+			if updateErr != nil {
+				return completed, updateErr
+			}
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+// StartErasureWorker 在后台定期执行删除请求，收到新请求时立即执行，直到 ctx 被取消。后台任务退出时调用 wg.Done
+func (s *PrivacyService) StartErasureWorker(ctx context.Context, interval time.Duration, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			completed, err := s.ProcessErasures(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "erasure processing failed", slog.Any("error", err))
+			} else if completed > 0 {
+				slog.InfoContext(ctx, "completed erasure requests", slog.Int("count", completed))
+			}
+		}
+	}()
+}
+
+// erase 删除用户的全部数据并在请求中记录回执。
+// 用户会话中的消息（包括助手回复和工具消息）、附件、分享链接、邮件记录、API 密钥、外部身份和回复缓存被删除；
+// 审核记录、知识库文档和审计日志被匿名化
+func (s *PrivacyService) erase(ctx context.Context, request *models.ErasureRequest) error {
+	db := s.DB.WithContext(ctx)
+	userID := request.UserID
+
+	var sessions []string
+	if err := db.Model(&models.ChatMessage{}).Where("user_id = ?", userID).
+		Distinct().Pluck("session_id", &sessions).Error; err != nil {
+		return err
+	}
+	request.Receipt.Sessions = max(request.Receipt.Sessions, len(sessions))
+
+	// 附件内容不在数据库中，逐个删除；重试时只会找到尚未删除的附件
+	var attachments []models.Attachment
+	if err := s.userScope(db, userID, sessions).Find(&attachments).Error; err != nil {
+		return err
+	}
+	for i := range attachments {
+		if err := s.AttachmentService.remove(ctx, &attachments[i]); err != nil {
+			return err
+		}
+		request.Receipt.Attachments++
+	}
+
+	// 进程内的回复缓存不在数据库中；SQL 缓存中的记录在下面的事务中同样会被删除
+	if s.ResponseCache != nil {
+		deleted, err := s.ResponseCache.DeleteUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		request.Receipt.ResponseCacheEntries += deleted
+	}
+
+	// 事务回滚时数量不计入回执
+	receipt := request.Receipt
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := s.userScope(tx, userID, sessions).Delete(&models.ChatMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.Messages += result.RowsAffected
+
+		for _, step := range []struct {
+			model any
+			count *int64
+		}{
+			{&models.ShareLink{}, &receipt.ShareLinks},
+			{&models.EmailDelivery{}, &receipt.EmailDeliveries},
+			{&models.APIKey{}, &receipt.APIKeys},
+			{&models.UserIdentity{}, &receipt.Identities},
+			{&models.ResponseCacheEntry{}, &receipt.ResponseCacheEntries},
+		} {
+			result := tx.Where("user_id = ?", userID).Delete(step.model)
+			if result.Error != nil {
+				return result.Error
+			}
+			*step.count += result.RowsAffected
+		}
+
+		result = s.userScope(tx.Model(&models.ModerationFlag{}), userID, sessions).Updates(map[string]any{
+			"user_id":    nil,
+			"session_id": "",
+			"message_id": nil,
+			"excerpt":    "",
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.ModerationFlags += result.RowsAffected
+		if err := tx.Model(&models.ModerationFlag{}).Where("reviewed_by = ?", userID).
+			Update("reviewed_by", nil).Error; err != nil {
+			return err
+		}
+
+		result = tx.Model(&models.KnowledgeDocument{}).Where("user_id = ?", userID).Update("user_id", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.KnowledgeDocuments += result.RowsAffected
+
+		pseudonymized, err := pseudonymizeAuditLogs(tx, userID)
+		if err != nil {
+			return err
+		}
+		receipt.AuditLogs += pseudonymized
+
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.User{ID: userID}).Error; err != nil {
+			return err
+		}
+		receipt.UserDeleted = true
+
+		return tx.Model(request).Updates(map[string]any{
+			"status":       models.ErasureStatusCompleted,
+			"completed_at": time.Now(),
+			"last_error":   "",
+			"receipt":      receipt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	request.Receipt = receipt
+	return nil
+}
+
+// pseudonymizeAuditLogs 去掉审计日志中可以识别用户的信息，返回修改的记录数。
+// 审计日志本身保留，用户 ID 在用户删除后不再对应任何人
+func pseudonymizeAuditLogs(tx *gorm.DB, userID uint) (int64, error) {
+	var user models.User
+	if err := tx.Unscoped().Select("id", "user_name").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	targetID := strconv.FormatUint(uint64(userID), 10)
+	var entries []models.AuditLog
+	err := tx.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", userID, models.AuditTargetUser, targetID).
+		Find(&entries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// 用户改过用户名时，以前的用户名同样需要去掉
+	names := []string{user.UserName}
+	for _, entry := range entries {
+		if change, ok := entry.Changes["user_name"]; ok && entry.TargetID == targetID {
+			for _, name := range []any{change.Before, change.After} {
+				if name, ok := name.(string); ok && name != models.AuditRedacted {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	// 登录失败的记录没有操作者，只能按尝试的用户名查找，LIKE 的结果在 pseudonymizeAuditLog 中再精确比较
+	for _, name := range names {
+		quoted, _ := json.Marshal(name)
+		pattern := "%" + likeEscaper.Replace(`"user_name":`+string(quoted)) + "%"
+		var failures []models.AuditLog
+		err := tx.Where("action = ? AND actor_id IS NULL AND metadata LIKE ? ESCAPE '!'", models.AuditActionLogin, pattern).
+			Find(&failures).Error
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, failures...)
+	}
+
+	var count int64
+	for i := range entries {
+		entry := &entries[i]
+		if !pseudonymizeAuditLog(entry, userID, names) {
+			continue
+		}
+		err := models.PseudonymizeAuditLogs(tx).Model(entry).Select("changes", "metadata", "ip").Updates(entry).Error
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// likeEscaper 转义 LIKE 中的通配符，配合 ESCAPE '!' 使用
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// pseudonymizeAuditLog 去掉一条审计日志中属于用户的用户名、邮箱和 IP，返回是否有修改。
+// 管理员对该用户的操作保留管理员的 IP
+func pseudonymizeAuditLog(entry *models.AuditLog, userID uint, names []string) bool {
+	changed := false
+	isTarget := entry.TargetType == models.AuditTargetUser && entry.TargetID == strconv.FormatUint(uint64(userID), 10)
+	if isTarget {
+		for _, field := range []string{"user_name", "email"} {
+			change, ok := entry.Changes[field]
+			if ok && (change.Before != models.AuditRedacted || change.After != models.AuditRedacted) {
+				entry.Changes[field] = models.AuditChange{Before: models.AuditRedacted, After: models.AuditRedacted}
+				changed = true
+			}
+		}
+	}
+
+	name, hasName := entry.Metadata["user_name"].(string)
+	ownName := hasName && (isTarget || slices.Contains(names, name))
+	if ownName && name != models.AuditRedacted {
+		entry.Metadata["user_name"] = models.AuditRedacted
+		changed = true
+	}
+
+	isActor := entry.ActorID != nil && *entry.ActorID == userID
+	failedLogin := entry.ActorID == nil && entry.Action == models.AuditActionLogin && ownName
+	if (isActor || failedLogin) && entry.IP != "" {
+		entry.IP = ""
+		changed = true
+	}
+	return changed
+}
+
+// userScope 筛选属于用户或用户会话中的记录
+func (s *PrivacyService) userScope(db *gorm.DB, userID uint, sessions []string) *gorm.DB {
+	if len(sessions) == 0 {
+		return db.Where("user_id = ?", userID)
+	}
+	return db.Where("user_id = ? OR session_id IN ?", userID, sessions)
+}
+
+// Export 将用户的全部个人数据打包为 zip 写入 w，包含 data.json 和 attachments 目录中的附件内容
+func (s *PrivacyService) Export(ctx context.Context, userID uint, w io.Writer) error {
+	db := s.DB.WithContext(ctx)
+	var user models.User
+	if err := db.Preload("Roles").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+		}
+		return domainErrors.TranslateDBError(err)
+	}
+
+	conversations, err := s.ConversationService.GetUserConversations(userID)
+	if err != nil {
+		return err
+	}
+	export := models.UserExport{
+		ExportedAt:    time.Now().UTC(),
+		User:          models.NewUserResponse(&user),
+		Conversations: conversations,
+	}
+	for _, query := range []any{
+		&export.Identities, &export.APIKeys, &export.Attachments, &export.ShareLinks,
+		&export.KnowledgeDocuments, &export.ModerationFlags, &export.EmailDeliveries,
+	} {
+		if err := db.Where("user_id = ?", userID).Order("id asc").Find(query).Error; err != nil {
+			return domainErrors.TranslateDBError(err)
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	data, err := archive.CreateHeader(&zip.FileHeader{Name: "data.json", Method: zip.Deflate, Modified: export.ExportedAt})
+	if err != nil {
+		return domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	encoder := json.NewEncoder(data)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+
+	for i := range export.Attachments {
+		attachment := &export.Attachments[i]
+		if attachment.StorageKey == "" {
+			continue
+		}
+		if err := s.exportAttachment(ctx, archive, attachment); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	return nil
+}
+
+func (s *PrivacyService) exportAttachment(ctx context.Context, archive *zip.Writer, attachment *models.Attachment) error {
+	content, err := s.AttachmentService.Open(ctx, attachment)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	name := path.Join("attachments", fmt.Sprintf("%d-%s", attachment.ID, path.Base(attachment.FileName)))
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: attachment.CreatedAt})
+	if err != nil {
+		return domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		return domainErrors.NewAppError(err, domainErrors.UnknownError)
+	}
+	return nil
+}
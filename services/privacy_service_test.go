@@ -0,0 +1,271 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+type privacyTest struct {
+	db      *gorm.DB
+	storage *LocalStorage
+	cache   *MemoryResponseCache
+	privacy *PrivacyService
+}
+
+func newPrivacyTest(t *testing.T) *privacyTest {
+	t.Helper()
+	db := newTestDB(t)
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	conversations, err := NewConversationService(db)
+	if err != nil {
+		t.Fatalf("NewConversationService: %v", err)
+	}
+	attachments, err := NewAttachmentService(db, storage, conversations)
+	if err != nil {
+		t.Fatalf("NewAttachmentService: %v", err)
+	}
+	cache := NewMemoryResponseCache(time.Hour, 100, 1<<10)
+	privacy, err := NewPrivacyService(db, attachments, conversations, cache)
+	if err != nil {
+		t.Fatalf("NewPrivacyService: %v", err)
+	}
+	return &privacyTest{db: db, storage: storage, cache: cache, privacy: privacy}
+}
+
+func (p *privacyTest) create(t *testing.T, records ...any) {
+	t.Helper()
+	for _, record := range records {
+		if err := p.db.Create(record).Error; err != nil {
+			t.Fatalf("creating %T: %v", record, err)
+		}
+	}
+}
+
+// seedUserData 为用户创建会话、附件和其他关联数据，返回附件在存储中的键
+func (p *privacyTest) seedUserData(t *testing.T, user *models.User) string {
+	t.Helper()
+	ctx := context.Background()
+	sessionID := user.UserName + "-session"
+	question := &models.ChatMessage{SessionID: sessionID, UserID: &user.ID, Role: "user", Content: "my phone is 555-0100"}
+	p.create(t, question, &models.ChatMessage{SessionID: sessionID, UserID: &user.ID, Role: "assistant", Content: "noted"})
+
+	key := "attachments/" + user.UserName + "/notes.txt"
+	content := []byte("notes of " + user.UserName)
+	if err := p.storage.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("storing attachment: %v", err)
+	}
+	p.create(t,
+		&models.Attachment{MessageID: &question.ID, SessionID: sessionID, UserID: &user.ID, Source: "upload",
+			FileName: "notes.txt", ContentType: "text/plain", Size: int64(len(content)), StorageKey: key},
+		&models.ShareLink{Token: user.UserName + "-share", SessionID: sessionID, UserID: user.ID},
+		&models.EmailDelivery{UserID: user.ID, Purpose: models.EmailPurposeVerify, Email: user.Email},
+		&models.APIKey{UserID: user.ID, Name: "ci", Prefix: "glk_" + user.UserName, KeyHash: user.UserName + "-hash",
+			Scopes: models.APIKeyScopes{models.APIKeyScopeChat}},
+		&models.UserIdentity{UserID: user.ID, Provider: "corp", Issuer: "https://sso.example.com", Subject: user.UserName,
+			Email: user.Email, LastLoginAt: time.Now()},
+		&models.ModerationFlag{SessionID: sessionID, UserID: &user.ID, Stage: models.ModerationStageInput,
+			Checker: "keyword", Category: "pii", Action: "flag", Excerpt: "5…0"},
+		&models.KnowledgeDocument{Name: user.UserName + ".md", UserID: &user.ID},
+		&models.ResponseCacheEntry{CacheKey: user.UserName + "-cache", Content: "cached answer", UserID: &user.ID,
+			ExpiresAt: time.Now().Add(time.Hour)},
+	)
+	if err := p.cache.Set(WithToolCaller(ctx, user.ID), user.UserName+"-memory", "cached answer"); err != nil {
+		t.Fatalf("caching response: %v", err)
+	}
+	return key
+}
+
+func TestErasureRemovesPersonalData(t *testing.T) {
+	p := newPrivacyTest(t)
+	ctx := context.Background()
+	alice := createTestUser(t, p.db, "alice")
+	bobby := createTestUser(t, p.db, "bobby")
+	aliceKey := p.seedUserData(t, alice)
+	p.seedUserData(t, bobby)
+
+	audit := &AuditService{DB: p.db}
+	record := func(entry models.AuditLog) {
+		t.Helper()
+		if err := audit.Record(ctx, entry); err != nil {
+			t.Fatalf("recording audit log: %v", err)
+		}
+	}
+	created := models.NewAuditLog(models.AuditActionUserCreate, models.AuditTargetUser, alice.ID)
+	created.ActorID, created.IP = &alice.ID, "10.0.0.1"
+	created.Metadata = models.AuditMetadata{"user_name": "alicia"}
+	record(created)
+	renamed := models.NewAuditLog(models.AuditActionUserUpdate, models.AuditTargetUser, alice.ID)
+	renamed.ActorID, renamed.IP = &alice.ID, "10.0.0.1"
+	renamed.Changes = models.AuditChanges{
+		"user_name": {Before: "alicia", After: "alice"},
+		"email":     {Before: "alicia@example.com", After: alice.Email},
+	}
+	record(renamed)
+	login := models.NewAuditLog(models.AuditActionLogin, models.AuditTargetUser, alice.ID)
+	login.ActorID, login.IP = &alice.ID, "10.0.0.1"
+	record(login)
+	// 以前的用户名登录失败
+	record(models.AuditLog{Action: models.AuditActionLogin, Outcome: models.AuditOutcomeFailure, TargetType: models.AuditTargetUser,
+		IP: "10.0.0.2", Metadata: models.AuditMetadata{"method": "password", "user_name": "alicia"}})
+	// 其他用户的记录和管理员的 IP 保留
+	record(models.AuditLog{Action: models.AuditActionLogin, Outcome: models.AuditOutcomeFailure, TargetType: models.AuditTargetUser,
+		IP: "10.0.0.3", Metadata: models.AuditMetadata{"method": "password", "user_name": "bobby"}})
+	deactivated := models.NewAuditLog(models.AuditActionUserDeactivate, models.AuditTargetUser, alice.ID)
+	deactivated.ActorID, deactivated.IP = &bobby.ID, "10.0.0.4"
+	record(deactivated)
+
+	request, err := p.privacy.RequestErasure(ctx, alice.ID, alice.ID)
+	if err != nil {
+		t.Fatalf("RequestErasure: %v", err)
+	}
+	completed, err := p.privacy.ProcessErasures(ctx)
+	if err != nil || completed != 1 {
+		t.Fatalf("ProcessErasures = %d, %v; want 1 completed", completed, err)
+	}
+
+	request, err = p.privacy.GetErasure(ctx, request.ID)
+	if err != nil {
+		t.Fatalf("GetErasure: %v", err)
+	}
+	want := models.ErasureReceipt{
+		Sessions: 1, Messages: 2, Attachments: 1, ShareLinks: 1, APIKeys: 1, Identities: 1, EmailDeliveries: 1,
+		ResponseCacheEntries: 2, ModerationFlags: 1, KnowledgeDocuments: 1, AuditLogs: 4, UserDeleted: true,
+	}
+	if request.Status != models.ErasureStatusCompleted || request.Receipt != want {
+		t.Fatalf("got %s receipt %+v, want completed receipt %+v", request.Status, request.Receipt, want)
+	}
+
+	if err := p.db.Unscoped().First(&models.User{}, alice.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("user still exists: %v", err)
+	}
+	if _, err := p.storage.Get(ctx, aliceKey); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("attachment content still stored: %v", err)
+	}
+	if _, ok, _ := p.cache.Get(ctx, "alice-memory"); ok {
+		t.Fatal("in-memory cached response was not removed")
+	}
+	for _, model := range []any{
+		&models.ChatMessage{}, &models.Attachment{}, &models.ShareLink{}, &models.EmailDelivery{}, &models.APIKey{},
+		&models.UserIdentity{}, &models.ModerationFlag{}, &models.KnowledgeDocument{}, &models.ResponseCacheEntry{},
+	} {
+		var remaining, others int64
+		p.db.Model(model).Where("user_id = ?", alice.ID).Count(&remaining)
+		p.db.Model(model).Where("user_id = ?", bobby.ID).Count(&others)
+		if remaining != 0 || others == 0 {
+			t.Errorf("%T: %d rows of the erased user remain, %d rows of another user", model, remaining, others)
+		}
+	}
+	if _, ok, _ := p.cache.Get(ctx, "bobby-memory"); !ok {
+		t.Error("another user's cached response was removed")
+	}
+
+	var logs []models.AuditLog
+	if err := p.db.Order("id").Find(&logs).Error; err != nil {
+		t.Fatalf("loading audit logs: %v", err)
+	}
+	if len(logs) != 6 {
+		t.Fatalf("%d audit logs remain, want all 6 kept", len(logs))
+	}
+	dump, _ := json.Marshal(logs)
+	for _, personal := range []string{"alice", "10.0.0.1", "10.0.0.2"} {
+		if strings.Contains(string(dump), personal) {
+			t.Errorf("audit logs still contain %q: %s", personal, dump)
+		}
+	}
+	for _, kept := range []string{"bobby", "10.0.0.3", "10.0.0.4"} {
+		if !strings.Contains(string(dump), kept) {
+			t.Errorf("audit logs lost %q", kept)
+		}
+	}
+
+	// 匿名化之外审计日志仍然不能修改
+	err = p.db.Model(&logs[0]).Update("ip", "10.9.9.9").Error
+	if !errors.Is(err, models.ErrAuditLogImmutable) {
+		t.Fatalf("updating an audit log returned %v, want ErrAuditLogImmutable", err)
+	}
+	if completed, err := p.privacy.ProcessErasures(ctx); err != nil || completed != 0 {
+		t.Fatalf("second ProcessErasures = %d, %v; want nothing to do", completed, err)
+	}
+}
+
+func TestExportContainsUserData(t *testing.T) {
+	p := newPrivacyTest(t)
+	alice := createTestUser(t, p.db, "alice")
+	bobby := createTestUser(t, p.db, "bobby")
+	p.seedUserData(t, alice)
+	p.seedUserData(t, bobby)
+
+	var buf bytes.Buffer
+	if err := p.privacy.Export(context.Background(), alice.ID, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", file.Name, err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	var export models.UserExport
+	if err := json.Unmarshal([]byte(files["data.json"]), &export); err != nil {
+		t.Fatalf("decoding data.json: %v", err)
+	}
+	if export.User.ID != alice.ID || export.User.Email != alice.Email {
+		t.Fatalf("exported user %+v, want %s", export.User, alice.Email)
+	}
+	counts := map[string]int{
+		"conversations":       len(export.Conversations),
+		"identities":          len(export.Identities),
+		"api_keys":            len(export.APIKeys),
+		"attachments":         len(export.Attachments),
+		"share_links":         len(export.ShareLinks),
+		"knowledge_documents": len(export.KnowledgeDocuments),
+		"moderation_flags":    len(export.ModerationFlags),
+		"email_deliveries":    len(export.EmailDeliveries),
+	}
+	for name, count := range counts {
+		if count != 1 {
+			t.Errorf("exported %d %s, want only the user's 1", count, name)
+		}
+	}
+	if len(export.Conversations) == 1 && len(export.Conversations[0].Messages) != 2 {
+		t.Errorf("exported conversation has %d messages, want 2", len(export.Conversations[0].Messages))
+	}
+	for _, secret := range []string{"hash_password", "key_hash", "alice-hash", "bobby"} {
+		if strings.Contains(files["data.json"], secret) {
+			t.Errorf("data.json contains %q", secret)
+		}
+	}
+
+	if len(export.Attachments) == 1 {
+		name := fmt.Sprintf("attachments/%d-notes.txt", export.Attachments[0].ID)
+		if files[name] != "notes of alice" {
+			t.Errorf("attachment %s = %q, want the uploaded content", name, files[name])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// builtinPermissions 是全部内置权限及其说明
+var builtinPermissions = []models.Permission{
+	{Name: models.PermissionUsersRead, Description: "查看任意用户"},
+	{Name: models.PermissionUsersWrite, Description: "修改任意用户"},
+	{Name: models.PermissionUsersDelete, Description: "删除任意用户"},
+	{Name: models.PermissionRolesManage, Description: "查看角色并修改用户的角色"},
+	{Name: models.PermissionModerationRead, Description: "查看内容审核记录"},
+	{Name: models.PermissionModerationReview, Description: "复核内容审核记录"},
+	{Name: models.PermissionAPIKeysManage, Description: "查看、创建和吊销任意用户的 API 密钥"},
+	{Name: models.PermissionAuditRead, Description: "查看审计日志"},
+	{Name: models.PermissionKnowledgeManage, Description: "上传和删除知识库文档"},
+}
+
+// builtinRoles 是内置角色及其权限，启动时会把数据库中的内置角色同步为这里的定义
+var builtinRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	{models.RoleUser, "普通用户，只能访问自己的数据", nil},
+	{models.RoleAuditor, "审计员，可以只读访问用户、审核记录和审计日志", []string{
+		models.PermissionUsersRead, models.PermissionModerationRead, models.PermissionAuditRead,
+	}},
+	{models.RoleAdmin, "管理员，拥有全部权限", []string{
+		models.PermissionUsersRead, models.PermissionUsersWrite, models.PermissionUsersDelete,
+		models.PermissionRolesManage, models.PermissionModerationRead, models.PermissionModerationReview,
+		models.PermissionAPIKeysManage, models.PermissionAuditRead, models.PermissionKnowledgeManage,
+	}},
+}
+
+// RBACService 管理角色和权限。角色、权限及用户与角色的关联都保存在数据库中
+type RBACService struct {
+	DB *gorm.DB
+}
+
+// NewRBACService 创建内置权限和角色，并为 ADMIN_USER_IDS 中已存在的用户授予 admin 角色
+func NewRBACService(db *gorm.DB) (*RBACService, error) {
+	var adminIDs []uint
+	for _, v := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("ADMIN_USER_IDS must be a comma-separated list of user IDs")
+		}
+		adminIDs = append(adminIDs, uint(id))
+	}
+
+	s := &RBACService{DB: db}
+	if err := s.seed(adminIDs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RBACService) seed(adminIDs []uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(slices.Clone(builtinPermissions)).Error
+		if err != nil {
+			return err
+		}
+
+		for _, builtin := range builtinRoles {
+			role := models.Role{Name: builtin.name}
+			if err := tx.Where(&role).Attrs(models.Role{Description: builtin.description}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+			var permissions []models.Permission
+			if len(builtin.permissions) > 0 {
+				if err := tx.Where("name IN ?", builtin.permissions).Find(&permissions).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+				return err
+			}
+		}
+
+		if len(adminIDs) == 0 {
+			return nil
+		}
+		var admin models.Role
+		if err := tx.Where("name = ?", models.RoleAdmin).First(&admin).Error; err != nil {
+			return err
+		}
+		var users []models.User
+		if err := tx.Where("id IN ?", adminIDs).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) < len(adminIDs) {
+			log.Printf("Some users in ADMIN_USER_IDS do not exist yet, restart after they sign up to grant them the admin role")
+		}
+		for i := range users {
+			if err := tx.Model(&users[i]).Association("Roles").Append(&admin); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Permissions 返回用户通过全部角色获得的权限名称
+func (s *RBACService) Permissions(ctx context.Context, userID uint) (map[string]bool, error) {
+	var names []string
+	err := s.DB.WithContext(ctx).
+		Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	return permissions, nil
+}
+
+// ListRoles 返回全部角色及其权限
+func (s *RBACService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := s.DB.WithContext(ctx).Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return roles, nil
+}
+
+// SetUserRoles 将用户的角色替换为 roleNames，返回包含新角色的用户和修改前的角色名称
+func (s *RBACService) SetUserRoles(ctx context.Context, userID uint, roleNames []string) (*models.User, []string, error) {
+	var user models.User
+	var previous []string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Roles").First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+			}
+			return err
+		}
+		previous = models.NewUserResponse(&user).Roles
+
+		var roles []models.Role
+		if err := tx.Where("name IN ?", roleNames).Find(&roles).Error; err != nil {
+			return err
+		}
+		if unknown := unknownRoles(roleNames, roles); unknown != "" {
+			return domainErrors.NewAppErrorWithMessage(domainErrors.ValidationError, "role_unknown", unknown)
+		}
+		if err := tx.Model(&user).Association("Roles").Replace(roles); err != nil {
+			return err
+		}
+		return tx.Preload("Roles").First(&user, userID).Error
+	})
+	if err != nil {
+		return nil, nil, domainErrors.TranslateDBError(err)
+	}
+	return &user, previous, nil
+}
+
+// unknownRoles 返回 names 中不存在的角色名称，以逗号分隔
+func unknownRoles(names []string, roles []models.Role) string {
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return strings.Join(unknown, ", ")
+}
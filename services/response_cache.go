@@ -0,0 +1,323 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultResponseCacheTTL          = time.Hour
+	defaultResponseCacheMaxEntries   = 1000
+	defaultResponseCacheMaxEntrySize = 64 << 10
+)
+
+// 回复缓存的状态，通过 X-Cache 响应头返回
+const (
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheBypass = "BYPASS"
+)
+
+// CachedResponse 是缓存的一次回复
+type CachedResponse struct {
+	Content  string
+	StoredAt time.Time
+}
+
+// ResponseCache 缓存相同请求的回复，键由 AIService 根据消息和生成参数计算。
+// Set 记录 ctx 中通过 WithToolCaller 设置的用户，DeleteUser 删除该用户的请求写入的回复并返回删除的数量
+type ResponseCache interface {
+	Get(ctx context.Context, key string) (*CachedResponse, bool, error)
+	Set(ctx context.Context, key string, content string) error
+	DeleteUser(ctx context.Context, userID uint) (int64, error)
+}
+
+// cacheOwner 返回写入缓存的请求所属的用户
+func cacheOwner(ctx context.Context) *uint {
+	if userID, ok := ToolCallerFrom(ctx); ok {
+		return &userID
+	}
+	return nil
+}
+
+// NewResponseCache 根据 RESPONSE_CACHE 创建回复缓存：memory、sql，未配置时返回 nil 表示不启用。
+// RESPONSE_CACHE_TTL_SECONDS、RESPONSE_CACHE_MAX_ENTRIES 和 RESPONSE_CACHE_MAX_ENTRY_SIZE 分别设置
+// 有效期、最多缓存的条数和单条回复的最大字节数。
+func NewResponseCache(db *gorm.DB) (ResponseCache, error) {
+	kind := os.Getenv("RESPONSE_CACHE")
+	if kind == "" || kind == "off" {
+		return nil, nil
+	}
+
+	ttl := defaultResponseCacheTTL
+	if v := os.Getenv("RESPONSE_CACHE_TTL_SECONDS"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return nil, errors.New("RESPONSE_CACHE_TTL_SECONDS must be a positive integer")
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	limits := map[string]int{
+		"RESPONSE_CACHE_MAX_ENTRIES":    defaultResponseCacheMaxEntries,
+		"RESPONSE_CACHE_MAX_ENTRY_SIZE": defaultResponseCacheMaxEntrySize,
+	}
+	for name := range limits {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s must be a positive integer", name)
+			}
+			limits[name] = n
+		}
+	}
+
+	switch kind {
+	case "memory":
+		return NewMemoryResponseCache(ttl, limits["RESPONSE_CACHE_MAX_ENTRIES"], limits["RESPONSE_CACHE_MAX_ENTRY_SIZE"]), nil
+	case "sql":
+		return &SQLResponseCache{
+			DB:           db,
+			ttl:          ttl,
+			maxEntries:   limits["RESPONSE_CACHE_MAX_ENTRIES"],
+			maxEntrySize: limits["RESPONSE_CACHE_MAX_ENTRY_SIZE"],
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported RESPONSE_CACHE: %s", kind)
+	}
+}
+
+// CacheControl 控制单个请求如何使用回复缓存
+type CacheControl struct {
+	// NoRead 为 true 时不读取缓存，但仍会写入新的回复
+	NoRead bool
+	// NoStore 为 true 时既不读取也不写入缓存
+	NoStore bool
+}
+
+type cacheControlKey struct{}
+
+// WithCacheControl 返回携带缓存控制选项的 context
+func WithCacheControl(ctx context.Context, control CacheControl) context.Context {
+	return context.WithValue(ctx, cacheControlKey{}, control)
+}
+
+func cacheControlFrom(ctx context.Context) CacheControl {
+	control, _ := ctx.Value(cacheControlKey{}).(CacheControl)
+	return control
+}
+
+// cacheKeyMessage 是计算缓存键时使用的消息，图片只保留地址
+type cacheKeyMessage struct {
+	Role       string            `json:"role"`
+	Content    string            `json:"content"`
+	ToolCalls  []models.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	Images     []string          `json:"images,omitempty"`
+}
+
+// responseCacheKey 根据部署、生成参数、可用工具和规范化后的消息计算缓存键。
+// 消息内容去掉首尾空白并合并连续空白，使仅空白不同的请求命中同一条缓存。
+func (s *AIService) responseCacheKey(ctx context.Context, messages []ChatMessage, deployment string) string {
+	normalized := make([]cacheKeyMessage, 0, len(messages))
+	for _, msg := range messages {
+		m := cacheKeyMessage{
+			Role:       msg.Role,
+			Content:    strings.Join(strings.Fields(msg.Content), " "),
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, image := range msg.Images {
+			m.Images = append(m.Images, image.URL)
+		}
+		normalized = append(normalized, m)
+	}
+
+	var tools []string
+	for _, definition := range s.toolsForIteration(ctx, 0) {
+		if fn, ok := definition.(*azopenai.ChatCompletionsFunctionToolDefinition); ok && fn.Function != nil && fn.Function.Name != nil {
+			tools = append(tools, *fn.Function.Name)
+		}
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"deployment":        deployment,
+		"max_tokens":        s.maxTokens,
+		"temperature":       s.temperature,
+		"top_p":             s.topP,
+		"frequency_penalty": s.freqPenalty,
+		"presence_penalty":  s.presencePenalty,
+		"stop":              s.stop,
+		"tools":             tools,
+		"messages":          normalized,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryResponseCache 是进程内的 LRU 缓存
+type MemoryResponseCache struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	maxEntries   int
+	maxEntrySize int
+	entries      map[string]*list.Element
+	order        *list.List // 最近使用的在前
+}
+
+type memoryCacheEntry struct {
+	key       string
+	response  CachedResponse
+	userID    *uint
+	expiresAt time.Time
+}
+
+func NewMemoryResponseCache(ttl time.Duration, maxEntries, maxEntrySize int) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		maxEntrySize: maxEntrySize,
+		entries:      make(map[string]*list.Element),
+		order:        list.New(),
+	}
+}
+
+func (c *MemoryResponseCache) Get(_ context.Context, key string) (*CachedResponse, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(element)
+	response := entry.response
+	return &response, true, nil
+}
+
+func (c *MemoryResponseCache) Set(ctx context.Context, key string, content string) error {
+	if len(content) > c.maxEntrySize {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryCacheEntry{
+		key:       key,
+		response:  CachedResponse{Content: content, StoredAt: time.Now()},
+		userID:    cacheOwner(ctx),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// DeleteUser 只能删除当前进程中的缓存，其他实例中的缓存在有效期后过期
+func (c *MemoryResponseCache) DeleteUser(_ context.Context, userID uint) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var deleted int64
+	for key, element := range c.entries {
+		entry := element.Value.(*memoryCacheEntry)
+		if entry.userID != nil && *entry.userID == userID {
+			c.order.Remove(element)
+			delete(c.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// SQLResponseCache 把缓存保存在数据库中，多实例部署时可以共享
+type SQLResponseCache struct {
+	DB           *gorm.DB
+	ttl          time.Duration
+	maxEntries   int
+	maxEntrySize int
+}
+
+func (c *SQLResponseCache) Get(ctx context.Context, key string) (*CachedResponse, bool, error) {
+	// 未命中是常见情况，使用 Find 避免 gorm 为每次未命中打印 record not found
+	var entries []models.ResponseCacheEntry
+	err := c.DB.WithContext(ctx).Where("cache_key = ? AND expires_at > ?", key, time.Now()).Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, false, err
+	}
+	entry := entries[0]
+	return &CachedResponse{Content: entry.Content, StoredAt: entry.CreatedAt}, true, nil
+}
+
+func (c *SQLResponseCache) Set(ctx context.Context, key string, content string) error {
+	if len(content) > c.maxEntrySize {
+		return nil
+	}
+
+	now := time.Now()
+	db := c.DB.WithContext(ctx)
+	entry := models.ResponseCacheEntry{
+		CacheKey:  key,
+		Content:   content,
+		Size:      len(content),
+		UserID:    cacheOwner(ctx),
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.ttl),
+	}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		return err
+	}
+
+	// 清理过期的记录，超出条数限制时删除最早写入的记录
+	if err := db.Where("expires_at <= ?", now).Delete(&models.ResponseCacheEntry{}).Error; err != nil {
+		return err
+	}
+	var count int64
+	if err := db.Model(&models.ResponseCacheEntry{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if excess := int(count) - c.maxEntries; excess > 0 {
+		var keys []string
+		if err := db.Model(&models.ResponseCacheEntry{}).Order("created_at asc").Limit(excess).Pluck("cache_key", &keys).Error; err != nil {
+			return err
+		}
+		return db.Where("cache_key IN ?", keys).Delete(&models.ResponseCacheEntry{}).Error
+	}
+	return nil
+}
+
+func (c *SQLResponseCache) DeleteUser(ctx context.Context, userID uint) (int64, error) {
+	result := c.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.ResponseCacheEntry{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thoulee21/go-learn/models"
+)
+
+// requireCached 检查 key 是否在缓存中，在时内容应为 key 本身
+func requireCached(t *testing.T, cache ResponseCache, key string, want bool) {
+	t.Helper()
+	cached, ok, err := cache.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	if ok != want {
+		t.Fatalf("Get(%s) found = %v, want %v", key, ok, want)
+	}
+	if ok && cached.Content != key {
+		t.Fatalf("Get(%s) = %q", key, cached.Content)
+	}
+}
+
+func TestMemoryResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryResponseCache(time.Hour, 2, 100)
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(ctx, key, key); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	// 读取 a 后 b 成为最久未使用的条目
+	requireCached(t, cache, "a", true)
+	if err := cache.Set(ctx, "c", "c"); err != nil {
+		t.Fatalf("Set(c): %v", err)
+	}
+	requireCached(t, cache, "b", false)
+	requireCached(t, cache, "a", true)
+	requireCached(t, cache, "c", true)
+}
+
+func TestMemoryResponseCacheExpiresEntries(t *testing.T) {
+	cache := NewMemoryResponseCache(20*time.Millisecond, 10, 100)
+	if err := cache.Set(context.Background(), "a", "a"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	requireCached(t, cache, "a", true)
+	time.Sleep(30 * time.Millisecond)
+	requireCached(t, cache, "a", false)
+	if cache.order.Len() != 0 || len(cache.entries) != 0 {
+		t.Fatal("the expired entry was not removed")
+	}
+}
+
+func TestResponseCacheSkipsLargeEntries(t *testing.T) {
+	caches := map[string]ResponseCache{
+		"memory": NewMemoryResponseCache(time.Hour, 10, 4),
+		"sql":    &SQLResponseCache{DB: newTestDB(t), ttl: time.Hour, maxEntries: 10, maxEntrySize: 4},
+	}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{"four", "fives"} {
+				if err := cache.Set(ctx, key, key); err != nil {
+					t.Fatalf("Set(%s): %v", key, err)
+				}
+			}
+			requireCached(t, cache, "four", true)
+			requireCached(t, cache, "fives", false)
+		})
+	}
+}
+
+func TestSQLResponseCachePrunesOnSet(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	cache := &SQLResponseCache{DB: db, ttl: time.Hour, maxEntries: 2, maxEntrySize: 100}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cache.Set(ctx, key, key); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	// 超出条数限制时删除最早写入的记录
+	requireCached(t, cache, "a", false)
+	requireCached(t, cache, "b", true)
+	requireCached(t, cache, "c", true)
+
+	// 写入时清理已过期的记录
+	past := time.Now().Add(-time.Minute)
+	if err := db.Model(&models.ResponseCacheEntry{}).Where("cache_key = ?", "b").Update("expires_at", past).Error; err != nil {
+		t.Fatalf("expiring b: %v", err)
+	}
+	requireCached(t, cache, "b", false)
+	if err := cache.Set(ctx, "d", "d"); err != nil {
+		t.Fatalf("Set(d): %v", err)
+	}
+	var keys []string
+	if err := db.Model(&models.ResponseCacheEntry{}).Order("cache_key").Pluck("cache_key", &keys).Error; err != nil {
+		t.Fatalf("listing entries: %v", err)
+	}
+	if got := strings.Join(keys, ","); got != "c,d" {
+		t.Fatalf("entries = %s, want c,d", got)
+	}
+}
+
+func TestResponseCacheDeleteUser(t *testing.T) {
+	db := newTestDB(t)
+	caches := map[string]ResponseCache{
+		"memory": NewMemoryResponseCache(time.Hour, 10, 100),
+		"sql":    &SQLResponseCache{DB: db, ttl: time.Hour, maxEntries: 10, maxEntrySize: 100},
+	}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			writes := map[string]context.Context{
+				name + "-alice-1":   WithToolCaller(ctx, 1),
+				name + "-alice-2":   WithToolCaller(ctx, 1),
+				name + "-bob":       WithToolCaller(ctx, 2),
+				name + "-anonymous": ctx,
+			}
+			for key, writeCtx := range writes {
+				if err := cache.Set(writeCtx, key, key); err != nil {
+					t.Fatalf("Set(%s): %v", key, err)
+				}
+			}
+
+			deleted, err := cache.DeleteUser(ctx, 1)
+			if err != nil {
+				t.Fatalf("DeleteUser: %v", err)
+			}
+			if deleted != 2 {
+				t.Fatalf("deleted %d entries, want 2", deleted)
+			}
+			requireCached(t, cache, name+"-alice-1", false)
+			requireCached(t, cache, name+"-alice-2", false)
+			requireCached(t, cache, name+"-bob", true)
+			requireCached(t, cache, name+"-anonymous", true)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	domainErrors "github.com/thoulee21/go-learn/errors"
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+type ShareService struct {
+	DB                  *gorm.DB
+	ConversationService *ConversationService
+}
+
+func NewShareService(db *gorm.DB, conversationService *ConversationService) (*ShareService, error) {
+	return &ShareService{DB: db, ConversationService: conversationService}, nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create 为用户自己的会话创建分享链接，并保存当前消息的快照
+func (s *ShareService) Create(userID uint, request models.CreateShareLinkRequest) (*models.ShareLink, error) {
+	conversation, err := s.ConversationService.GetConversation(request.SessionID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	// 匿名会话无法证明归属，不允许分享
+	if SessionOwner(conversation.Messages) == nil {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+	}
+
+	messages := make([]models.SharedMessage, 0, len(conversation.Messages))
+	for _, msg := range conversation.Messages {
+		// 工具调用过程可能包含内部系统数据，不对外分享
+		if msg.Role == "tool" || msg.Content == "" {
+			continue
+		}
+		messages = append(messages, models.SharedMessage{Role: msg.Role, Content: msg.Content, CreatedAt: msg.CreatedAt})
+	}
+	snapshot, err := json.Marshal(messages)
+	if err != nil {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, domainErrors.NewAppError(err, domainErrors.TokenGeneratorError)
+	}
+
+	link := &models.ShareLink{
+		Token:        token,
+		SessionID:    request.SessionID,
+		UserID:       userID,
+		Title:        conversation.Title,
+		Snapshot:     string(snapshot),
+		MessageCount: len(messages),
+	}
+	if request.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(request.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := s.DB.Create(link).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return link, nil
+}
+
+// List 返回用户创建的全部分享链接
+func (s *ShareService) List(userID uint) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&links).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return links, nil
+}
+
+// Revoke 撤销分享链接，已撤销的链接再次撤销不会报错
+func (s *ShareService) Revoke(userID, id uint) error {
+	var link models.ShareLink
+	err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		return domainErrors.TranslateDBError(err)
+	}
+	if link.RevokedAt != nil {
+		return nil
+	}
+
+	if err := s.DB.Model(&link).Update("revoked_at", time.Now()).Error; err != nil {
+		return domainErrors.TranslateDBError(err)
+	}
+	return nil
+}
+
+// GetShared 根据令牌返回分享快照，已撤销或已过期的链接按不存在处理
+func (s *ShareService) GetShared(token string) (*models.SharedConversation, error) {
+	var link models.ShareLink
+	err := s.DB.Where("token = ?", token).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+		}
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	if link.RevokedAt != nil || (link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt)) {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.NotFound)
+	}
+
+	var messages []models.SharedMessage
+	if err := json.Unmarshal([]byte(link.Snapshot), &messages); err != nil {
+		return nil, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+	}
+	return &models.SharedConversation{
+		Title:     link.Title,
+		SharedAt:  link.CreatedAt,
+		ExpiresAt: link.ExpiresAt,
+		Messages:  messages,
+	}, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/thoulee21/go-learn/models"
+)
+
+const defaultToolTimeout = 10 * time.Second
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ErrToolIterationsExceeded 表示模型在达到 AI_MAX_TOOL_ITERATIONS 轮工具调用、不再提供工具后仍然请求调用工具
+var ErrToolIterationsExceeded = errors.New("the model kept calling tools after the maximum number of tool iterations")
+
+// ToolHandler 执行一次工具调用，arguments 为模型生成的 JSON 参数，返回值会作为 tool 消息发送给模型
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Tool 描述一个可以被模型调用的函数
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters 是描述参数的 JSON Schema 对象
+	Parameters json.RawMessage
+	// Timeout 为零时使用注册表的默认超时
+	Timeout time.Duration
+	// RequiresUser 为 true 的工具只提供给已登录用户，处理函数通过 ToolCallerFrom 获取用户
+	RequiresUser bool
+	Handler      ToolHandler
+}
+
+type toolCallerKey struct{}
+
+// WithToolCaller 返回记录了发起对话的用户的 context，工具只能查询该用户的数据
+func WithToolCaller(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, toolCallerKey{}, userID)
+}
+
+// ToolCallerFrom 返回发起对话的用户，匿名请求返回 false
+func ToolCallerFrom(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(toolCallerKey{}).(uint)
+	return userID, ok
+}
+
+// availableTo 判断工具能否在 ctx 对应的请求中使用
+func (t Tool) availableTo(ctx context.Context) bool {
+	if !t.RequiresUser {
+		return true
+	}
+	_, ok := ToolCallerFrom(ctx)
+	return ok
+}
+
+// ToolRegistry 保存已注册的工具，可以在多个请求之间并发使用
+type ToolRegistry struct {
+	mu             sync.RWMutex
+	tools          map[string]Tool
+	order          []string
+	defaultTimeout time.Duration
+}
+
+func NewToolRegistry(defaultTimeout time.Duration) *ToolRegistry {
+	if defaultTimeout <= 0 {
+		defaultTimeout = defaultToolTimeout
+	}
+	return &ToolRegistry{tools: make(map[string]Tool), defaultTimeout: defaultTimeout}
+}
+
+// Register 注册工具，名称重复或参数不是合法的 JSON Schema 对象时返回错误
+func (r *ToolRegistry) Register(tool Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name: %q", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %s has no handler", tool.Name)
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(tool.Parameters, &schema); err != nil {
+		return fmt.Errorf("tool %s has invalid parameters schema: %w", tool.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Len 返回已注册工具的数量
+func (r *ToolRegistry) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// definitions 将 ctx 对应的请求可以使用的工具转换为 Azure SDK 的工具定义
+func (r *ToolRegistry) definitions(ctx context.Context) []azopenai.ChatCompletionsToolDefinitionClassification {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]azopenai.ChatCompletionsToolDefinitionClassification, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		if !tool.availableTo(ctx) {
+			continue
+		}
+		definitions = append(definitions, &azopenai.ChatCompletionsFunctionToolDefinition{
+			Type: toPtr("function"),
+			Function: &azopenai.ChatCompletionsFunctionToolDefinitionFunction{
+				Name:        toPtr(tool.Name),
+				Description: toPtr(tool.Description),
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// Execute 执行工具调用。执行失败时返回描述错误的 JSON，让模型可以据此继续回答
+func (r *ToolRegistry) Execute(ctx context.Context, call models.ToolCall) string {
+	if r == nil {
+		return toolError(fmt.Errorf("unknown tool: %s", call.Name))
+	}
+	r.mu.RLock()
+	tool, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok || !tool.availableTo(ctx) {
+		return toolError(fmt.Errorf("unknown tool: %s", call.Name))
+	}
+
+	arguments := json.RawMessage(call.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return toolError(errors.New("arguments are not valid JSON"))
+	}
+
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = r.defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 处理函数可能不响应 ctx，在单独的 goroutine 中执行以保证超时生效
+	type toolResult struct {
+		output string
+		err    error
+	}
+	done := make(chan toolResult, 1)
+	start := time.Now()
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- toolResult{err: fmt.Errorf("tool panicked: %v", p)}
+			}
+		}()
+		output, err := tool.Handler(ctx, arguments)
+		done <- toolResult{output: output, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		slog.InfoContext(ctx, "tool finished",
+			slog.String("tool", call.Name), slog.String("call_id", call.ID), slog.Duration("duration", time.Since(start)))
+		if result.err != nil {
+			return toolError(result.err)
+		}
+		return result.output
+	case <-ctx.Done():
+		slog.WarnContext(ctx, "tool aborted",
+			slog.String("tool", call.Name), slog.String("call_id", call.ID), slog.Duration("duration", time.Since(start)), slog.Any("error", ctx.Err()))
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return toolError(fmt.Errorf("tool timed out after %s", timeout))
+		}
+		return toolError(ctx.Err())
+	}
+}
+
+func toolError(err error) string {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(b)
+}
+
+func toPtr[T any](v T) *T {
+	return &v
+}
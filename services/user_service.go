@@ -1,9 +1,9 @@
 package services
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
+	"time"
 
 	domainErrors "github.com/thoulee21/go-learn/errors"
 	"github.com/thoulee21/go-learn/models"
@@ -21,31 +21,37 @@ func NewUserService(db *gorm.DB) (*UserService, error) {
 func (r *UserService) GetAll() (*[]models.User, error) {
 	var users []models.User
 	if err := r.DB.Find(&users).Error; err != nil {
-		return nil, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+		return nil, domainErrors.TranslateDBError(err)
 	}
 	return &users, nil
 }
 
-func (r *UserService) Create(userDomain *models.User) (*models.User, error) {
+func (r *UserService) Create(userDomain *models.User, password string) (*models.User, error) {
 	userRepository := userDomain
-	txDb := r.DB.Create(userRepository)
-	err := txDb.Error
+	hash, err := HashPassword(password)
 	if err != nil {
-		byteErr, _ := json.Marshal(err)
-		var newError domainErrors.GormErr
-		errUnmarshal := json.Unmarshal(byteErr, &newError)
-		if errUnmarshal != nil {
-			return &models.User{}, errUnmarshal
-		}
-		switch newError.Number {
-		case 1062:
-			err = domainErrors.NewAppErrorWithType(domainErrors.ResourceAlreadyExists)
-			return &models.User{}, err
-		default:
-			err = domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
-		}
+		return &models.User{}, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+	}
+	userRepository.HashPassword = hash
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		return createUser(tx, userRepository)
+	})
+	if err != nil {
+		return &models.User{}, domainErrors.TranslateDBError(err)
 	}
-	return userRepository, err
+	return userRepository, nil
+}
+
+// createUser 在事务 tx 中创建用户，新用户默认拥有 user 角色
+func createUser(tx *gorm.DB, user *models.User) error {
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	var role models.Role
+	if err := tx.Where("name = ?", models.RoleUser).First(&role).Error; err != nil {
+		return err
+	}
+	return tx.Model(user).Association("Roles").Append(&role)
 }
 
 func IsZeroValue(value any) bool {
@@ -62,7 +68,7 @@ func (r *UserService) GetOneByMap(userMap map[string]interface{}) (*models.User,
 		}
 	}
 	if err := tx.Find(&userRepository).Error; err != nil {
-		return &models.User{}, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+		return &models.User{}, domainErrors.TranslateDBError(err)
 	}
 	return &userRepository, nil
 }
@@ -70,15 +76,8 @@ func (r *UserService) GetOneByMap(userMap map[string]interface{}) (*models.User,
 func (r *UserService) GetByID(id uint) (*models.User, error) {
 	var user models.User
 
-	dbRes := r.DB.Where("id = ?", id)
-	err := dbRes.Error
-	if err != nil {
-		return &models.User{}, err
-	}
-
-	err = dbRes.First(&user).Error
-	if err != nil {
-		return &models.User{}, err
+	if err := r.DB.Preload("Roles").Where("id = ?", id).First(&user).Error; err != nil {
+		return &models.User{}, domainErrors.TranslateDBError(err)
 	}
 	return &user, nil
 }
@@ -86,34 +85,81 @@ func (r *UserService) GetByID(id uint) (*models.User, error) {
 func (r *UserService) Update(id uint, userMap *models.User) (*models.User, error) {
 	var userObj models.User
 	userObj.ID = id
-	err := r.DB.Model(&userObj).Select("user_name", "email").Updates(userMap).Error
-	if err != nil {
-		byteErr, _ := json.Marshal(err)
-		var newError domainErrors.GormErr
-		errUnmarshal := json.Unmarshal(byteErr, &newError)
-		if errUnmarshal != nil {
-			return &models.User{}, errUnmarshal
-		}
-		switch newError.Number {
-		case 1062:
-			return &models.User{}, domainErrors.NewAppErrorWithType(domainErrors.ResourceAlreadyExists)
-		default:
-			return &models.User{}, domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// 直接修改邮箱后需要重新验证
+		err := tx.Model(&models.User{}).Where("id = ? AND email <> ?", id, userMap.Email).
+			Update("email_verified_at", nil).Error
+		if err != nil {
+			return err
 		}
+		return tx.Model(&userObj).Select("user_name", "email").Updates(userMap).Error
+	})
+	if err != nil {
+		return &models.User{}, domainErrors.TranslateDBError(err)
 	}
 	if err := r.DB.Where("id = ?", id).First(&userObj).Error; err != nil {
-		return &models.User{}, err
+		return &models.User{}, domainErrors.TranslateDBError(err)
 	}
 	return &userObj, nil
 }
 
+// Delete 软删除用户，用户的角色和数据都会保留，管理员可以通过 Restore 恢复。
+// 彻底删除用户数据使用 PrivacyService.RequestErasure
 func (r *UserService) Delete(id uint) error {
-	tx := r.DB.Delete(&models.User{}, id)
+	tx := r.DB.Delete(&models.User{ID: id})
 	if tx.Error != nil {
-		return domainErrors.NewAppErrorWithType(domainErrors.UnknownError)
+		return domainErrors.TranslateDBError(tx.Error)
 	}
 	if tx.RowsAffected == 0 {
 		return domainErrors.NewAppErrorWithType(domainErrors.NotFound)
 	}
 	return nil
 }
+
+// GetDeleted 返回已被软删除的用户
+func (r *UserService) GetDeleted() (*[]models.User, error) {
+	var users []models.User
+	if err := r.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&users).Error; err != nil {
+		return nil, domainErrors.TranslateDBError(err)
+	}
+	return &users, nil
+}
+
+// Restore 恢复被软删除的用户，已请求彻底删除的用户不能恢复
+func (r *UserService) Restore(id uint) (*models.User, error) {
+	var pending int64
+	if err := r.DB.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", id, models.ErasureStatusPending).
+		Count(&pending).Error; err != nil {
+		return &models.User{}, domainErrors.TranslateDBError(err)
+	}
+	if pending > 0 {
+		return &models.User{}, domainErrors.NewAppErrorWithMessage(domainErrors.Conflict, "erasure_pending")
+	}
+
+	tx := r.DB.Unscoped().Model(&models.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if tx.Error != nil {
+		return &models.User{}, domainErrors.TranslateDBError(tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return &models.User{}, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "deleted_user_not_found")
+	}
+	return r.GetByID(id)
+}
+
+// SetActive 停用或重新启用用户。停用的用户不能登录，已签发的访问令牌和 API 密钥也会失效
+func (r *UserService) SetActive(id uint, active bool) (*models.User, error) {
+	var deactivatedAt *time.Time
+	if !active {
+		now := time.Now()
+		deactivatedAt = &now
+	}
+	tx := r.DB.Model(&models.User{}).Where("id = ?", id).Update("deactivated_at", deactivatedAt)
+	if tx.Error != nil {
+		return &models.User{}, domainErrors.TranslateDBError(tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return &models.User{}, domainErrors.NewAppErrorWithMessage(domainErrors.NotFound, "user_not_found")
+	}
+	return r.GetByID(id)
+}
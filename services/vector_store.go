@@ -0,0 +1,205 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/thoulee21/go-learn/models"
+	"gorm.io/gorm"
+)
+
+// VectorRecord 是向量索引中的一条记录
+type VectorRecord struct {
+	ChunkID    uint
+	DocumentID uint
+	Vector     []float32
+}
+
+// VectorMatch 是相似度检索的结果，Score 为余弦相似度
+type VectorMatch struct {
+	ChunkID    uint
+	DocumentID uint
+	Score      float32
+}
+
+// VectorStore 是知识库片段的向量索引。
+// 片段和向量始终持久化在 knowledge_chunks 表中，索引只负责检索。
+type VectorStore interface {
+	Index(ctx context.Context, records []VectorRecord) error
+	Search(ctx context.Context, query []float32, k int) ([]VectorMatch, error)
+	DeleteDocument(ctx context.Context, documentID uint) error
+}
+
+// NewVectorStore 根据 KB_VECTOR_STORE 创建向量索引：memory（默认）或 sql
+func NewVectorStore(db *gorm.DB) (VectorStore, error) {
+	switch kind := os.Getenv("KB_VECTOR_STORE"); kind {
+	case "", "memory":
+		store := NewMemoryVectorStore()
+		if err := store.Load(db); err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "sql":
+		return &SQLVectorStore{DB: db}, nil
+	default:
+		return nil, fmt.Errorf("unsupported KB_VECTOR_STORE: %s", kind)
+	}
+}
+
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = f / norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// matchHeap 是按分数排序的小顶堆，用于保留前 k 个结果
+type matchHeap []VectorMatch
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return h[i].Score < h[j].Score }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(VectorMatch)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+type topK struct {
+	k int
+	h matchHeap
+}
+
+func (t *topK) add(m VectorMatch) {
+	if t.h.Len() < t.k {
+		heap.Push(&t.h, m)
+	} else if m.Score > t.h[0].Score {
+		t.h[0] = m
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// sorted 按分数从高到低返回结果
+func (t *topK) sorted() []VectorMatch {
+	result := make([]VectorMatch, t.h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&t.h).(VectorMatch)
+	}
+	return result
+}
+
+// MemoryVectorStore 是进程内的暴力检索索引，启动时从数据库加载
+type MemoryVectorStore struct {
+	mu      sync.RWMutex
+	records map[uint]VectorRecord
+}
+
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{records: make(map[uint]VectorRecord)}
+}
+
+// Load 从 knowledge_chunks 表加载全部向量
+func (s *MemoryVectorStore) Load(db *gorm.DB) error {
+	var chunks []models.KnowledgeChunk
+	return db.Select("id", "document_id", "embedding").FindInBatches(&chunks, 500, func(tx *gorm.DB, batch int) error {
+		records := make([]VectorRecord, 0, len(chunks))
+		for _, chunk := range chunks {
+			records = append(records, VectorRecord{ChunkID: chunk.ID, DocumentID: chunk.DocumentID, Vector: chunk.Embedding})
+		}
+		return s.Index(context.Background(), records)
+	}).Error
+}
+
+func (s *MemoryVectorStore) Index(_ context.Context, records []VectorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		record.Vector = normalize(record.Vector)
+		s.records[record.ChunkID] = record
+	}
+	return nil
+}
+
+func (s *MemoryVectorStore) Search(_ context.Context, query []float32, k int) ([]VectorMatch, error) {
+	query = normalize(query)
+	top := &topK{k: k}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.records {
+		top.add(VectorMatch{ChunkID: record.ChunkID, DocumentID: record.DocumentID, Score: dot(query, record.Vector)})
+	}
+	return top.sorted(), nil
+}
+
+func (s *MemoryVectorStore) DeleteDocument(_ context.Context, documentID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, record := range s.records {
+		if record.DocumentID == documentID {
+			delete(s.records, id)
+		}
+	}
+	return nil
+}
+
+// SQLVectorStore 每次检索都直接扫描数据库中的向量，适合多实例部署时共享同一份知识库
+type SQLVectorStore struct {
+	DB *gorm.DB
+}
+
+// Index 无需操作，向量已随片段写入数据库
+func (s *SQLVectorStore) Index(context.Context, []VectorRecord) error {
+	return nil
+}
+
+func (s *SQLVectorStore) Search(ctx context.Context, query []float32, k int) ([]VectorMatch, error) {
+	query = normalize(query)
+	top := &topK{k: k}
+
+	var chunks []models.KnowledgeChunk
+	err := s.DB.WithContext(ctx).Select("id", "document_id", "embedding").
+		FindInBatches(&chunks, 500, func(tx *gorm.DB, batch int) error {
+			for _, chunk := range chunks {
+				score := dot(query, normalize(chunk.Embedding))
+				top.add(VectorMatch{ChunkID: chunk.ID, DocumentID: chunk.DocumentID, Score: score})
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return top.sorted(), nil
+}
+
+// DeleteDocument 无需操作，片段删除后向量随之删除
+func (s *SQLVectorStore) DeleteDocument(context.Context, uint) error {
+	return nil
+}
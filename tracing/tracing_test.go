@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// collectorStandIn 是一个只接收 OTLP/HTTP protobuf 请求的 collector
+type collectorStandIn struct {
+	mu       sync.Mutex
+	requests []*collectortrace.ExportTraceServiceRequest
+}
+
+func (c *collectorStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, &request)
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	out, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	_, _ = w.Write(out)
+}
+
+// useTracerProvider 在测试期间替换全局 tracer provider
+func useTracerProvider(t *testing.T, provider trace.TracerProvider) {
+	t.Helper()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+}
+
+func TestSetupExportsSpansToCollector(t *testing.T) {
+	collector := &collectorStandIn{}
+	server := httptest.NewServer(collector)
+	t.Cleanup(server.Close)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_SERVICE_NAME", "aichatbot-test")
+	useTracerProvider(t, otel.GetTracerProvider())
+
+	ctx := context.Background()
+	shutdown, err := Setup(ctx)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	_, span := Tracer().Start(ctx, "test.operation")
+	span.End()
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	var names []string
+	var serviceName string
+	for _, request := range collector.requests {
+		for _, resourceSpans := range request.GetResourceSpans() {
+			for _, attr := range resourceSpans.GetResource().GetAttributes() {
+				if attr.GetKey() == "service.name" {
+					serviceName = attr.GetValue().GetStringValue()
+				}
+			}
+			for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+				for _, exported := range scopeSpans.GetSpans() {
+					names = append(names, exported.GetName())
+				}
+			}
+		}
+	}
+	if len(names) != 1 || names[0] != "test.operation" {
+		t.Fatalf("collector received spans %v, want [test.operation]", names)
+	}
+	if serviceName != "aichatbot-test" {
+		t.Fatalf("service.name = %q, want OTEL_SERVICE_NAME to override the default", serviceName)
+	}
+}
+
+func TestGormPluginTracesQueriesWithParentSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	useTracerProvider(t, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatalf("registering plugin: %v", err)
+	}
+	type note struct {
+		ID   uint
+		Text string
+	}
+	if err := db.AutoMigrate(&note{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	// 没有父 span 的查询不记录
+	var notes []note
+	if err := db.WithContext(context.Background()).Find(&notes).Error; err != nil {
+		t.Fatalf("query without parent: %v", err)
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Fatalf("recorded %d spans for a query without a parent span", len(spans))
+	}
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	if err := db.WithContext(ctx).Find(&notes).Error; err != nil {
+		t.Fatalf("query with parent: %v", err)
+	}
+	parent.End()
+
+	var query sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "gorm.query" {
+			query = span
+		}
+	}
+	if query == nil {
+		t.Fatal("no gorm.query span was recorded")
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("gorm.query span is not a child of the request span")
+	}
+	if query.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("span kind = %v, want client", query.SpanKind())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range query.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	if got := attrs["db.system"].AsString(); got != "sqlite" {
+		t.Fatalf("db.system = %q, want sqlite", got)
+	}
+	if got := attrs["db.collection.name"].AsString(); got != "notes" {
+		t.Fatalf("db.collection.name = %q, want notes", got)
+	}
+}
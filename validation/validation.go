@@ -0,0 +1,88 @@
+// Package validation configures the validator shared by all request bindings and
+// registers the custom rules used in binding tags.
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/thoulee21/go-learn/i18n"
+)
+
+// Custom rules, usable in binding tags like the built-in ones.
+const (
+	// UsernameTag accepts letters, digits, '_', '-' and '.', starting with a letter or digit.
+	UsernameTag = "username"
+	// PasswordTag requires at least one letter and one digit. Length is checked with min and max.
+	PasswordTag = "password"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// rules maps custom tags to their checks and the i18n message shown when they fail.
+var rules = map[string]struct {
+	check     validator.Func
+	messageID string
+}{
+	UsernameTag: {validateUsername, "username_charset"},
+	PasswordTag: {validatePassword, "password_strength"},
+}
+
+// Setup configures gin's validator to name fields by their JSON names, registers the
+// custom rules and the translations of all rules.
+func Setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+	v.RegisterTagNameFunc(jsonFieldName)
+	if err := i18n.RegisterValidatorTranslations(v); err != nil {
+		return err
+	}
+
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule.check); err != nil {
+			return err
+		}
+		for _, lang := range []string{i18n.English, i18n.SimplifiedChinese} {
+			err := v.RegisterTranslation(tag, i18n.Translator(lang),
+				func(ut.Translator) error { return nil },
+				func(_ ut.Translator, fe validator.FieldError) string {
+					return i18n.T(lang, rule.messageID, fe.Field())
+				})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateUsername(fl validator.FieldLevel) bool {
+	return usernamePattern.MatchString(fl.Field().String())
+}
+
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	return strings.ContainsFunc(password, unicode.IsLetter) && strings.ContainsFunc(password, unicode.IsDigit)
+}
+
+// jsonFieldName names struct fields by their json, form or uri tag in validation errors.
+func jsonFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}